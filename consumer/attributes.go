@@ -0,0 +1,62 @@
+package consumer
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/hatsunemiku3939/sqsrouter"
+)
+
+// systemAttributeNames are the SQS system attributes requested on every ReceiveMessage call.
+var systemAttributeNames = []sqstypes.MessageSystemAttributeName{
+	sqstypes.MessageSystemAttributeNameApproximateReceiveCount,
+	sqstypes.MessageSystemAttributeNameApproximateFirstReceiveTimestamp,
+	sqstypes.MessageSystemAttributeNameSentTimestamp,
+	sqstypes.MessageSystemAttributeNameMessageGroupId,
+	sqstypes.MessageSystemAttributeNameMessageDeduplicationId,
+	sqstypes.MessageSystemAttributeNameSequenceNumber,
+	sqstypes.MessageSystemAttributeNameAWSTraceHeader,
+}
+
+// allMessageAttributes requests every user-defined message attribute.
+var allMessageAttributes = []string{"All"}
+
+// sqsAttributes converts the attributes of a received message into the router's representation.
+func sqsAttributes(queueURL string, msg *sqstypes.Message) *sqsrouter.SQSAttributes {
+	attrs := &sqsrouter.SQSAttributes{
+		QueueURL:               queueURL,
+		MessageID:              aws.ToString(msg.MessageId),
+		MessageGroupID:         msg.Attributes[string(sqstypes.MessageSystemAttributeNameMessageGroupId)],
+		MessageDeduplicationID: msg.Attributes[string(sqstypes.MessageSystemAttributeNameMessageDeduplicationId)],
+		SequenceNumber:         msg.Attributes[string(sqstypes.MessageSystemAttributeNameSequenceNumber)],
+		AWSTraceHeader:         msg.Attributes[string(sqstypes.MessageSystemAttributeNameAWSTraceHeader)],
+	}
+	if n, err := strconv.Atoi(msg.Attributes[string(sqstypes.MessageSystemAttributeNameApproximateReceiveCount)]); err == nil {
+		attrs.ApproximateReceiveCount = n
+	}
+	attrs.SentTimestamp = epochMillis(msg.Attributes[string(sqstypes.MessageSystemAttributeNameSentTimestamp)])
+	attrs.ApproximateFirstReceiveTimestamp = epochMillis(msg.Attributes[string(sqstypes.MessageSystemAttributeNameApproximateFirstReceiveTimestamp)])
+
+	if len(msg.MessageAttributes) > 0 {
+		attrs.MessageAttributes = make(map[string]sqsrouter.MessageAttribute, len(msg.MessageAttributes))
+		for name, v := range msg.MessageAttributes {
+			attrs.MessageAttributes[name] = sqsrouter.MessageAttribute{
+				DataType:    aws.ToString(v.DataType),
+				StringValue: aws.ToString(v.StringValue),
+				BinaryValue: v.BinaryValue,
+			}
+		}
+	}
+	return attrs
+}
+
+// epochMillis parses an SQS timestamp attribute (milliseconds since the epoch); invalid input yields the zero time.
+func epochMillis(v string) time.Time {
+	ms, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
@@ -0,0 +1,112 @@
+package consumer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	sqsrouter "github.com/hatsunemiku3939/sqsrouter"
+)
+
+func TestSQSAttributes(t *testing.T) {
+	msg := types.Message{
+		MessageId: aws.String("sqs-id-1"),
+		Attributes: map[string]string{
+			"ApproximateReceiveCount":          "4",
+			"SentTimestamp":                    "1700000000000",
+			"ApproximateFirstReceiveTimestamp": "1700000001000",
+			"MessageGroupId":                   "group-a",
+			"MessageDeduplicationId":           "dedup-1",
+			"SequenceNumber":                   "18849496460467696128",
+			"AWSTraceHeader":                   "Root=1-5759e988-bd862e3fe1be46a994272793",
+		},
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"tenant": {DataType: aws.String("String"), StringValue: aws.String("acme")},
+			"blob":   {DataType: aws.String("Binary"), BinaryValue: []byte{1, 2}},
+		},
+	}
+
+	attrs := sqsAttributes("queue-url", &msg)
+
+	assert.Equal(t, &sqsrouter.SQSAttributes{
+		QueueURL:                         "queue-url",
+		MessageID:                        "sqs-id-1",
+		ApproximateReceiveCount:          4,
+		SentTimestamp:                    time.UnixMilli(1700000000000),
+		ApproximateFirstReceiveTimestamp: time.UnixMilli(1700000001000),
+		MessageGroupID:                   "group-a",
+		MessageDeduplicationID:           "dedup-1",
+		SequenceNumber:                   "18849496460467696128",
+		AWSTraceHeader:                   "Root=1-5759e988-bd862e3fe1be46a994272793",
+		MessageAttributes: map[string]sqsrouter.MessageAttribute{
+			"tenant": {DataType: "String", StringValue: "acme"},
+			"blob":   {DataType: "Binary", BinaryValue: []byte{1, 2}},
+		},
+	}, attrs)
+}
+
+func TestSQSAttributes_MissingAttributes(t *testing.T) {
+	attrs := sqsAttributes("queue-url", &types.Message{})
+
+	assert.Equal(t, 0, attrs.ApproximateReceiveCount)
+	assert.True(t, attrs.SentTimestamp.IsZero())
+	assert.Nil(t, attrs.MessageAttributes)
+}
+
+func TestConsumer_processMessage_ExposesAttributes(t *testing.T) {
+	router, err := sqsrouter.NewRouter(sqsrouter.EnvelopeSchema)
+	require.NoError(t, err)
+
+	var fromState, fromHandler *sqsrouter.SQSAttributes
+	router.Use(func(next sqsrouter.HandlerFunc) sqsrouter.HandlerFunc {
+		return func(ctx context.Context, s *sqsrouter.RouteState) (sqsrouter.RoutedResult, error) {
+			fromState = s.SQS
+			return next(ctx, s)
+		}
+	})
+	router.Register("test.event", "1.0", func(ctx context.Context, _ []byte, _ []byte) sqsrouter.HandlerResult {
+		fromHandler, _ = sqsrouter.SQSAttributesFromContext(ctx)
+		return sqsrouter.HandlerResult{ShouldDelete: true}
+	})
+
+	client := new(MockSQSClient)
+	client.On("DeleteMessage", mock.Anything, mock.Anything).Return(&sqs.DeleteMessageOutput{}, nil)
+	c, err := NewConsumer(client, "queue-url", router)
+	require.NoError(t, err)
+
+	msg := createSQSMessage(`{"schemaVersion":"1.0","messageType":"test.event","messageVersion":"1.0","message":{},"metadata":{}}`, "receipt-1")
+	msg.MessageId = aws.String("sqs-id-1")
+	msg.Attributes = map[string]string{"ApproximateReceiveCount": "2"}
+	c.processMessage(context.Background(), c.queues[0], &msg)
+
+	require.NotNil(t, fromState)
+	assert.Equal(t, "sqs-id-1", fromState.MessageID)
+	assert.Equal(t, 2, fromState.ApproximateReceiveCount)
+	assert.Equal(t, "queue-url", fromState.QueueURL)
+	assert.Same(t, fromState, fromHandler)
+}
+
+func TestConsumer_Start_RequestsAttributes(t *testing.T) {
+	router, err := sqsrouter.NewRouter(sqsrouter.EnvelopeSchema)
+	require.NoError(t, err)
+	client := new(MockSQSClient)
+	c, err := NewConsumer(client, "queue-url", router)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client.On("ReceiveMessage", mock.Anything, mock.MatchedBy(func(in *sqs.ReceiveMessageInput) bool {
+		return assert.ObjectsAreEqual(systemAttributeNames, in.MessageSystemAttributeNames) &&
+			assert.ObjectsAreEqual([]string{"All"}, in.MessageAttributeNames)
+	})).Run(func(mock.Arguments) { cancel() }).Return(&sqs.ReceiveMessageOutput{}, nil).Once()
+
+	c.Start(ctx)
+
+	client.AssertExpectations(t)
+}
@@ -0,0 +1,279 @@
+package consumer
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// --- SQS Consumer Configuration ---
+const (
+	// DefaultMaxNumberOfMessages defines the maximum number of messages to retrieve in one SQS API call.
+	DefaultMaxNumberOfMessages = 5
+	// DefaultWaitTimeSeconds enables SQS Long Polling, reducing cost and empty responses.
+	DefaultWaitTimeSeconds = 10
+	// DefaultDeleteTimeout sets a client-side timeout for the DeleteMessage API call.
+	DefaultDeleteTimeout = 5 * time.Second
+	// DefaultProcessingTimeout sets a deadline for processing a single message.
+	// This should be less than the container's graceful shutdown period (e.g., terminationGracePeriodSeconds in K8s).
+	DefaultProcessingTimeout = 30 * time.Second
+	// DefaultRetrySleep defines the duration to wait before retrying after a failed SQS API call.
+	DefaultRetrySleep = 2 * time.Second
+	// DefaultMaxConcurrency bounds the number of messages processed at the same time.
+	DefaultMaxConcurrency = 10
+	// DefaultDeleteBatchSize is the number of receipt handles sent per DeleteMessageBatch call.
+	DefaultDeleteBatchSize = 10
+	// DefaultDeleteBatchWindow is how long the delete batcher waits for a batch to fill up.
+	DefaultDeleteBatchWindow = 100 * time.Millisecond
+	// DefaultDeleteMaxRetries is how many times failed batch entries are retried.
+	DefaultDeleteMaxRetries = 3
+	// DefaultVisibilityExtension is the visibility timeout set by each heartbeat.
+	DefaultVisibilityExtension = 30 * time.Second
+	// DefaultMaxVisibilityExtension caps how long a single message is kept invisible by heartbeats.
+	DefaultMaxVisibilityExtension = time.Hour
+)
+
+// Limits imposed by the SQS ReceiveMessage API.
+const (
+	maxNumberOfMessagesLimit = 10
+	maxWaitTimeSecondsLimit  = 20
+	maxDeleteBatchSizeLimit  = 10
+	// maxVisibilityTimeout is the SQS upper bound for a message visibility timeout.
+	maxVisibilityTimeout = 12 * time.Hour
+)
+
+// Environment variables read by ConfigFromEnv.
+const (
+	EnvMaxNumberOfMessages = "SQS_MAX_NUMBER_OF_MESSAGES"
+	EnvWaitTimeSeconds     = "SQS_WAIT_TIME_SECONDS"
+	EnvDeleteTimeout       = "SQS_DELETE_TIMEOUT"
+	EnvProcessingTimeout   = "SQS_PROCESSING_TIMEOUT"
+	EnvRetrySleep          = "SQS_RETRY_SLEEP"
+	EnvMaxConcurrency      = "SQS_MAX_CONCURRENCY"
+	EnvBatchDeletes        = "SQS_BATCH_DELETES"
+	EnvDeleteBatchSize     = "SQS_DELETE_BATCH_SIZE"
+	EnvDeleteBatchWindow   = "SQS_DELETE_BATCH_WINDOW"
+	EnvDeleteMaxRetries    = "SQS_DELETE_MAX_RETRIES"
+	EnvHeartbeatInterval   = "SQS_HEARTBEAT_INTERVAL"
+	EnvVisibilityExtension = "SQS_VISIBILITY_EXTENSION"
+	EnvMaxVisibilityExt    = "SQS_MAX_VISIBILITY_EXTENSION"
+	EnvFIFO                = "SQS_FIFO"
+	EnvStrictPriority      = "SQS_STRICT_PRIORITY"
+)
+
+// Config holds the tunable parameters of a Consumer.
+// Use DefaultConfig as a starting point and Validate before use.
+type Config struct {
+	// MaxNumberOfMessages is the maximum number of messages requested per ReceiveMessage call (1-10).
+	MaxNumberOfMessages int32
+	// WaitTimeSeconds is the long polling duration of a ReceiveMessage call (0-20).
+	WaitTimeSeconds int32
+	// DeleteTimeout bounds a single DeleteMessage call.
+	DeleteTimeout time.Duration
+	// ProcessingTimeout bounds the routing and handling of a single message.
+	ProcessingTimeout time.Duration
+	// RetrySleep is the pause after a failed ReceiveMessage call before polling again.
+	RetrySleep time.Duration
+	// MaxConcurrency is the size of the worker pool, i.e. the maximum number of in-flight messages.
+	// The poller never receives more messages than there are free workers.
+	MaxConcurrency int
+	// BatchDeletes enables deleting messages through DeleteMessageBatch instead of one DeleteMessage call per message.
+	BatchDeletes bool
+	// DeleteBatchSize is the number of receipt handles that triggers an immediate batch flush (1-10).
+	DeleteBatchSize int
+	// DeleteBatchWindow is the maximum time a receipt handle waits for its batch to fill up.
+	// FIFO queues do not wait for the window, as the next message of a group waits for the delete.
+	DeleteBatchWindow time.Duration
+	// DeleteMaxRetries is the number of retries for batch entries that failed for a non-sender reason.
+	DeleteMaxRetries int
+	// HeartbeatInterval is how often the visibility of an in-flight message is extended while its handler runs.
+	// Zero disables the heartbeat.
+	HeartbeatInterval time.Duration
+	// VisibilityExtension is the visibility timeout set by each heartbeat; it must exceed HeartbeatInterval.
+	VisibilityExtension time.Duration
+	// MaxVisibilityExtension bounds the total time a message is kept invisible by heartbeats.
+	MaxVisibilityExtension time.Duration
+	// FIFO processes messages sharing a MessageGroupId strictly in order, one at a time, while
+	// different groups run in parallel. It is enabled automatically for queue URLs ending in ".fifo".
+	FIFO bool
+	// StrictPriority makes a multi-queue consumer poll its queues in the given order every cycle, so a queue is
+	// only read when all queues before it are empty. Otherwise queues are polled by weighted round-robin.
+	StrictPriority bool
+}
+
+// DefaultConfig returns the configuration used when no options are given.
+func DefaultConfig() Config {
+	return Config{
+		MaxNumberOfMessages:    DefaultMaxNumberOfMessages,
+		WaitTimeSeconds:        DefaultWaitTimeSeconds,
+		DeleteTimeout:          DefaultDeleteTimeout,
+		ProcessingTimeout:      DefaultProcessingTimeout,
+		RetrySleep:             DefaultRetrySleep,
+		MaxConcurrency:         DefaultMaxConcurrency,
+		DeleteBatchSize:        DefaultDeleteBatchSize,
+		DeleteBatchWindow:      DefaultDeleteBatchWindow,
+		DeleteMaxRetries:       DefaultDeleteMaxRetries,
+		VisibilityExtension:    DefaultVisibilityExtension,
+		MaxVisibilityExtension: DefaultMaxVisibilityExtension,
+	}
+}
+
+// Validate reports whether the configuration can be used against SQS.
+func (c Config) Validate() error {
+	if c.MaxNumberOfMessages < 1 || c.MaxNumberOfMessages > maxNumberOfMessagesLimit {
+		return fmt.Errorf("%w: MaxNumberOfMessages must be between 1 and %d, got %d", ErrInvalidConfig, maxNumberOfMessagesLimit, c.MaxNumberOfMessages)
+	}
+	if c.WaitTimeSeconds < 0 || c.WaitTimeSeconds > maxWaitTimeSecondsLimit {
+		return fmt.Errorf("%w: WaitTimeSeconds must be between 0 and %d, got %d", ErrInvalidConfig, maxWaitTimeSecondsLimit, c.WaitTimeSeconds)
+	}
+	if c.DeleteTimeout <= 0 {
+		return fmt.Errorf("%w: DeleteTimeout must be positive, got %s", ErrInvalidConfig, c.DeleteTimeout)
+	}
+	if c.ProcessingTimeout <= 0 {
+		return fmt.Errorf("%w: ProcessingTimeout must be positive, got %s", ErrInvalidConfig, c.ProcessingTimeout)
+	}
+	if c.RetrySleep < 0 {
+		return fmt.Errorf("%w: RetrySleep must not be negative, got %s", ErrInvalidConfig, c.RetrySleep)
+	}
+	if c.MaxConcurrency < 1 {
+		return fmt.Errorf("%w: MaxConcurrency must be at least 1, got %d", ErrInvalidConfig, c.MaxConcurrency)
+	}
+	if c.BatchDeletes {
+		if c.DeleteBatchSize < 1 || c.DeleteBatchSize > maxDeleteBatchSizeLimit {
+			return fmt.Errorf("%w: DeleteBatchSize must be between 1 and %d, got %d", ErrInvalidConfig, maxDeleteBatchSizeLimit, c.DeleteBatchSize)
+		}
+		if c.DeleteBatchWindow <= 0 {
+			return fmt.Errorf("%w: DeleteBatchWindow must be positive, got %s", ErrInvalidConfig, c.DeleteBatchWindow)
+		}
+		if c.DeleteMaxRetries < 0 {
+			return fmt.Errorf("%w: DeleteMaxRetries must not be negative, got %d", ErrInvalidConfig, c.DeleteMaxRetries)
+		}
+	}
+	if c.HeartbeatInterval < 0 {
+		return fmt.Errorf("%w: HeartbeatInterval must not be negative, got %s", ErrInvalidConfig, c.HeartbeatInterval)
+	}
+	if c.HeartbeatInterval > 0 {
+		if c.VisibilityExtension <= c.HeartbeatInterval || c.VisibilityExtension > maxVisibilityTimeout {
+			return fmt.Errorf("%w: VisibilityExtension must be greater than HeartbeatInterval (%s) and at most %s, got %s",
+				ErrInvalidConfig, c.HeartbeatInterval, maxVisibilityTimeout, c.VisibilityExtension)
+		}
+		if c.MaxVisibilityExtension <= 0 || c.MaxVisibilityExtension > maxVisibilityTimeout {
+			return fmt.Errorf("%w: MaxVisibilityExtension must be positive and at most %s, got %s", ErrInvalidConfig, maxVisibilityTimeout, c.MaxVisibilityExtension)
+		}
+	}
+	return nil
+}
+
+// ConfigFromEnv returns DefaultConfig overridden by any of the SQS_* environment variables that are set.
+// Integer variables are parsed as base-10 integers (strconv.ParseInt with bitSize 32 for int32 fields,
+// strconv.Atoi otherwise), booleans with strconv.ParseBool and durations with time.ParseDuration.
+// The resulting configuration is validated.
+func ConfigFromEnv() (Config, error) {
+	cfg := DefaultConfig()
+	if err := envInt32(EnvMaxNumberOfMessages, &cfg.MaxNumberOfMessages); err != nil {
+		return Config{}, err
+	}
+	if err := envInt32(EnvWaitTimeSeconds, &cfg.WaitTimeSeconds); err != nil {
+		return Config{}, err
+	}
+	if err := envDuration(EnvDeleteTimeout, &cfg.DeleteTimeout); err != nil {
+		return Config{}, err
+	}
+	if err := envDuration(EnvProcessingTimeout, &cfg.ProcessingTimeout); err != nil {
+		return Config{}, err
+	}
+	if err := envDuration(EnvRetrySleep, &cfg.RetrySleep); err != nil {
+		return Config{}, err
+	}
+	if err := envInt(EnvMaxConcurrency, &cfg.MaxConcurrency); err != nil {
+		return Config{}, err
+	}
+	if err := envBool(EnvBatchDeletes, &cfg.BatchDeletes); err != nil {
+		return Config{}, err
+	}
+	if err := envInt(EnvDeleteBatchSize, &cfg.DeleteBatchSize); err != nil {
+		return Config{}, err
+	}
+	if err := envDuration(EnvDeleteBatchWindow, &cfg.DeleteBatchWindow); err != nil {
+		return Config{}, err
+	}
+	if err := envInt(EnvDeleteMaxRetries, &cfg.DeleteMaxRetries); err != nil {
+		return Config{}, err
+	}
+	if err := envDuration(EnvHeartbeatInterval, &cfg.HeartbeatInterval); err != nil {
+		return Config{}, err
+	}
+	if err := envDuration(EnvVisibilityExtension, &cfg.VisibilityExtension); err != nil {
+		return Config{}, err
+	}
+	if err := envDuration(EnvMaxVisibilityExt, &cfg.MaxVisibilityExtension); err != nil {
+		return Config{}, err
+	}
+	if err := envBool(EnvFIFO, &cfg.FIFO); err != nil {
+		return Config{}, err
+	}
+	if err := envBool(EnvStrictPriority, &cfg.StrictPriority); err != nil {
+		return Config{}, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// envInt32 overwrites dst with the value of the named environment variable when it is set.
+func envInt32(name string, dst *int32) error {
+	v, ok := os.LookupEnv(name)
+	if !ok || v == "" {
+		return nil
+	}
+	n, err := strconv.ParseInt(v, 10, 32)
+	if err != nil {
+		return fmt.Errorf("%w: %s=%q: %v", ErrInvalidConfig, name, v, err)
+	}
+	*dst = int32(n)
+	return nil
+}
+
+// envInt overwrites dst with the value of the named environment variable when it is set.
+func envInt(name string, dst *int) error {
+	v, ok := os.LookupEnv(name)
+	if !ok || v == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("%w: %s=%q: %v", ErrInvalidConfig, name, v, err)
+	}
+	*dst = n
+	return nil
+}
+
+// envDuration overwrites dst with the value of the named environment variable when it is set.
+func envDuration(name string, dst *time.Duration) error {
+	v, ok := os.LookupEnv(name)
+	if !ok || v == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return fmt.Errorf("%w: %s=%q: %v", ErrInvalidConfig, name, v, err)
+	}
+	*dst = d
+	return nil
+}
+
+// envBool overwrites dst with the value of the named environment variable when it is set.
+func envBool(name string, dst *bool) error {
+	v, ok := os.LookupEnv(name)
+	if !ok || v == "" {
+		return nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return fmt.Errorf("%w: %s=%q: %v", ErrInvalidConfig, name, v, err)
+	}
+	*dst = b
+	return nil
+}
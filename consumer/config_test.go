@@ -0,0 +1,138 @@
+package consumer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	sqsrouter "github.com/hatsunemiku3939/sqsrouter"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(c *Config)
+		wantErr bool
+	}{
+		{name: "defaults are valid", mutate: func(c *Config) {}},
+		{name: "max messages at upper limit", mutate: func(c *Config) { c.MaxNumberOfMessages = 10 }},
+		{name: "short polling", mutate: func(c *Config) { c.WaitTimeSeconds = 0 }},
+		{name: "zero max messages", mutate: func(c *Config) { c.MaxNumberOfMessages = 0 }, wantErr: true},
+		{name: "max messages above limit", mutate: func(c *Config) { c.MaxNumberOfMessages = 11 }, wantErr: true},
+		{name: "wait time above limit", mutate: func(c *Config) { c.WaitTimeSeconds = 21 }, wantErr: true},
+		{name: "negative wait time", mutate: func(c *Config) { c.WaitTimeSeconds = -1 }, wantErr: true},
+		{name: "zero delete timeout", mutate: func(c *Config) { c.DeleteTimeout = 0 }, wantErr: true},
+		{name: "zero processing timeout", mutate: func(c *Config) { c.ProcessingTimeout = 0 }, wantErr: true},
+		{name: "negative retry sleep", mutate: func(c *Config) { c.RetrySleep = -time.Second }, wantErr: true},
+		{name: "zero concurrency", mutate: func(c *Config) { c.MaxConcurrency = 0 }, wantErr: true},
+		{name: "batch size ignored when batching is off", mutate: func(c *Config) { c.DeleteBatchSize = 0 }},
+		{name: "batch size above limit", mutate: func(c *Config) { c.BatchDeletes = true; c.DeleteBatchSize = 11 }, wantErr: true},
+		{name: "zero batch window", mutate: func(c *Config) { c.BatchDeletes = true; c.DeleteBatchWindow = 0 }, wantErr: true},
+		{name: "negative delete retries", mutate: func(c *Config) { c.BatchDeletes = true; c.DeleteMaxRetries = -1 }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			tt.mutate(&cfg)
+			err := cfg.Validate()
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidConfig)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Run("uses defaults when nothing is set", func(t *testing.T) {
+		cfg, err := ConfigFromEnv()
+		require.NoError(t, err)
+		assert.Equal(t, DefaultConfig(), cfg)
+	})
+
+	t.Run("overrides fields from environment", func(t *testing.T) {
+		t.Setenv(EnvMaxNumberOfMessages, "10")
+		t.Setenv(EnvWaitTimeSeconds, "20")
+		t.Setenv(EnvDeleteTimeout, "3s")
+		t.Setenv(EnvProcessingTimeout, "1m")
+		t.Setenv(EnvRetrySleep, "500ms")
+		t.Setenv(EnvMaxConcurrency, "32")
+		t.Setenv(EnvBatchDeletes, "true")
+		t.Setenv(EnvDeleteBatchSize, "5")
+		t.Setenv(EnvDeleteBatchWindow, "250ms")
+		t.Setenv(EnvDeleteMaxRetries, "1")
+		t.Setenv(EnvFIFO, "true")
+		t.Setenv(EnvStrictPriority, "true")
+
+		cfg, err := ConfigFromEnv()
+		require.NoError(t, err)
+		assert.Equal(t, int32(10), cfg.MaxNumberOfMessages)
+		assert.Equal(t, int32(20), cfg.WaitTimeSeconds)
+		assert.Equal(t, 3*time.Second, cfg.DeleteTimeout)
+		assert.Equal(t, time.Minute, cfg.ProcessingTimeout)
+		assert.Equal(t, 500*time.Millisecond, cfg.RetrySleep)
+		assert.Equal(t, 32, cfg.MaxConcurrency)
+		assert.True(t, cfg.BatchDeletes)
+		assert.Equal(t, 5, cfg.DeleteBatchSize)
+		assert.Equal(t, 250*time.Millisecond, cfg.DeleteBatchWindow)
+		assert.Equal(t, 1, cfg.DeleteMaxRetries)
+		assert.True(t, cfg.FIFO)
+		assert.True(t, cfg.StrictPriority)
+	})
+
+	t.Run("rejects unparsable values", func(t *testing.T) {
+		t.Setenv(EnvProcessingTimeout, "thirty")
+		_, err := ConfigFromEnv()
+		assert.ErrorIs(t, err, ErrInvalidConfig)
+	})
+
+	t.Run("rejects out of range values", func(t *testing.T) {
+		t.Setenv(EnvWaitTimeSeconds, "25")
+		_, err := ConfigFromEnv()
+		assert.ErrorIs(t, err, ErrInvalidConfig)
+	})
+}
+
+func TestNewConsumer_Options(t *testing.T) {
+	router, err := sqsrouter.NewRouter(sqsrouter.EnvelopeSchema)
+	require.NoError(t, err)
+
+	t.Run("applies options on top of defaults", func(t *testing.T) {
+		c, err := NewConsumer(new(MockSQSClient), "q", router,
+			WithMaxNumberOfMessages(10),
+			WithWaitTimeSeconds(0),
+			WithDeleteTimeout(time.Second),
+			WithProcessingTimeout(time.Minute),
+			WithRetrySleep(time.Millisecond),
+			WithMaxConcurrency(4),
+		)
+		require.NoError(t, err)
+		want := DefaultConfig()
+		want.MaxNumberOfMessages = 10
+		want.WaitTimeSeconds = 0
+		want.DeleteTimeout = time.Second
+		want.ProcessingTimeout = time.Minute
+		want.RetrySleep = time.Millisecond
+		want.MaxConcurrency = 4
+		assert.Equal(t, want, c.cfg)
+	})
+
+	t.Run("later options override WithConfig", func(t *testing.T) {
+		base := DefaultConfig()
+		base.WaitTimeSeconds = 1
+		c, err := NewConsumer(new(MockSQSClient), "q", router, WithConfig(base), WithMaxNumberOfMessages(2))
+		require.NoError(t, err)
+		assert.Equal(t, int32(1), c.cfg.WaitTimeSeconds)
+		assert.Equal(t, int32(2), c.cfg.MaxNumberOfMessages)
+	})
+
+	t.Run("rejects invalid configuration", func(t *testing.T) {
+		c, err := NewConsumer(new(MockSQSClient), "q", router, WithMaxNumberOfMessages(11))
+		assert.ErrorIs(t, err, ErrInvalidConfig)
+		assert.Nil(t, c)
+	})
+}
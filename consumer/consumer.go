@@ -3,7 +3,7 @@ package consumer
 import (
 	"context"
 	"errors"
-	"log"
+	"log/slog"
 	"sync"
 	"time"
 
@@ -13,141 +13,283 @@ import (
 	"github.com/hatsunemiku3939/sqsrouter"
 )
 
-// --- SQS Consumer Configuration ---
-const (
-	// maxMessages defines the maximum number of messages to retrieve in one SQS API call.
-	maxMessages = 5
-	// waitTimeSeconds enables SQS Long Polling, reducing cost and empty responses.
-	waitTimeSeconds = 10
-	// deleteTimeout sets a client-side timeout for the DeleteMessage API call.
-	deleteTimeout = 5 * time.Second
-	// processingTimeout sets a deadline for processing a single message.
-	// This should be less than the container's graceful shutdown period (e.g., terminationGracePeriodSeconds in K8s).
-	processingTimeout = 30 * time.Second
-	// retrySleep defines the duration to wait before retrying after a failed SQS API call.
-	retrySleep = 2 * time.Second
-)
-
 // SQSClient defines the interface for SQS operations needed by the Consumer.
 // This allows for easier testing by mocking the SQS client.
 type SQSClient interface {
 	ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error)
 	DeleteMessage(ctx context.Context, params *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error)
+	DeleteMessageBatch(ctx context.Context, params *sqs.DeleteMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageBatchOutput, error)
+	ChangeMessageVisibility(ctx context.Context, params *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error)
 }
 
 // Consumer encapsulates the SQS polling and message processing logic.
+// A Consumer polls one or more queues and processes their messages with a single worker pool and router.
 type Consumer struct {
-	client   SQSClient
-	queueURL string
-	router   *sqsrouter.Router
+	client  SQSClient
+	router  *sqsrouter.Router
+	cfg     Config
+	queues  []*queue
+	logger  *slog.Logger
+	metrics sqsrouter.Metrics
 }
 
 // NewConsumer creates a new SQS message consumer.
-func NewConsumer(client SQSClient, queueURL string, router *sqsrouter.Router) *Consumer {
-	return &Consumer{client: client, queueURL: queueURL, router: router}
+// Without options the consumer uses DefaultConfig. The resulting configuration is validated.
+func NewConsumer(client SQSClient, queueURL string, router *sqsrouter.Router, opts ...ConsumerOption) (*Consumer, error) {
+	return NewMultiQueueConsumer(client, []Queue{{URL: queueURL}}, router, opts...)
+}
+
+// NewMultiQueueConsumer creates a consumer that polls several queues and shares one worker pool between them.
+// By default the queues are polled by weighted round-robin; with StrictPriority a queue is only polled when
+// all queues before it returned no messages or failed. Deletes and visibility changes target the queue a message
+// came from.
+//
+// Only the last queue of a poll cycle long-polls, for up to WaitTimeSeconds. While it waits, the earlier queues
+// are not polled, so with StrictPriority a new message on a high-priority queue can wait up to WaitTimeSeconds
+// behind an empty low-priority queue. Lower WaitTimeSeconds to bound that latency at the cost of more receives.
+func NewMultiQueueConsumer(client SQSClient, queues []Queue, router *sqsrouter.Router, opts ...ConsumerOption) (*Consumer, error) {
+	if err := validateQueues(queues); err != nil {
+		return nil, err
+	}
+	c := &Consumer{client: client, router: router, cfg: DefaultConfig(), logger: slog.Default(), metrics: sqsrouter.NopMetrics{}}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if err := c.cfg.Validate(); err != nil {
+		return nil, err
+	}
+	for _, q := range queues {
+		qs := &queue{url: q.URL, weight: max(q.Weight, 1)}
+		// Ordering is the point of a FIFO queue, so it cannot be switched off for one.
+		qs.fifo = c.cfg.FIFO || isFIFOQueue(q.URL)
+		if qs.fifo {
+			qs.attemptID = aws.String(newReceiveAttemptID())
+		}
+		if c.cfg.BatchDeletes {
+			qs.batcher = newDeleteBatcher(client, q.URL, c.cfg)
+		}
+		c.queues = append(c.queues, qs)
+	}
+	return c, nil
 }
 
 // Start begins the consumer's polling loop. It blocks until the context is canceled.
 func (c *Consumer) Start(ctx context.Context) {
-	log.Printf("🚀 SQS consumer started. Polling queue: %s. Press Ctrl+C to shut down.", c.queueURL)
+	c.logger.LogAttrs(ctx, slog.LevelInfo, "consumer started", slog.String(sqsrouter.LogKeyQueue, c.queueURLs()))
 
 	var wg sync.WaitGroup
+	pool := newWorkerPool(c.cfg.MaxConcurrency)
 
+poll:
 	for {
 		// Before polling, check if a shutdown has been initiated.
 		if ctx.Err() != nil {
-			log.Println("INFO: Shutdown initiated, no longer polling for new messages.")
+			c.logger.LogAttrs(ctx, slog.LevelInfo, "shutdown initiated, no longer polling for new messages")
 			break
 		}
 
-		output, err := c.client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
-			QueueUrl:            aws.String(c.queueURL),
-			MaxNumberOfMessages: maxMessages,
-			WaitTimeSeconds:     waitTimeSeconds,
-		})
+		// Reserve workers before polling. This blocks while the pool is saturated and shrinks the
+		// batch size to the number of free workers, so received messages never wait for a worker
+		// while their visibility timeout is running.
+		slots := pool.acquire(ctx, int(c.cfg.MaxNumberOfMessages))
+		if slots == 0 {
+			c.logger.LogAttrs(ctx, slog.LevelInfo, "shutdown initiated, no longer polling for new messages")
+			break
+		}
 
-		if err != nil {
-			if errors.Is(err, context.Canceled) {
-				log.Println("INFO: Context canceled by shutdown signal. Stopping poller.")
-				break // Exit the loop cleanly.
+		// Poll the queues in order until one returns messages. Only the last queue long-polls,
+		// so a message on an earlier queue is not delayed by waiting on a later one.
+		order := c.pollOrder()
+		failed := false
+		for i, q := range order {
+			wait := int32(0)
+			if i == len(order)-1 {
+				wait = c.cfg.WaitTimeSeconds
 			}
-			log.Printf("ERROR: Failed to receive messages: %v. Retrying...", err)
-			time.Sleep(retrySleep) // Wait before retrying on other errors.
-			continue
-		}
+			output, err := c.client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+				QueueUrl:                    aws.String(q.url),
+				MaxNumberOfMessages:         int32(slots), //nolint:gosec // bounded by MaxNumberOfMessages
+				WaitTimeSeconds:             wait,
+				MessageSystemAttributeNames: systemAttributeNames,
+				MessageAttributeNames:       allMessageAttributes,
+				ReceiveRequestAttemptId:     q.attemptID,
+			})
 
-		if len(output.Messages) == 0 {
-			continue
-		}
+			if err != nil {
+				if errors.Is(err, context.Canceled) {
+					pool.release(slots)
+					c.logger.LogAttrs(ctx, slog.LevelInfo, "context canceled by shutdown signal, stopping poller")
+					break poll // Exit the loop cleanly.
+				}
+				c.metrics.ReceiveError(q.url)
+				c.logger.LogAttrs(ctx, slog.LevelError, "failed to receive messages, retrying",
+					slog.String(sqsrouter.LogKeyQueue, q.url),
+					slog.String(sqsrouter.LogKeyError, err.Error()),
+				)
+				// Move on to the next queue, so a failing queue does not keep the others from being polled.
+				failed = true
+				continue
+			}
+
+			// A FIFO receive that succeeded must not be replayed, so the next one gets a new attempt ID.
+			if q.fifo {
+				q.attemptID = aws.String(newReceiveAttemptID())
+			}
 
-		log.Printf("INFO: Received %d messages.", len(output.Messages))
-
-		for _, msg := range output.Messages {
-			m := msg // capture range variable
-			// process each message in its own goroutine
-			wg.Add(1)
-			go func(m sqstypes.Message) { //nolint:contextcheck
-				defer wg.Done()
-				msgCtx, cancelMsg := context.WithTimeout(context.Background(), processingTimeout)
-				defer cancelMsg()
-				c.processMessage(msgCtx, &m)
-			}(m)
+			if len(output.Messages) == 0 {
+				continue
+			}
+			c.metrics.MessagesReceived(q.url, len(output.Messages))
+
+			// Return the slots that were not filled by this batch.
+			pool.release(slots - len(output.Messages))
+			slots = 0
+			c.logger.LogAttrs(ctx, slog.LevelDebug, "received messages",
+				slog.String(sqsrouter.LogKeyQueue, q.url),
+				slog.Int(sqsrouter.LogKeyCount, len(output.Messages)),
+				slog.Int(sqsrouter.LogKeyInFlight, pool.inFlight()),
+			)
+			c.dispatch(q, output.Messages, pool, &wg)
+			break
 		}
+		if failed && slots > 0 {
+			time.Sleep(c.cfg.RetrySleep) // Wait before retrying when no queue returned messages.
+		}
+		pool.release(slots)
 	}
 
-	log.Println("INFO: Waiting for in-flight messages to be processed...")
+	c.logger.LogAttrs(ctx, slog.LevelInfo, "waiting for in-flight messages to be processed", slog.Int(sqsrouter.LogKeyInFlight, pool.inFlight()))
 	wg.Wait()
-	log.Println("✅ Graceful shutdown complete. All processed messages are handled.")
+	for _, q := range c.queues {
+		if q.batcher != nil {
+			// Flush any receipt handles still waiting for their batch window.
+			q.batcher.close()
+		}
+	}
+	c.logger.LogAttrs(ctx, slog.LevelInfo, "graceful shutdown complete")
+}
+
+// dispatch starts processing a received batch. Each message holds one reserved worker slot until it is handled.
+func (c *Consumer) dispatch(q *queue, msgs []sqstypes.Message, pool *workerPool, wg *sync.WaitGroup) {
+	if q.fifo {
+		c.dispatchGroups(q, msgs, pool, wg)
+		return
+	}
+
+	for _, msg := range msgs {
+		m := msg // capture range variable
+		// process each message in its own goroutine, holding one reserved worker slot
+		wg.Add(1)
+		go func(m sqstypes.Message) { //nolint:contextcheck
+			defer wg.Done()
+			defer pool.release(1)
+			c.processWithTimeout(q, &m)
+		}(m)
+	}
 }
 
 // processMessage routes, handles, and deletes a single SQS message.
-func (c *Consumer) processMessage(ctx context.Context, msg *sqstypes.Message) {
+// It reports whether the message was deleted from the queue.
+func (c *Consumer) processMessage(ctx context.Context, q *queue, msg *sqstypes.Message) (deleted bool) {
 	defer func() {
 		if rec := recover(); rec != nil {
-			log.Printf("ERROR: Panic recovered while processing a message: %v", rec)
+			c.logger.LogAttrs(ctx, slog.LevelError, "panic recovered while processing message",
+				slog.String(sqsrouter.LogKeyQueue, q.url),
+				slog.Any(sqsrouter.LogKeyError, rec),
+			)
+			deleted = false
 		}
 	}()
 
 	if msg.Body == nil {
-		log.Println("ERROR: Received message with empty body.")
-		return
+		c.logger.LogAttrs(ctx, slog.LevelError, "received message with empty body", slog.String(sqsrouter.LogKeyQueue, q.url))
+		return false
 	}
 
+	start := time.Now()
+
+	// Keep the message invisible while it is being handled. The heartbeat stops as soon as routing
+	// has decided the message's fate, so no extension races with the delete or release below.
+	stopHeartbeat := c.startHeartbeat(q, msg)
+	defer stopHeartbeat()
+
+	// Expose the SQS delivery attributes to middlewares, failure policies and handlers.
+	ctx = sqsrouter.WithSQSAttributes(ctx, sqsAttributes(q.url, msg))
+
 	routed := c.router.Route(ctx, []byte(*msg.Body))
+	stopHeartbeat()
 
-	if routed.HandlerResult.Error != nil {
-		log.Printf("❌ FAILURE [%s] %s v%s (%s): %v",
-			routed.Timestamp,
-			routed.MessageType,
-			routed.MessageVersion,
-			routed.MessageID,
-			routed.HandlerResult.Error,
-		)
-	} else {
-		log.Printf("✅ SUCCESS [%s] %s v%s (%s)",
-			routed.Timestamp,
-			routed.MessageType,
-			routed.MessageVersion,
-			routed.MessageID,
-		)
+	attrs := []slog.Attr{
+		slog.String(sqsrouter.LogKeyQueue, q.url),
+		slog.String(sqsrouter.LogKeyMessageType, routed.MessageType),
+		slog.String(sqsrouter.LogKeyMessageVersion, routed.MessageVersion),
+		slog.String(sqsrouter.LogKeyMessageID, routed.MessageID),
+		slog.String(sqsrouter.LogKeyFailureKind, routed.FailureKind.String()),
 	}
 
+	var retryAfter time.Duration
 	if routed.HandlerResult.ShouldDelete {
-		deleteCtx, cancelDelete := context.WithTimeout(context.Background(), deleteTimeout)
-		defer cancelDelete()
-
+		if err := c.deleteMessage(q, msg); err != nil { //nolint:contextcheck
+			c.metrics.DeleteError(q.url)
+			c.logger.LogAttrs(ctx, slog.LevelError, "failed to delete message", append(attrs, slog.String(sqsrouter.LogKeyError, err.Error()))...)
+		} else {
+			deleted = true
+			c.metrics.MessageDeleted(q.url)
+		}
+	} else if delay := routed.HandlerResult.RetryAfter; delay > 0 {
+		if delay > maxVisibilityTimeout {
+			delay = maxVisibilityTimeout
+		}
 		//nolint:contextcheck
-		_, err := c.client.DeleteMessage(deleteCtx, &sqs.DeleteMessageInput{
-			QueueUrl:      aws.String(c.queueURL),
-			ReceiptHandle: msg.ReceiptHandle,
-		})
-
-		if err != nil {
-			log.Printf("ERROR: Failed to delete message ID %s: %v", routed.MessageID, err)
+		if err := c.changeVisibility(q, msg, delay); err != nil {
+			c.logger.LogAttrs(ctx, slog.LevelError, "failed to set retry delay", append(attrs, slog.String(sqsrouter.LogKeyError, err.Error()))...)
 		} else {
-			log.Printf("🗑️  Deleted message ID %s", routed.MessageID)
+			retryAfter = delay
 		}
-	} else {
-		log.Printf("🔁 RETRYING message ID %s later (visibility timeout will expire).", routed.MessageID)
 	}
+
+	if !routed.HandlerResult.ShouldDelete {
+		c.metrics.MessageRetried(q.url, retryAfter)
+	}
+
+	// One record per message summarizes its outcome. Retained messages without a retry delay
+	// become visible again when the queue's visibility timeout expires.
+	attrs = append(attrs,
+		slog.Duration(sqsrouter.LogKeyDuration, time.Since(start)),
+		slog.Bool(sqsrouter.LogKeyDeleted, deleted),
+	)
+	if retryAfter > 0 {
+		attrs = append(attrs, slog.Duration(sqsrouter.LogKeyRetryAfter, retryAfter))
+	}
+	if routed.Variant != "" {
+		attrs = append(attrs, slog.String(sqsrouter.LogKeyVariant, routed.Variant))
+	}
+	level := slog.LevelInfo
+	if routed.HandlerResult.Error != nil {
+		level = slog.LevelWarn
+		attrs = append(attrs, slog.String(sqsrouter.LogKeyError, routed.HandlerResult.Error.Error()))
+	}
+	c.logger.LogAttrs(ctx, level, "message processed", attrs...)
+	return deleted
+}
+
+// deleteMessage removes a handled message from the queue, through the batcher when batching is enabled.
+// It uses its own timeout so that deletes still complete when the processing context has expired.
+// FIFO deletes skip the batch window, as the next message of the group waits for them.
+func (c *Consumer) deleteMessage(q *queue, msg *sqstypes.Message) error {
+	if q.batcher != nil {
+		if q.fifo {
+			return q.batcher.deleteNow(aws.ToString(msg.ReceiptHandle))
+		}
+		return q.batcher.delete(aws.ToString(msg.ReceiptHandle))
+	}
+
+	deleteCtx, cancelDelete := context.WithTimeout(context.Background(), c.cfg.DeleteTimeout)
+	defer cancelDelete()
+
+	_, err := c.client.DeleteMessage(deleteCtx, &sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(q.url),
+		ReceiptHandle: msg.ReceiptHandle,
+	})
+	return err
 }
@@ -4,9 +4,12 @@ import (
     "context"
     "errors"
     "fmt"
+    "slices"
+    "sync"
     "testing"
     "time"
 
+    "github.com/aws/aws-sdk-go-v2/aws"
     "github.com/aws/aws-sdk-go-v2/service/sqs"
     "github.com/aws/aws-sdk-go-v2/service/sqs/types"
     "github.com/stretchr/testify/assert"
@@ -18,9 +21,59 @@ import (
 
 // --- Mock SQSClient ---
 
-type MockSQSClient struct{ mock.Mock }
+// MockSQSClient is a testify mock of the SQS API that also records every call.
+// Tests that script SQS instead of setting expectations use the hooks: a method with a hook calls it,
+// and with Succeed set a method without a hook returns an empty output, instead of the expectations.
+// Hooks may be called concurrently.
+type MockSQSClient struct {
+    mock.Mock
+
+    ReceiveFunc     func(params *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error)
+    DeleteBatchFunc func(params *sqs.DeleteMessageBatchInput) (*sqs.DeleteMessageBatchOutput, error)
+    Succeed         bool
+
+    mu    sync.Mutex
+    calls []sqsCall
+}
+
+// sqsCall is a call recorded by MockSQSClient.
+type sqsCall struct {
+    Method   string
+    QueueURL string
+    // ReceiptHandles of DeleteMessage, DeleteMessageBatch and ChangeMessageVisibility calls.
+    ReceiptHandles    []string
+    MaxMessages       int32
+    WaitTimeSeconds   int32
+    VisibilityTimeout int32
+}
+
+func (m *MockSQSClient) record(c sqsCall) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    m.calls = append(m.calls, c)
+}
+
+// recorded returns the calls of the given methods in call order.
+func (m *MockSQSClient) recorded(methods ...string) []sqsCall {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    var calls []sqsCall
+    for _, c := range m.calls {
+        if slices.Contains(methods, c.Method) {
+            calls = append(calls, c)
+        }
+    }
+    return calls
+}
 
 func (m *MockSQSClient) ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+    m.record(sqsCall{Method: "ReceiveMessage", QueueURL: aws.ToString(params.QueueUrl), MaxMessages: params.MaxNumberOfMessages, WaitTimeSeconds: params.WaitTimeSeconds})
+    if m.ReceiveFunc != nil {
+        return m.ReceiveFunc(params)
+    }
+    if m.Succeed {
+        return &sqs.ReceiveMessageOutput{}, nil
+    }
     args := m.Called(ctx, params)
     if args.Get(0) == nil {
         return nil, args.Error(1)
@@ -29,6 +82,10 @@ func (m *MockSQSClient) ReceiveMessage(ctx context.Context, params *sqs.ReceiveM
 }
 
 func (m *MockSQSClient) DeleteMessage(ctx context.Context, params *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+    m.record(sqsCall{Method: "DeleteMessage", QueueURL: aws.ToString(params.QueueUrl), ReceiptHandles: []string{aws.ToString(params.ReceiptHandle)}})
+    if m.Succeed {
+        return &sqs.DeleteMessageOutput{}, nil
+    }
     args := m.Called(ctx, params)
     if args.Get(0) == nil {
         return nil, args.Error(1)
@@ -36,6 +93,37 @@ func (m *MockSQSClient) DeleteMessage(ctx context.Context, params *sqs.DeleteMes
     return args.Get(0).(*sqs.DeleteMessageOutput), args.Error(1)
 }
 
+func (m *MockSQSClient) DeleteMessageBatch(ctx context.Context, params *sqs.DeleteMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageBatchOutput, error) {
+    handles := make([]string, len(params.Entries))
+    for i, e := range params.Entries {
+        handles[i] = aws.ToString(e.ReceiptHandle)
+    }
+    m.record(sqsCall{Method: "DeleteMessageBatch", QueueURL: aws.ToString(params.QueueUrl), ReceiptHandles: handles})
+    if m.DeleteBatchFunc != nil {
+        return m.DeleteBatchFunc(params)
+    }
+    if m.Succeed {
+        return &sqs.DeleteMessageBatchOutput{}, nil
+    }
+    args := m.Called(ctx, params)
+    if args.Get(0) == nil {
+        return nil, args.Error(1)
+    }
+    return args.Get(0).(*sqs.DeleteMessageBatchOutput), args.Error(1)
+}
+
+func (m *MockSQSClient) ChangeMessageVisibility(ctx context.Context, params *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error) {
+    m.record(sqsCall{Method: "ChangeMessageVisibility", QueueURL: aws.ToString(params.QueueUrl), ReceiptHandles: []string{aws.ToString(params.ReceiptHandle)}, VisibilityTimeout: params.VisibilityTimeout})
+    if m.Succeed {
+        return &sqs.ChangeMessageVisibilityOutput{}, nil
+    }
+    args := m.Called(ctx, params)
+    if args.Get(0) == nil {
+        return nil, args.Error(1)
+    }
+    return args.Get(0).(*sqs.ChangeMessageVisibilityOutput), args.Error(1)
+}
+
 func createSQSMessage(body, receiptHandle string) types.Message {
     return types.Message{Body: &body, ReceiptHandle: &receiptHandle}
 }
@@ -44,10 +132,11 @@ func TestNewConsumer(t *testing.T) {
     mockClient := new(MockSQSClient)
     router, err := sqsrouter.NewRouter(sqsrouter.EnvelopeSchema)
     require.NoError(t, err)
-    c := NewConsumer(mockClient, "test-queue-url", router)
+    c, err := NewConsumer(mockClient, "test-queue-url", router)
+    require.NoError(t, err)
 
     assert.NotNil(t, c)
-    assert.Equal(t, "test-queue-url", c.queueURL)
+    assert.Equal(t, "test-queue-url", c.queues[0].url)
     assert.Equal(t, mockClient, c.client)
     assert.Equal(t, router, c.router)
 }
@@ -108,7 +197,8 @@ func TestConsumer_processMessage(t *testing.T) {
             msgType, msgVersion := "test.event", "1.0"
             router.Register(msgType, msgVersion, tt.handler)
 
-            c := NewConsumer(mockClient, queueURL, router)
+            c, err := NewConsumer(mockClient, queueURL, router)
+            require.NoError(t, err)
 
             msgBody := fmt.Sprintf(`{
                 "schemaVersion": "1.0", "messageType": "%s", "messageVersion": "%s",
@@ -125,7 +215,7 @@ func TestConsumer_processMessage(t *testing.T) {
                 }
             }
 
-            c.processMessage(context.Background(), &sqsMsg)
+            c.processMessage(context.Background(), c.queues[0], &sqsMsg)
 
             mockClient.AssertExpectations(t)
             if !tt.expectDeleteCall {
@@ -138,15 +228,41 @@ func TestConsumer_processMessage(t *testing.T) {
         mockClient := new(MockSQSClient)
         router, err := sqsrouter.NewRouter(sqsrouter.EnvelopeSchema)
         require.NoError(t, err)
-        c := NewConsumer(mockClient, queueURL, router)
+        c, err := NewConsumer(mockClient, queueURL, router)
+        require.NoError(t, err)
 
         sqsMsg := types.Message{Body: nil, ReceiptHandle: new(string)}
-        c.processMessage(context.Background(), &sqsMsg)
+        c.processMessage(context.Background(), c.queues[0], &sqsMsg)
         // No client calls should be made
         mockClient.AssertNotCalled(t, "DeleteMessage")
     })
 }
 
+func TestConsumer_processMessage_RetryAfter(t *testing.T) {
+    mockClient := new(MockSQSClient)
+    router, err := sqsrouter.NewRouter(sqsrouter.EnvelopeSchema, sqsrouter.WithFailurePolicy(sqsrouter.ExponentialBackoffPolicy{Base: 10 * time.Second}))
+    require.NoError(t, err)
+    router.Register("test.event", "1.0", func(ctx context.Context, msg []byte, meta []byte) sqsrouter.HandlerResult {
+        return sqsrouter.HandlerResult{ShouldDelete: false, Error: errors.New("transient error")}
+    })
+    c, err := NewConsumer(mockClient, "test-queue", router)
+    require.NoError(t, err)
+
+    msgBody := `{"schemaVersion":"1.0","messageType":"test.event","messageVersion":"1.0","message":{},"metadata":{"messageId":"msg-1"}}`
+    sqsMsg := createSQSMessage(msgBody, "receipt-1")
+    sqsMsg.Attributes = map[string]string{"ApproximateReceiveCount": "3"}
+
+    // Third receive: 10s * 2^2 = 40s.
+    mockClient.On("ChangeMessageVisibility", mock.Anything, mock.MatchedBy(func(in *sqs.ChangeMessageVisibilityInput) bool {
+        return *in.ReceiptHandle == "receipt-1" && in.VisibilityTimeout == 40
+    })).Return(&sqs.ChangeMessageVisibilityOutput{}, nil).Once()
+
+    c.processMessage(context.Background(), c.queues[0], &sqsMsg)
+
+    mockClient.AssertExpectations(t)
+    mockClient.AssertNotCalled(t, "DeleteMessage")
+}
+
 func TestConsumer_Start(t *testing.T) {
     queueURL := "test-queue"
     mockClient := new(MockSQSClient)
@@ -159,7 +275,8 @@ func TestConsumer_Start(t *testing.T) {
         return sqsrouter.HandlerResult{ShouldDelete: true}
     })
 
-    c := NewConsumer(mockClient, queueURL, router)
+    c, err := NewConsumer(mockClient, queueURL, router)
+    require.NoError(t, err)
 
     t.Run("receives and deletes message successfully", func(t *testing.T) {
         ctx, cancel := context.WithCancel(context.Background())
@@ -183,7 +300,8 @@ func TestConsumer_Start(t *testing.T) {
 
     t.Run("handles receive message error gracefully", func(t *testing.T) {
         mockClient := new(MockSQSClient) // Reset mock for this test
-        c := NewConsumer(mockClient, queueURL, router)
+        c, err := NewConsumer(mockClient, queueURL, router)
+        require.NoError(t, err)
         // The consumer sleeps for 2s on error, so context must be longer.
         ctx, cancel := context.WithTimeout(context.Background(), 2500*time.Millisecond)
         defer cancel()
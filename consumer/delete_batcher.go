@@ -0,0 +1,197 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// deleteRetryBackoff is the base pause between retries of failed batch entries.
+// The n-th retry waits n times this duration.
+const deleteRetryBackoff = 100 * time.Millisecond
+
+// deleteRequest is a single receipt handle waiting to be deleted as part of a batch.
+type deleteRequest struct {
+	receiptHandle string
+	err           error
+	done          chan error
+}
+
+// deleteBatcher accumulates receipt handles and deletes them with DeleteMessageBatch.
+// A batch is flushed when it reaches size entries or when window has elapsed since its first entry.
+// Callers block in delete until their own entry has been deleted or has finally failed.
+// It does not run a background goroutine; flushes happen on the caller or on a timer.
+type deleteBatcher struct {
+	client     SQSClient
+	queueURL   string
+	size       int
+	window     time.Duration
+	timeout    time.Duration
+	maxRetries int
+
+	mu      sync.Mutex
+	pending []*deleteRequest
+	timer   *time.Timer
+	closed  bool
+}
+
+// newDeleteBatcher creates a batcher for the given queue using the batching fields of cfg.
+func newDeleteBatcher(client SQSClient, queueURL string, cfg Config) *deleteBatcher {
+	return &deleteBatcher{
+		client:     client,
+		queueURL:   queueURL,
+		size:       cfg.DeleteBatchSize,
+		window:     cfg.DeleteBatchWindow,
+		timeout:    cfg.DeleteTimeout,
+		maxRetries: cfg.DeleteMaxRetries,
+	}
+}
+
+// delete enqueues the receipt handle and waits for the outcome of its batch.
+// After close, handles are deleted immediately in a batch of one.
+func (b *deleteBatcher) delete(receiptHandle string) error {
+	req := &deleteRequest{receiptHandle: receiptHandle, done: make(chan error, 1)}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		b.send([]*deleteRequest{req})
+		return <-req.done
+	}
+	b.pending = append(b.pending, req)
+	var batch []*deleteRequest
+	switch {
+	case len(b.pending) >= b.size:
+		batch = b.takeLocked()
+	case len(b.pending) == 1:
+		b.timer = time.AfterFunc(b.window, b.flush)
+	}
+	b.mu.Unlock()
+
+	if batch != nil {
+		b.send(batch)
+	}
+	return <-req.done
+}
+
+// deleteNow deletes the receipt handle without waiting for the window, together with any pending entries.
+// Pending entries never reach size, so the batch stays within the DeleteMessageBatch limit.
+func (b *deleteBatcher) deleteNow(receiptHandle string) error {
+	req := &deleteRequest{receiptHandle: receiptHandle, done: make(chan error, 1)}
+
+	b.mu.Lock()
+	batch := append(b.takeLocked(), req)
+	b.mu.Unlock()
+
+	b.send(batch)
+	return <-req.done
+}
+
+// flush sends whatever is pending. It is invoked by the window timer.
+func (b *deleteBatcher) flush() {
+	b.mu.Lock()
+	batch := b.takeLocked()
+	b.mu.Unlock()
+	if len(batch) > 0 {
+		b.send(batch)
+	}
+}
+
+// close flushes pending entries and switches the batcher to immediate mode.
+func (b *deleteBatcher) close() {
+	b.mu.Lock()
+	b.closed = true
+	batch := b.takeLocked()
+	b.mu.Unlock()
+	if len(batch) > 0 {
+		b.send(batch)
+	}
+}
+
+// takeLocked detaches the pending batch and stops its timer. b.mu must be held.
+func (b *deleteBatcher) takeLocked() []*deleteRequest {
+	batch := b.pending
+	b.pending = nil
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	return batch
+}
+
+// send deletes the batch, retrying entries that failed for a non-sender reason up to maxRetries times.
+// Every request in the batch receives exactly one result on its done channel.
+func (b *deleteBatcher) send(batch []*deleteRequest) {
+	remaining := batch
+	for attempt := 0; ; attempt++ {
+		retry := b.sendOnce(remaining)
+		if len(retry) == 0 {
+			return
+		}
+		if attempt >= b.maxRetries {
+			for _, req := range retry {
+				req.done <- req.err
+			}
+			return
+		}
+		time.Sleep(time.Duration(attempt+1) * deleteRetryBackoff)
+		remaining = retry
+	}
+}
+
+// sendOnce issues a single DeleteMessageBatch call and returns the requests worth retrying.
+// Requests that succeeded or failed permanently are completed.
+func (b *deleteBatcher) sendOnce(batch []*deleteRequest) []*deleteRequest {
+	entries := make([]sqstypes.DeleteMessageBatchRequestEntry, len(batch))
+	for i, req := range batch {
+		entries[i] = sqstypes.DeleteMessageBatchRequestEntry{
+			Id:            aws.String(strconv.Itoa(i)),
+			ReceiptHandle: aws.String(req.receiptHandle),
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), b.timeout)
+	defer cancel()
+	out, err := b.client.DeleteMessageBatch(ctx, &sqs.DeleteMessageBatchInput{
+		QueueUrl: aws.String(b.queueURL),
+		Entries:  entries,
+	})
+	if err != nil {
+		for _, req := range batch {
+			req.err = fmt.Errorf("%w: %v", ErrDeleteFailed, err)
+		}
+		return batch
+	}
+
+	failed := make(map[int]sqstypes.BatchResultErrorEntry, len(out.Failed))
+	for _, f := range out.Failed {
+		idx, convErr := strconv.Atoi(aws.ToString(f.Id))
+		if convErr != nil || idx < 0 || idx >= len(batch) {
+			continue
+		}
+		failed[idx] = f
+	}
+
+	var retry []*deleteRequest
+	for i, req := range batch {
+		f, ok := failed[i]
+		if !ok {
+			req.done <- nil
+			continue
+		}
+		req.err = fmt.Errorf("%w: %s: %s", ErrDeleteFailed, aws.ToString(f.Code), aws.ToString(f.Message))
+		if f.SenderFault {
+			// Sender faults (e.g. an expired receipt handle) do not succeed on retry.
+			req.done <- req.err
+			continue
+		}
+		retry = append(retry, req)
+	}
+	return retry
+}
@@ -0,0 +1,176 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	sqsrouter "github.com/hatsunemiku3939/sqsrouter"
+)
+
+// batchHandles returns the receipt handles of each DeleteMessageBatch call made on client.
+func batchHandles(client *MockSQSClient) [][]string {
+	var batches [][]string
+	for _, call := range client.recorded("DeleteMessageBatch") {
+		batches = append(batches, call.ReceiptHandles)
+	}
+	return batches
+}
+
+func testBatchConfig(size int, window time.Duration) Config {
+	cfg := DefaultConfig()
+	cfg.BatchDeletes = true
+	cfg.DeleteBatchSize = size
+	cfg.DeleteBatchWindow = window
+	cfg.DeleteMaxRetries = 2
+	return cfg
+}
+
+func deleteConcurrently(b *deleteBatcher, handles ...string) []error {
+	errs := make([]error, len(handles))
+	var wg sync.WaitGroup
+	for i, h := range handles {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = b.delete(h)
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
+func TestDeleteBatcher_FlushesOnSize(t *testing.T) {
+	client := &MockSQSClient{Succeed: true}
+	b := newDeleteBatcher(client, "q", testBatchConfig(3, time.Hour))
+
+	errs := deleteConcurrently(b, "a", "b", "c")
+
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+	calls := batchHandles(client)
+	require.Len(t, calls, 1)
+	assert.ElementsMatch(t, []string{"a", "b", "c"}, calls[0])
+}
+
+func TestDeleteBatcher_FlushesOnWindow(t *testing.T) {
+	client := &MockSQSClient{Succeed: true}
+	b := newDeleteBatcher(client, "q", testBatchConfig(10, 20*time.Millisecond))
+
+	start := time.Now()
+	err := b.delete("a")
+
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+	assert.Equal(t, [][]string{{"a"}}, batchHandles(client))
+}
+
+func TestDeleteBatcher_DeleteNowSkipsWindow(t *testing.T) {
+	client := &MockSQSClient{Succeed: true}
+	b := newDeleteBatcher(client, "q", testBatchConfig(10, time.Hour))
+
+	done := make(chan error, 1)
+	go func() { done <- b.delete("a") }()
+	require.Eventually(t, func() bool {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return len(b.pending) == 1
+	}, time.Second, time.Millisecond)
+
+	assert.NoError(t, b.deleteNow("b"))
+	assert.NoError(t, <-done)
+	assert.Equal(t, [][]string{{"a", "b"}}, batchHandles(client), "pending handles are sent along")
+}
+
+func TestDeleteBatcher_RetriesFailedEntries(t *testing.T) {
+	client := &MockSQSClient{}
+	client.DeleteBatchFunc = func(params *sqs.DeleteMessageBatchInput) (*sqs.DeleteMessageBatchOutput, error) {
+		call := len(client.recorded("DeleteMessageBatch"))
+		out := &sqs.DeleteMessageBatchOutput{}
+		for _, e := range params.Entries {
+			switch {
+			case aws.ToString(e.ReceiptHandle) == "flaky" && call == 1:
+				out.Failed = append(out.Failed, types.BatchResultErrorEntry{Id: e.Id, Code: aws.String("InternalError")})
+			case aws.ToString(e.ReceiptHandle) == "expired":
+				out.Failed = append(out.Failed, types.BatchResultErrorEntry{Id: e.Id, Code: aws.String("ReceiptHandleIsInvalid"), SenderFault: true})
+			default:
+				out.Successful = append(out.Successful, types.DeleteMessageBatchResultEntry{Id: e.Id})
+			}
+		}
+		return out, nil
+	}
+	b := newDeleteBatcher(client, "q", testBatchConfig(3, time.Hour))
+
+	errs := deleteConcurrently(b, "ok", "flaky", "expired")
+
+	byHandle := map[string]error{"ok": errs[0], "flaky": errs[1], "expired": errs[2]}
+	assert.NoError(t, byHandle["ok"])
+	assert.NoError(t, byHandle["flaky"], "transient entry failure should succeed on retry")
+	assert.ErrorIs(t, byHandle["expired"], ErrDeleteFailed)
+
+	calls := batchHandles(client)
+	require.Len(t, calls, 2)
+	assert.Equal(t, []string{"flaky"}, calls[1], "only the non-sender failure is retried")
+}
+
+func TestDeleteBatcher_GivesUpAfterMaxRetries(t *testing.T) {
+	client := &MockSQSClient{DeleteBatchFunc: func(*sqs.DeleteMessageBatchInput) (*sqs.DeleteMessageBatchOutput, error) {
+		return nil, errors.New("service unavailable")
+	}}
+	b := newDeleteBatcher(client, "q", testBatchConfig(1, time.Hour))
+
+	err := b.delete("a")
+
+	assert.ErrorIs(t, err, ErrDeleteFailed)
+	assert.Len(t, batchHandles(client), 3, "initial attempt plus DeleteMaxRetries retries")
+}
+
+func TestDeleteBatcher_CloseFlushesPending(t *testing.T) {
+	client := &MockSQSClient{Succeed: true}
+	b := newDeleteBatcher(client, "q", testBatchConfig(10, time.Hour))
+
+	done := make(chan error, 1)
+	go func() { done <- b.delete("a") }()
+	require.Eventually(t, func() bool {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return len(b.pending) == 1
+	}, time.Second, time.Millisecond)
+
+	b.close()
+
+	assert.NoError(t, <-done)
+	assert.NoError(t, b.delete("b"), "deletes after close are sent immediately")
+	assert.Equal(t, [][]string{{"a"}, {"b"}}, batchHandles(client))
+}
+
+func TestConsumer_processMessage_BatchedDelete(t *testing.T) {
+	router, err := sqsrouter.NewRouter(sqsrouter.EnvelopeSchema)
+	require.NoError(t, err)
+	router.Register("test.event", "1.0", func(context.Context, []byte, []byte) sqsrouter.HandlerResult {
+		return sqsrouter.HandlerResult{ShouldDelete: true}
+	})
+
+	client := &MockSQSClient{Succeed: true}
+	c, err := NewConsumer(client, "q", router, WithDeleteBatching(5*time.Millisecond))
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		body := fmt.Sprintf(`{"schemaVersion":"1.0","messageType":"test.event","messageVersion":"1.0","message":{},"metadata":{"messageId":"m-%d"}}`, i)
+		msg := createSQSMessage(body, fmt.Sprintf("r-%d", i))
+		c.processMessage(context.Background(), c.queues[0], &msg)
+	}
+
+	assert.Equal(t, [][]string{{"r-0"}, {"r-1"}}, batchHandles(client))
+	assert.Empty(t, client.recorded("DeleteMessage"))
+}
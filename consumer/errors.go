@@ -0,0 +1,8 @@
+package consumer
+
+import "errors"
+
+var (
+	ErrInvalidConfig = errors.New("invalid consumer config")
+	ErrDeleteFailed  = errors.New("failed to delete message")
+)
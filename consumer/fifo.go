@@ -0,0 +1,114 @@
+package consumer
+
+import (
+	"context"
+	"crypto/rand"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/hatsunemiku3939/sqsrouter"
+)
+
+// fifoQueueSuffix is the name suffix SQS requires for FIFO queues.
+const fifoQueueSuffix = ".fifo"
+
+// isFIFOQueue reports whether queueURL points to a FIFO queue.
+func isFIFOQueue(queueURL string) bool {
+	return strings.HasSuffix(queueURL, fifoQueueSuffix)
+}
+
+// newReceiveAttemptID returns a random ReceiveRequestAttemptId.
+// Reusing the same ID when retrying a failed ReceiveMessage call makes SQS return the same batch,
+// so messages received by a call whose response was lost are not hidden until their visibility timeout expires.
+// The ID carries 128 random bits in base32, which is within the characters SQS allows.
+func newReceiveAttemptID() string {
+	return rand.Text()
+}
+
+// groupMessages splits a batch by MessageGroupId, keeping the receive order within each group.
+// Groups are returned in the order their first message appears. Messages without a group ID
+// each form a group of their own.
+func groupMessages(msgs []sqstypes.Message) [][]sqstypes.Message {
+	var groups [][]sqstypes.Message
+	index := make(map[string]int)
+	for _, msg := range msgs {
+		id, ok := msg.Attributes[string(sqstypes.MessageSystemAttributeNameMessageGroupId)]
+		if !ok || id == "" {
+			groups = append(groups, []sqstypes.Message{msg})
+			continue
+		}
+		i, seen := index[id]
+		if !seen {
+			i = len(groups)
+			index[id] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], msg)
+	}
+	return groups
+}
+
+// dispatchGroups processes each message group of a FIFO batch in its own goroutine.
+// Messages of a group run one after another. While a message waits for its predecessors, its visibility
+// is extended like that of a running message, so it does not time out before a worker reaches it. Once
+// a message is not deleted, the rest of its group is held back and released at once: SQS redelivers it
+// after that message, which is still in flight, so ordering is preserved. Every message releases its
+// worker slot when it has been processed or held back.
+func (c *Consumer) dispatchGroups(q *queue, msgs []sqstypes.Message, pool *workerPool, wg *sync.WaitGroup) {
+	for _, group := range groupMessages(msgs) {
+		wg.Add(1)
+		go func(group []sqstypes.Message) { //nolint:contextcheck
+			defer wg.Done()
+			stopWaiting := make([]func(), len(group))
+			for i := 1; i < len(group); i++ {
+				stopWaiting[i] = c.startHeartbeat(q, &group[i])
+			}
+			for i := range group {
+				if stopWaiting[i] != nil {
+					stopWaiting[i]()
+				}
+				if !c.processWithTimeout(q, &group[i]) {
+					held := group[i+1:]
+					for j := i + 1; j < len(group); j++ {
+						stopWaiting[j]()
+					}
+					if len(held) > 0 {
+						c.logger.LogAttrs(context.Background(), slog.LevelInfo, "holding back rest of message group",
+							slog.String(sqsrouter.LogKeyQueue, q.url),
+							slog.String(sqsrouter.LogKeyMessageGroupID, group[i].Attributes[string(sqstypes.MessageSystemAttributeNameMessageGroupId)]),
+							slog.String(sqsrouter.LogKeySQSMessageID, aws.ToString(group[i].MessageId)),
+							slog.Int(sqsrouter.LogKeyHeld, len(held)),
+						)
+						c.releaseMessages(q, held)
+					}
+					pool.release(len(held) + 1)
+					return
+				}
+				pool.release(1)
+			}
+		}(group)
+	}
+}
+
+// releaseMessages makes held back messages visible again immediately instead of after their visibility timeout.
+func (c *Consumer) releaseMessages(q *queue, msgs []sqstypes.Message) {
+	for i := range msgs {
+		if err := c.changeVisibility(q, &msgs[i], 0); err != nil {
+			c.logger.LogAttrs(context.Background(), slog.LevelWarn, "failed to release held back message",
+				slog.String(sqsrouter.LogKeyQueue, q.url),
+				slog.String(sqsrouter.LogKeySQSMessageID, aws.ToString(msgs[i].MessageId)),
+				slog.String(sqsrouter.LogKeyError, err.Error()),
+			)
+		}
+	}
+}
+
+// processWithTimeout processes msg under its own ProcessingTimeout and reports whether it was deleted.
+func (c *Consumer) processWithTimeout(q *queue, msg *sqstypes.Message) bool {
+	msgCtx, cancelMsg := context.WithTimeout(context.Background(), c.cfg.ProcessingTimeout)
+	defer cancelMsg()
+	return c.processMessage(msgCtx, q, msg)
+}
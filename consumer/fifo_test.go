@@ -0,0 +1,226 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	sqsrouter "github.com/hatsunemiku3939/sqsrouter"
+)
+
+func fifoMessage(group, id string) types.Message {
+	body := fmt.Sprintf(`{"schemaVersion":"1.0","messageType":"fifo.event","messageVersion":"1.0","message":{"id":%q},"metadata":{"messageId":%q}}`, id, id)
+	msg := createSQSMessage(body, "r-"+id)
+	msg.MessageId = aws.String(id)
+	msg.Attributes = map[string]string{"MessageGroupId": group}
+	return msg
+}
+
+func TestGroupMessages(t *testing.T) {
+	msgs := []types.Message{
+		fifoMessage("a", "a1"),
+		fifoMessage("b", "b1"),
+		fifoMessage("a", "a2"),
+		createSQSMessage("{}", "no-group"),
+		fifoMessage("b", "b2"),
+	}
+
+	groups := groupMessages(msgs)
+
+	var ids [][]string
+	for _, g := range groups {
+		var gi []string
+		for _, m := range g {
+			gi = append(gi, aws.ToString(m.ReceiptHandle))
+		}
+		ids = append(ids, gi)
+	}
+	assert.Equal(t, [][]string{{"r-a1", "r-a2"}, {"r-b1", "r-b2"}, {"no-group"}}, ids)
+}
+
+// fifoTestRouter records the order in which messages are handled and fails the IDs in fail.
+type fifoTestRouter struct {
+	mu      sync.Mutex
+	handled []string
+	active  map[string]bool
+	overlap bool
+}
+
+func newFIFOTestRouter(t *testing.T, fail map[string]bool) (*sqsrouter.Router, *fifoTestRouter) {
+	t.Helper()
+	rec := &fifoTestRouter{active: map[string]bool{}}
+	router, err := sqsrouter.NewRouter(sqsrouter.EnvelopeSchema)
+	require.NoError(t, err)
+	router.Register("fifo.event", "1.0", func(ctx context.Context, _ []byte, _ []byte) sqsrouter.HandlerResult {
+		attrs, _ := sqsrouter.SQSAttributesFromContext(ctx)
+		group, id := attrs.MessageGroupID, attrs.MessageID
+
+		rec.mu.Lock()
+		if rec.active[group] {
+			rec.overlap = true
+		}
+		rec.active[group] = true
+		rec.mu.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		rec.mu.Lock()
+		rec.active[group] = false
+		rec.handled = append(rec.handled, id)
+		rec.mu.Unlock()
+
+		if fail[id] {
+			return sqsrouter.HandlerResult{ShouldDelete: false, Error: errors.New("transient")}
+		}
+		return sqsrouter.HandlerResult{ShouldDelete: true}
+	})
+	return router, rec
+}
+
+func (r *fifoTestRouter) order(group string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []string
+	for _, id := range r.handled {
+		if id[:1] == group {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+func TestConsumer_dispatchGroups_OrdersWithinGroup(t *testing.T) {
+	router, rec := newFIFOTestRouter(t, nil)
+	client := new(MockSQSClient)
+	client.On("DeleteMessage", mock.Anything, mock.Anything).Return(&sqs.DeleteMessageOutput{}, nil)
+	c, err := NewConsumer(client, "queue.fifo", router)
+	require.NoError(t, err)
+
+	msgs := []types.Message{
+		fifoMessage("a", "a1"), fifoMessage("b", "b1"), fifoMessage("a", "a2"),
+		fifoMessage("b", "b2"), fifoMessage("a", "a3"),
+	}
+	pool := newWorkerPool(len(msgs))
+	require.Equal(t, len(msgs), pool.acquire(context.Background(), len(msgs)))
+
+	var wg sync.WaitGroup
+	c.dispatchGroups(c.queues[0], msgs, pool, &wg)
+	wg.Wait()
+
+	assert.Equal(t, []string{"a1", "a2", "a3"}, rec.order("a"))
+	assert.Equal(t, []string{"b1", "b2"}, rec.order("b"))
+	assert.False(t, rec.overlap, "messages of one group must not run concurrently")
+	assert.Equal(t, 0, pool.inFlight(), "every message must release its slot")
+}
+
+func TestConsumer_dispatchGroups_FailureHoldsBackGroup(t *testing.T) {
+	router, rec := newFIFOTestRouter(t, map[string]bool{"a2": true})
+	client := new(MockSQSClient)
+	client.On("DeleteMessage", mock.Anything, mock.Anything).Return(&sqs.DeleteMessageOutput{}, nil)
+	client.On("ChangeMessageVisibility", mock.Anything, mock.MatchedBy(func(in *sqs.ChangeMessageVisibilityInput) bool {
+		return aws.ToString(in.ReceiptHandle) == "r-a3" && in.VisibilityTimeout == 0
+	})).Return(&sqs.ChangeMessageVisibilityOutput{}, nil).Once()
+	c, err := NewConsumer(client, "queue.fifo", router)
+	require.NoError(t, err)
+
+	msgs := []types.Message{
+		fifoMessage("a", "a1"), fifoMessage("a", "a2"), fifoMessage("a", "a3"),
+		fifoMessage("b", "b1"), fifoMessage("b", "b2"),
+	}
+	pool := newWorkerPool(len(msgs))
+	require.Equal(t, len(msgs), pool.acquire(context.Background(), len(msgs)))
+
+	var wg sync.WaitGroup
+	c.dispatchGroups(c.queues[0], msgs, pool, &wg)
+	wg.Wait()
+
+	assert.Equal(t, []string{"a1", "a2"}, rec.order("a"), "a3 must be held back after a2 failed")
+	assert.Equal(t, []string{"b1", "b2"}, rec.order("b"), "other groups are unaffected")
+	assert.Equal(t, 0, pool.inFlight(), "held back messages must release their slots")
+	client.AssertNumberOfCalls(t, "DeleteMessage", 3)
+	client.AssertNumberOfCalls(t, "ChangeMessageVisibility", 1)
+}
+
+func TestConsumer_dispatchGroups_HeartbeatsWaitingMessages(t *testing.T) {
+	router, err := sqsrouter.NewRouter(sqsrouter.EnvelopeSchema)
+	require.NoError(t, err)
+	router.Register("fifo.event", "1.0", func(context.Context, []byte, []byte) sqsrouter.HandlerResult {
+		time.Sleep(60 * time.Millisecond)
+		return sqsrouter.HandlerResult{ShouldDelete: true}
+	})
+	client := &MockSQSClient{Succeed: true}
+	c, err := NewConsumer(client, "queue.fifo", router,
+		WithVisibilityHeartbeat(20*time.Millisecond, 90*time.Second, time.Hour))
+	require.NoError(t, err)
+
+	msgs := []types.Message{fifoMessage("a", "a1"), fifoMessage("a", "a2")}
+	pool := newWorkerPool(len(msgs))
+	require.Equal(t, len(msgs), pool.acquire(context.Background(), len(msgs)))
+
+	var wg sync.WaitGroup
+	c.dispatchGroups(c.queues[0], msgs, pool, &wg)
+	wg.Wait()
+
+	calls := client.recorded("ChangeMessageVisibility", "DeleteMessage")
+	is := func(method, handle string) func(sqsCall) bool {
+		return func(c sqsCall) bool { return c.Method == method && c.ReceiptHandles[0] == handle }
+	}
+	deleted := slices.IndexFunc(calls, is("DeleteMessage", "r-a1"))
+	require.GreaterOrEqual(t, deleted, 0)
+	assert.True(t, slices.ContainsFunc(calls[:deleted], is("ChangeMessageVisibility", "r-a2")), "a2 must be extended while it waits for a1")
+	assert.True(t, is("DeleteMessage", "r-a2")(calls[len(calls)-1]))
+}
+
+func TestConsumer_Start_ReusesReceiveAttemptIDOnRetry(t *testing.T) {
+	router, _ := newFIFOTestRouter(t, nil)
+	client := new(MockSQSClient)
+	c, err := NewConsumer(client, "queue.fifo", router, WithRetrySleep(0))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var ids []string
+	record := func(args mock.Arguments) {
+		ids = append(ids, aws.ToString(args.Get(1).(*sqs.ReceiveMessageInput).ReceiveRequestAttemptId))
+	}
+	client.On("ReceiveMessage", mock.Anything, mock.Anything).Run(record).Return(nil, errors.New("connection reset")).Once()
+	client.On("ReceiveMessage", mock.Anything, mock.Anything).Run(record).Return(&sqs.ReceiveMessageOutput{}, nil).Once()
+	client.On("ReceiveMessage", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		record(args)
+		cancel()
+	}).Return(&sqs.ReceiveMessageOutput{}, nil).Once()
+
+	c.Start(ctx)
+
+	require.Len(t, ids, 3)
+	assert.NotEmpty(t, ids[0])
+	assert.Equal(t, ids[0], ids[1], "a failed receive must be retried with the same attempt ID")
+	assert.NotEqual(t, ids[1], ids[2], "a successful receive starts a new attempt")
+}
+
+func TestNewConsumer_DetectsFIFOQueue(t *testing.T) {
+	router, err := sqsrouter.NewRouter(sqsrouter.EnvelopeSchema)
+	require.NoError(t, err)
+
+	c, err := NewConsumer(new(MockSQSClient), "https://sqs.us-east-1.amazonaws.com/123/orders.fifo", router)
+	require.NoError(t, err)
+	assert.True(t, c.queues[0].fifo)
+
+	c, err = NewConsumer(new(MockSQSClient), "https://sqs.us-east-1.amazonaws.com/123/orders", router)
+	require.NoError(t, err)
+	assert.False(t, c.queues[0].fifo)
+
+	c, err = NewConsumer(new(MockSQSClient), "http://proxy/orders", router, WithFIFO())
+	require.NoError(t, err)
+	assert.True(t, c.queues[0].fifo)
+}
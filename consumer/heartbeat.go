@@ -0,0 +1,79 @@
+package consumer
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/hatsunemiku3939/sqsrouter"
+)
+
+// startHeartbeat periodically extends the visibility timeout of msg so it does not become visible
+// again while its handler is still running. The heartbeat ends when the returned stop function is
+// called or when MaxVisibilityExtension has been used up. stop waits for an in-progress extension
+// to finish, so no extension is issued after stop returns. stop may be called more than once.
+func (c *Consumer) startHeartbeat(q *queue, msg *sqstypes.Message) (stop func()) {
+	if c.cfg.HeartbeatInterval <= 0 || msg.ReceiptHandle == nil {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(c.cfg.HeartbeatInterval)
+		defer ticker.Stop()
+		started := time.Now()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			extension := c.cfg.VisibilityExtension
+			if remaining := c.cfg.MaxVisibilityExtension - time.Since(started); remaining < extension {
+				extension = remaining
+			}
+			if extension <= 0 {
+				c.logger.LogAttrs(context.Background(), slog.LevelWarn, "stopped extending message visibility",
+					slog.String(sqsrouter.LogKeyQueue, q.url),
+					slog.String(sqsrouter.LogKeySQSMessageID, aws.ToString(msg.MessageId)),
+					slog.Duration(sqsrouter.LogKeyMaxExtension, c.cfg.MaxVisibilityExtension),
+				)
+				return
+			}
+			if err := c.changeVisibility(q, msg, extension); err != nil {
+				c.logger.LogAttrs(context.Background(), slog.LevelError, "failed to extend message visibility",
+					slog.String(sqsrouter.LogKeyQueue, q.url),
+					slog.String(sqsrouter.LogKeySQSMessageID, aws.ToString(msg.MessageId)),
+					slog.String(sqsrouter.LogKeyError, err.Error()),
+				)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+		<-finished
+	}
+}
+
+// changeVisibility sets the visibility timeout of msg to d from now, rounded up to whole seconds.
+func (c *Consumer) changeVisibility(q *queue, msg *sqstypes.Message, d time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.DeleteTimeout)
+	defer cancel()
+
+	_, err := c.client.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String(q.url),
+		ReceiptHandle:     msg.ReceiptHandle,
+		VisibilityTimeout: int32(math.Ceil(d.Seconds())),
+	})
+	return err
+}
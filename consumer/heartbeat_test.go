@@ -0,0 +1,104 @@
+package consumer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	sqsrouter "github.com/hatsunemiku3939/sqsrouter"
+)
+
+// visibilityEvents returns the visibility changes and deletes made on client in order, as "extend:<handle>"
+// and "delete:<handle>", together with the requested visibility timeouts.
+func visibilityEvents(client *MockSQSClient) ([]string, []int32) {
+	var events []string
+	var timeouts []int32
+	for _, call := range client.recorded("ChangeMessageVisibility", "DeleteMessage") {
+		if call.Method == "DeleteMessage" {
+			events = append(events, "delete:"+call.ReceiptHandles[0])
+			continue
+		}
+		events = append(events, "extend:"+call.ReceiptHandles[0])
+		timeouts = append(timeouts, call.VisibilityTimeout)
+	}
+	return events, timeouts
+}
+
+func newSlowHandlerRouter(t *testing.T, d time.Duration) *sqsrouter.Router {
+	t.Helper()
+	router, err := sqsrouter.NewRouter(sqsrouter.EnvelopeSchema)
+	require.NoError(t, err)
+	router.Register("test.event", "1.0", func(context.Context, []byte, []byte) sqsrouter.HandlerResult {
+		time.Sleep(d)
+		return sqsrouter.HandlerResult{ShouldDelete: true}
+	})
+	return router
+}
+
+const heartbeatTestBody = `{"schemaVersion":"1.0","messageType":"test.event","messageVersion":"1.0","message":{},"metadata":{"messageId":"msg-1"}}`
+
+func TestConsumer_Heartbeat_ExtendsWhileHandlerRuns(t *testing.T) {
+	client := &MockSQSClient{Succeed: true}
+	c, err := NewConsumer(client, "q", newSlowHandlerRouter(t, 80*time.Millisecond),
+		WithVisibilityHeartbeat(20*time.Millisecond, 90*time.Second, time.Hour))
+	require.NoError(t, err)
+
+	msg := createSQSMessage(heartbeatTestBody, "receipt-1")
+	c.processMessage(context.Background(), c.queues[0], &msg)
+
+	events, timeouts := visibilityEvents(client)
+	require.GreaterOrEqual(t, len(events), 3, "expected at least two extensions followed by a delete")
+	assert.Equal(t, "delete:receipt-1", events[len(events)-1], "no extension may be issued after the message is deleted")
+	for _, e := range events[:len(events)-1] {
+		assert.Equal(t, "extend:receipt-1", e)
+	}
+	assert.Equal(t, int32(90), timeouts[0])
+
+	time.Sleep(50 * time.Millisecond)
+	after, _ := visibilityEvents(client)
+	assert.Equal(t, events, after, "heartbeat must stop once the message is handled")
+}
+
+func TestConsumer_Heartbeat_RespectsMaxExtension(t *testing.T) {
+	client := &MockSQSClient{Succeed: true}
+	c, err := NewConsumer(client, "q", newSlowHandlerRouter(t, 120*time.Millisecond),
+		WithVisibilityHeartbeat(10*time.Millisecond, time.Minute, 35*time.Millisecond))
+	require.NoError(t, err)
+
+	msg := createSQSMessage(heartbeatTestBody, "receipt-1")
+	c.processMessage(context.Background(), c.queues[0], &msg)
+
+	_, timeouts := visibilityEvents(client)
+	assert.LessOrEqual(t, len(timeouts), 4, "heartbeat must stop once MaxVisibilityExtension is used up")
+	for _, ts := range timeouts {
+		assert.Equal(t, int32(1), ts, "extensions are clamped to the remaining budget")
+	}
+}
+
+func TestConsumer_Heartbeat_DisabledByDefault(t *testing.T) {
+	client := &MockSQSClient{Succeed: true}
+	c, err := NewConsumer(client, "q", newSlowHandlerRouter(t, 30*time.Millisecond))
+	require.NoError(t, err)
+
+	msg := createSQSMessage(heartbeatTestBody, "receipt-1")
+	c.processMessage(context.Background(), c.queues[0], &msg)
+
+	events, _ := visibilityEvents(client)
+	assert.Equal(t, []string{"delete:receipt-1"}, events)
+}
+
+func TestConfig_Validate_Heartbeat(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.HeartbeatInterval = time.Minute
+	cfg.VisibilityExtension = 30 * time.Second
+	assert.ErrorIs(t, cfg.Validate(), ErrInvalidConfig, "extension shorter than interval leaves gaps")
+
+	cfg.VisibilityExtension = 2 * time.Minute
+	assert.NoError(t, cfg.Validate())
+
+	cfg.MaxVisibilityExtension = 13 * time.Hour
+	assert.ErrorIs(t, cfg.Validate(), ErrInvalidConfig)
+}
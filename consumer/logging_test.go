@@ -0,0 +1,75 @@
+package consumer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	sqsrouter "github.com/hatsunemiku3939/sqsrouter"
+)
+
+// logRecords decodes the records written by a slog.JSONHandler.
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var out []map[string]any
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var rec map[string]any
+		require.NoError(t, dec.Decode(&rec))
+		out = append(out, rec)
+	}
+	return out
+}
+
+func TestConsumer_processMessage_Logging(t *testing.T) {
+	router, err := sqsrouter.NewRouter(sqsrouter.EnvelopeSchema, sqsrouter.WithLogger(nil))
+	require.NoError(t, err)
+	router.Register("test.event", "1.0", func(context.Context, []byte, []byte) sqsrouter.HandlerResult {
+		return sqsrouter.HandlerResult{ShouldDelete: false, Error: errors.New("boom")}
+	})
+
+	var buf bytes.Buffer
+	client := new(MockSQSClient)
+	c, err := NewConsumer(client, "queue-url", router, WithLogger(slog.New(slog.NewJSONHandler(&buf, nil))))
+	require.NoError(t, err)
+
+	msg := createSQSMessage(`{"schemaVersion":"1.0","messageType":"test.event","messageVersion":"1.0","message":{},"metadata":{"messageId":"msg-1"}}`, "receipt-1")
+	c.processMessage(context.Background(), c.queues[0], &msg)
+
+	records := logRecords(t, &buf)
+	require.Len(t, records, 1)
+	rec := records[0]
+	assert.Equal(t, "message processed", rec["msg"])
+	assert.Equal(t, "WARN", rec["level"])
+	assert.Equal(t, "queue-url", rec[sqsrouter.LogKeyQueue])
+	assert.Equal(t, "test.event", rec[sqsrouter.LogKeyMessageType])
+	assert.Equal(t, "1.0", rec[sqsrouter.LogKeyMessageVersion])
+	assert.Equal(t, "msg-1", rec[sqsrouter.LogKeyMessageID])
+	assert.Equal(t, "handler_error", rec[sqsrouter.LogKeyFailureKind])
+	assert.Equal(t, false, rec[sqsrouter.LogKeyDeleted])
+	assert.Equal(t, "boom", rec[sqsrouter.LogKeyError])
+	assert.Contains(t, rec, sqsrouter.LogKeyDuration)
+}
+
+func TestConsumer_WithLoggerNil_DisablesLogging(t *testing.T) {
+	router, err := sqsrouter.NewRouter(sqsrouter.EnvelopeSchema)
+	require.NoError(t, err)
+	client := new(MockSQSClient)
+	c, err := NewConsumer(client, "queue-url", router, WithLogger(nil))
+	require.NoError(t, err)
+
+	assert.False(t, c.logger.Enabled(context.Background(), slog.LevelError))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client.On("ReceiveMessage", mock.Anything, mock.Anything).Run(func(mock.Arguments) { cancel() }).Return(&sqs.ReceiveMessageOutput{}, nil).Once()
+	c.Start(ctx)
+	client.AssertExpectations(t)
+}
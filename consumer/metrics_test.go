@@ -0,0 +1,86 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	sqsrouter "github.com/hatsunemiku3939/sqsrouter"
+)
+
+// consumerMetrics records the consumer-side Metrics hooks.
+type consumerMetrics struct {
+	sqsrouter.NopMetrics
+	mu     sync.Mutex
+	events []string
+}
+
+func (m *consumerMetrics) record(e string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, e)
+}
+
+func (m *consumerMetrics) MessagesReceived(queue string, n int) { m.record("received:" + queue) }
+func (m *consumerMetrics) ReceiveError(queue string)            { m.record("receive_error:" + queue) }
+func (m *consumerMetrics) MessageDeleted(queue string)          { m.record("deleted:" + queue) }
+func (m *consumerMetrics) DeleteError(queue string)             { m.record("delete_error:" + queue) }
+func (m *consumerMetrics) MessageRetried(queue string, delay time.Duration) {
+	m.record("retried:" + queue + ":" + delay.String())
+}
+
+func TestConsumer_processMessage_Metrics(t *testing.T) {
+	router, err := sqsrouter.NewRouter(sqsrouter.EnvelopeSchema)
+	require.NoError(t, err)
+	router.Register("ok", "1.0", func(context.Context, []byte, []byte) sqsrouter.HandlerResult {
+		return sqsrouter.HandlerResult{ShouldDelete: true}
+	})
+	router.Register("retry", "1.0", func(context.Context, []byte, []byte) sqsrouter.HandlerResult {
+		return sqsrouter.HandlerResult{Error: errors.New("later"), RetryAfter: 5 * time.Second}
+	})
+
+	m := &consumerMetrics{}
+	client := new(MockSQSClient)
+	client.On("DeleteMessage", mock.Anything, mock.Anything).Return(&sqs.DeleteMessageOutput{}, nil).Once()
+	client.On("DeleteMessage", mock.Anything, mock.Anything).Return(nil, errors.New("denied")).Once()
+	client.On("ChangeMessageVisibility", mock.Anything, mock.Anything).Return(&sqs.ChangeMessageVisibilityOutput{}, nil)
+	c, err := NewConsumer(client, "q", router, WithMetrics(m))
+	require.NoError(t, err)
+
+	for _, body := range []string{
+		`{"schemaVersion":"1.0","messageType":"ok","messageVersion":"1.0","message":{},"metadata":{}}`,
+		`{"schemaVersion":"1.0","messageType":"ok","messageVersion":"1.0","message":{},"metadata":{}}`,
+		`{"schemaVersion":"1.0","messageType":"retry","messageVersion":"1.0","message":{},"metadata":{}}`,
+	} {
+		msg := createSQSMessage(body, "r")
+		c.processMessage(context.Background(), c.queues[0], &msg)
+	}
+
+	assert.Equal(t, []string{"deleted:q", "delete_error:q", "retried:q:5s"}, m.events)
+}
+
+func TestConsumer_Start_ReceiveMetrics(t *testing.T) {
+	router, err := sqsrouter.NewRouter(sqsrouter.EnvelopeSchema)
+	require.NoError(t, err)
+
+	m := &consumerMetrics{}
+	client := new(MockSQSClient)
+	c, err := NewConsumer(client, "q", router, WithMetrics(m), WithRetrySleep(0))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client.On("ReceiveMessage", mock.Anything, mock.Anything).Return(nil, errors.New("throttled")).Once()
+	client.On("ReceiveMessage", mock.Anything, mock.Anything).Run(func(mock.Arguments) { cancel() }).
+		Return(&sqs.ReceiveMessageOutput{}, nil).Once()
+
+	c.Start(ctx)
+
+	assert.Equal(t, []string{"receive_error:q"}, m.events, "empty receives are not counted")
+}
@@ -0,0 +1,100 @@
+package consumer
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/hatsunemiku3939/sqsrouter"
+)
+
+// ConsumerOption configures a Consumer at construction time.
+type ConsumerOption func(*Consumer) //nolint:revive
+
+// WithConfig replaces the whole Consumer configuration.
+// Options applied after WithConfig override individual fields.
+func WithConfig(cfg Config) ConsumerOption {
+	return func(c *Consumer) { c.cfg = cfg }
+}
+
+// WithMaxNumberOfMessages sets the maximum number of messages requested per ReceiveMessage call.
+func WithMaxNumberOfMessages(n int32) ConsumerOption {
+	return func(c *Consumer) { c.cfg.MaxNumberOfMessages = n }
+}
+
+// WithWaitTimeSeconds sets the long polling duration of a ReceiveMessage call.
+func WithWaitTimeSeconds(s int32) ConsumerOption {
+	return func(c *Consumer) { c.cfg.WaitTimeSeconds = s }
+}
+
+// WithDeleteTimeout sets the client-side timeout for deleting a message.
+func WithDeleteTimeout(d time.Duration) ConsumerOption {
+	return func(c *Consumer) { c.cfg.DeleteTimeout = d }
+}
+
+// WithProcessingTimeout sets the deadline for routing and handling a single message.
+func WithProcessingTimeout(d time.Duration) ConsumerOption {
+	return func(c *Consumer) { c.cfg.ProcessingTimeout = d }
+}
+
+// WithRetrySleep sets the pause after a failed ReceiveMessage call.
+func WithRetrySleep(d time.Duration) ConsumerOption {
+	return func(c *Consumer) { c.cfg.RetrySleep = d }
+}
+
+// WithMaxConcurrency sets the maximum number of messages processed concurrently.
+func WithMaxConcurrency(n int) ConsumerOption {
+	return func(c *Consumer) { c.cfg.MaxConcurrency = n }
+}
+
+// WithDeleteBatching enables DeleteMessageBatch with the given flush window.
+// Receipt handles are flushed when DeleteBatchSize handles are pending or the window elapses, whichever comes first.
+func WithDeleteBatching(window time.Duration) ConsumerOption {
+	return func(c *Consumer) {
+		c.cfg.BatchDeletes = true
+		c.cfg.DeleteBatchWindow = window
+	}
+}
+
+// WithVisibilityHeartbeat extends the visibility timeout of in-flight messages every interval
+// by extension, for at most maxExtension in total per message.
+func WithVisibilityHeartbeat(interval, extension, maxExtension time.Duration) ConsumerOption {
+	return func(c *Consumer) {
+		c.cfg.HeartbeatInterval = interval
+		c.cfg.VisibilityExtension = extension
+		c.cfg.MaxVisibilityExtension = maxExtension
+	}
+}
+
+// WithFIFO enables in-order processing per MessageGroupId.
+// It is only needed for FIFO queues whose URL does not end in ".fifo", e.g. behind a proxy.
+func WithFIFO() ConsumerOption {
+	return func(c *Consumer) { c.cfg.FIFO = true }
+}
+
+// WithStrictPriority makes a multi-queue consumer drain its queues in the order they were given
+// instead of polling them by weight.
+func WithStrictPriority() ConsumerOption {
+	return func(c *Consumer) { c.cfg.StrictPriority = true }
+}
+
+// WithLogger sets the logger for consumer lifecycle events. A nil logger disables logging.
+// Without this option slog.Default() is used.
+func WithLogger(l *slog.Logger) ConsumerOption {
+	return func(c *Consumer) {
+		if l == nil {
+			l = slog.New(slog.DiscardHandler)
+		}
+		c.logger = l
+	}
+}
+
+// WithMetrics sets the Metrics the consumer reports receives, deletes and retries to.
+// A nil value disables metrics, which is also the default. Routing outcomes are reported by the router.
+func WithMetrics(m sqsrouter.Metrics) ConsumerOption {
+	return func(c *Consumer) {
+		if m == nil {
+			m = sqsrouter.NopMetrics{}
+		}
+		c.metrics = m
+	}
+}
@@ -0,0 +1,47 @@
+package consumer
+
+import "context"
+
+// workerPool bounds the number of messages processed concurrently.
+// Slots are reserved before polling so that every received message can start immediately,
+// and released by the worker once the message has been handled.
+type workerPool struct {
+	slots chan struct{}
+}
+
+// newWorkerPool creates a pool with the given number of worker slots.
+func newWorkerPool(size int) *workerPool {
+	return &workerPool{slots: make(chan struct{}, size)}
+}
+
+// acquire blocks until at least one slot is free, then reserves up to limit slots without blocking further.
+// It returns the number of reserved slots, or 0 if ctx is done before any slot becomes free.
+func (p *workerPool) acquire(ctx context.Context, limit int) int {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return 0
+	}
+	n := 1
+	for n < limit {
+		select {
+		case p.slots <- struct{}{}:
+			n++
+		default:
+			return n
+		}
+	}
+	return n
+}
+
+// release frees n previously reserved slots.
+func (p *workerPool) release(n int) {
+	for i := 0; i < n; i++ {
+		<-p.slots
+	}
+}
+
+// inFlight returns the number of currently reserved slots.
+func (p *workerPool) inFlight() int {
+	return len(p.slots)
+}
@@ -0,0 +1,97 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	sqsrouter "github.com/hatsunemiku3939/sqsrouter"
+)
+
+func TestWorkerPool_AcquireRelease(t *testing.T) {
+	p := newWorkerPool(3)
+
+	assert.Equal(t, 2, p.acquire(context.Background(), 2))
+	assert.Equal(t, 2, p.inFlight())
+
+	// Only one slot is left even though more are requested.
+	assert.Equal(t, 1, p.acquire(context.Background(), 5))
+	assert.Equal(t, 3, p.inFlight())
+
+	p.release(2)
+	assert.Equal(t, 1, p.inFlight())
+}
+
+func TestWorkerPool_AcquireBlocksWhenSaturated(t *testing.T) {
+	p := newWorkerPool(1)
+	require.Equal(t, 1, p.acquire(context.Background(), 1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, 0, p.acquire(ctx, 1), "acquire must give up when ctx is done")
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		p.release(1)
+	}()
+	assert.Equal(t, 1, p.acquire(context.Background(), 1), "acquire must resume once a slot is released")
+}
+
+// sizedBatches returns a receive hook that answers with as many messages as requested and cancels
+// the consumer after stopAfter polls.
+func sizedBatches(stopAfter int, cancel context.CancelFunc) func(*sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+	polls := 0
+	return func(params *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+		polls++
+		if polls >= stopAfter {
+			cancel()
+		}
+		msgs := make([]types.Message, 0, params.MaxNumberOfMessages)
+		for i := int32(0); i < params.MaxNumberOfMessages; i++ {
+			body := fmt.Sprintf(`{"schemaVersion":"1.0","messageType":"slow","messageVersion":"1.0","message":{},"metadata":{"messageId":"m-%d-%d"}}`, polls, i)
+			msgs = append(msgs, createSQSMessage(body, fmt.Sprintf("r-%d-%d", polls, i)))
+		}
+		return &sqs.ReceiveMessageOutput{Messages: msgs}, nil
+	}
+}
+
+func TestConsumer_Start_BoundsInFlightMessages(t *testing.T) {
+	const limit = 3
+
+	router, err := sqsrouter.NewRouter(sqsrouter.EnvelopeSchema)
+	require.NoError(t, err)
+
+	var active, maxActive int32
+	router.Register("slow", "1.0", func(ctx context.Context, _ []byte, _ []byte) sqsrouter.HandlerResult {
+		n := atomic.AddInt32(&active, 1)
+		for {
+			m := atomic.LoadInt32(&maxActive)
+			if n <= m || atomic.CompareAndSwapInt32(&maxActive, m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&active, -1)
+		return sqsrouter.HandlerResult{ShouldDelete: true}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client := &MockSQSClient{ReceiveFunc: sizedBatches(5, cancel), Succeed: true}
+	c, err := NewConsumer(client, "q", router, WithMaxConcurrency(limit), WithMaxNumberOfMessages(10))
+	require.NoError(t, err)
+
+	c.Start(ctx)
+
+	assert.LessOrEqual(t, atomic.LoadInt32(&maxActive), int32(limit), "in-flight messages exceeded MaxConcurrency")
+	for _, call := range client.recorded("ReceiveMessage") {
+		assert.LessOrEqual(t, call.MaxMessages, int32(limit), "requested more messages than free workers")
+		assert.GreaterOrEqual(t, call.MaxMessages, int32(1))
+	}
+}
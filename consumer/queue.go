@@ -0,0 +1,87 @@
+package consumer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Queue is one SQS queue polled by a multi-queue Consumer.
+type Queue struct {
+	// URL is the queue URL.
+	URL string
+	// Weight is the relative share of polls the queue is given under weighted scheduling. Zero is treated as 1.
+	// It is ignored when Config.StrictPriority is set; the order of the queues decides instead.
+	Weight int
+}
+
+// queue is the per-queue state of a Consumer.
+type queue struct {
+	url    string
+	weight int
+	fifo   bool
+	// batcher is non-nil when Config.BatchDeletes is enabled.
+	batcher *deleteBatcher
+	// attemptID is the ReceiveRequestAttemptId of the next receive; only set for FIFO queues.
+	attemptID *string
+	// credit is the smooth weighted round-robin counter, only touched by the poll loop.
+	credit int
+}
+
+// validateQueues checks the queue list passed to NewMultiQueueConsumer.
+func validateQueues(queues []Queue) error {
+	if len(queues) == 0 {
+		return fmt.Errorf("%w: at least one queue is required", ErrInvalidConfig)
+	}
+	seen := make(map[string]bool, len(queues))
+	for _, q := range queues {
+		if q.URL == "" {
+			return fmt.Errorf("%w: queue URL must not be empty", ErrInvalidConfig)
+		}
+		if q.Weight < 0 {
+			return fmt.Errorf("%w: weight of queue %s must not be negative, got %d", ErrInvalidConfig, q.URL, q.Weight)
+		}
+		if seen[q.URL] {
+			return fmt.Errorf("%w: queue %s is listed twice", ErrInvalidConfig, q.URL)
+		}
+		seen[q.URL] = true
+	}
+	return nil
+}
+
+// pollOrder returns the queues in the order they are polled in the next cycle.
+// With strict priority the configured order is used as is. Otherwise the queue picked by smooth weighted
+// round-robin comes first, followed by the others in configured order, so an empty pick does not waste a cycle.
+func (c *Consumer) pollOrder() []*queue {
+	if len(c.queues) == 1 || c.cfg.StrictPriority {
+		return c.queues
+	}
+
+	total := 0
+	var picked *queue
+	for _, q := range c.queues {
+		q.credit += q.weight
+		total += q.weight
+		if picked == nil || q.credit > picked.credit {
+			picked = q
+		}
+	}
+	picked.credit -= total
+
+	order := make([]*queue, 0, len(c.queues))
+	order = append(order, picked)
+	for _, q := range c.queues {
+		if q != picked {
+			order = append(order, q)
+		}
+	}
+	return order
+}
+
+// queueURLs returns the URLs of all polled queues, for logging.
+func (c *Consumer) queueURLs() string {
+	urls := make([]string, len(c.queues))
+	for i, q := range c.queues {
+		urls[i] = q.url
+	}
+	return strings.Join(urls, ", ")
+}
@@ -0,0 +1,173 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	sqsrouter "github.com/hatsunemiku3939/sqsrouter"
+)
+
+func TestNewMultiQueueConsumer_Validation(t *testing.T) {
+	router, err := sqsrouter.NewRouter(sqsrouter.EnvelopeSchema)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		queues []Queue
+	}{
+		{"no queues", nil},
+		{"empty URL", []Queue{{URL: ""}}},
+		{"negative weight", []Queue{{URL: "a", Weight: -1}}},
+		{"duplicate URL", []Queue{{URL: "a"}, {URL: "a"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewMultiQueueConsumer(new(MockSQSClient), tt.queues, router)
+			assert.ErrorIs(t, err, ErrInvalidConfig)
+		})
+	}
+}
+
+func firstURLs(c *Consumer, cycles int) []string {
+	var urls []string
+	for i := 0; i < cycles; i++ {
+		urls = append(urls, c.pollOrder()[0].url)
+	}
+	return urls
+}
+
+func TestConsumer_pollOrder(t *testing.T) {
+	router, err := sqsrouter.NewRouter(sqsrouter.EnvelopeSchema)
+	require.NoError(t, err)
+	queues := []Queue{{URL: "high", Weight: 3}, {URL: "low", Weight: 1}}
+
+	t.Run("weighted round-robin", func(t *testing.T) {
+		c, err := NewMultiQueueConsumer(new(MockSQSClient), queues, router)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"high", "high", "low", "high", "high", "high", "low", "high"}, firstURLs(c, 8))
+		order := c.pollOrder()
+		require.Len(t, order, 2, "every queue is polled when the pick is empty")
+	})
+
+	t.Run("strict priority", func(t *testing.T) {
+		c, err := NewMultiQueueConsumer(new(MockSQSClient), []Queue{{URL: "high"}, {URL: "low", Weight: 10}}, router, WithStrictPriority())
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"high", "high", "high"}, firstURLs(c, 3))
+	})
+}
+
+// backlogScript is a receive script for MockSQSClient that serves a fixed backlog per queue.
+type backlogScript struct {
+	backlog map[string]int
+	// failures is the number of receives per queue that fail before it serves its backlog.
+	failures map[string]int
+	// highLeftAtLow is the remaining high backlog each time low is polled.
+	highLeftAtLow []int
+	cancel        context.CancelFunc
+}
+
+func (s *backlogScript) receive(params *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+	url := aws.ToString(params.QueueUrl)
+	if url == "low" {
+		s.highLeftAtLow = append(s.highLeftAtLow, s.backlog["high"])
+	}
+	if s.failures[url] > 0 {
+		s.failures[url]--
+		return nil, errors.New("service unavailable")
+	}
+
+	var msgs []types.Message
+	for i := int32(0); i < params.MaxNumberOfMessages && s.backlog[url] > 0; i++ {
+		s.backlog[url]--
+		id := fmt.Sprintf("%s-%d", url, s.backlog[url])
+		body := fmt.Sprintf(`{"schemaVersion":"1.0","messageType":"test.event","messageVersion":"1.0","message":{},"metadata":{"messageId":%q}}`, id)
+		msgs = append(msgs, createSQSMessage(body, id))
+	}
+	if len(msgs) == 0 && s.backlog["high"] == 0 && s.backlog["low"] == 0 {
+		s.cancel()
+	}
+	return &sqs.ReceiveMessageOutput{Messages: msgs}, nil
+}
+
+// deletedByQueue returns the receipt handles deleted on client per queue URL.
+func deletedByQueue(client *MockSQSClient) map[string][]string {
+	deleted := map[string][]string{}
+	for _, call := range client.recorded("DeleteMessage") {
+		deleted[call.QueueURL] = append(deleted[call.QueueURL], call.ReceiptHandles...)
+	}
+	return deleted
+}
+
+func TestConsumer_Start_MultiQueueStrictPriority(t *testing.T) {
+	router, err := sqsrouter.NewRouter(sqsrouter.EnvelopeSchema)
+	require.NoError(t, err)
+	router.Register("test.event", "1.0", func(context.Context, []byte, []byte) sqsrouter.HandlerResult {
+		return sqsrouter.HandlerResult{ShouldDelete: true}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	script := &backlogScript{backlog: map[string]int{"high": 4, "low": 2}, cancel: cancel}
+	client := &MockSQSClient{ReceiveFunc: script.receive, Succeed: true}
+	c, err := NewMultiQueueConsumer(client, []Queue{{URL: "high"}, {URL: "low"}}, router,
+		WithStrictPriority(), WithMaxNumberOfMessages(2), WithMaxConcurrency(2), WithWaitTimeSeconds(20))
+	require.NoError(t, err)
+
+	c.Start(ctx)
+
+	require.NotEmpty(t, script.highLeftAtLow)
+	for _, left := range script.highLeftAtLow {
+		assert.Equal(t, 0, left, "low must only be polled once high is drained")
+	}
+	deleted := deletedByQueue(client)
+	assert.Len(t, deleted["high"], 4)
+	assert.Len(t, deleted["low"], 2)
+	for _, h := range deleted["low"] {
+		assert.Contains(t, h, "low-", "deletes must target the queue the message came from")
+	}
+	var lowWaits []int32
+	for _, call := range client.recorded("ReceiveMessage") {
+		if call.QueueURL == "high" {
+			assert.Equal(t, int32(0), call.WaitTimeSeconds, "only the last queue in the order long-polls")
+		} else {
+			lowWaits = append(lowWaits, call.WaitTimeSeconds)
+		}
+	}
+	assert.Contains(t, lowWaits, int32(20))
+}
+
+func TestConsumer_Start_StrictPriorityFailedReceiveMovesOn(t *testing.T) {
+	router, err := sqsrouter.NewRouter(sqsrouter.EnvelopeSchema)
+	require.NoError(t, err)
+	router.Register("test.event", "1.0", func(context.Context, []byte, []byte) sqsrouter.HandlerResult {
+		return sqsrouter.HandlerResult{ShouldDelete: true}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	script := &backlogScript{backlog: map[string]int{"high": 2, "low": 2}, failures: map[string]int{"high": 1}, cancel: cancel}
+	client := &MockSQSClient{ReceiveFunc: script.receive, Succeed: true}
+	c, err := NewMultiQueueConsumer(client, []Queue{{URL: "high"}, {URL: "low"}}, router,
+		WithStrictPriority(), WithMaxNumberOfMessages(2), WithMaxConcurrency(2), WithRetrySleep(0))
+	require.NoError(t, err)
+
+	c.Start(ctx)
+
+	var receives []string
+	for _, call := range client.recorded("ReceiveMessage") {
+		receives = append(receives, call.QueueURL)
+	}
+	require.GreaterOrEqual(t, len(receives), 3)
+	assert.Equal(t, []string{"high", "low", "high"}, receives[:3], "a failed receive moves on to the next queue")
+	deleted := deletedByQueue(client)
+	assert.Len(t, deleted["high"], 2)
+	assert.Len(t, deleted["low"], 2)
+}
@@ -0,0 +1,26 @@
+package sqsrouter
+
+import "context"
+
+type sqsAttributesKey struct{}
+
+// WithSQSAttributes returns a copy of ctx carrying the SQS attributes of the message being routed.
+// The consumer sets it before calling Router.Route.
+func WithSQSAttributes(ctx context.Context, attrs *SQSAttributes) context.Context {
+	return context.WithValue(ctx, sqsAttributesKey{}, attrs)
+}
+
+// SQSAttributesFromContext returns the SQS attributes stored by WithSQSAttributes.
+func SQSAttributesFromContext(ctx context.Context) (*SQSAttributes, bool) {
+	attrs, ok := ctx.Value(sqsAttributesKey{}).(*SQSAttributes)
+	return attrs, ok && attrs != nil
+}
+
+// ReceiveCountFromContext returns the ApproximateReceiveCount of the message being routed, if known.
+func ReceiveCountFromContext(ctx context.Context) (int, bool) {
+	attrs, ok := SQSAttributesFromContext(ctx)
+	if !ok || attrs.ApproximateReceiveCount == 0 {
+		return 0, false
+	}
+	return attrs.ApproximateReceiveCount, true
+}
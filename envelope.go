@@ -0,0 +1,211 @@
+package sqsrouter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hatsunemiku3939/sqsrouter/internal/jsonschema"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// defaultEnvelopeSchema is the schema the native envelope decoder implements.
+// EnvelopeSchema is initialized from it; keeping a private copy means reassigning EnvelopeSchema
+// cannot make the router believe a different schema is the default one.
+const defaultEnvelopeSchema = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "properties": {
+    "schemaVersion": { "type": "string" },
+    "messageType": { "type": "string" },
+    "messageVersion": { "type": "string" },
+    "message": { "type": "object" },
+    "metadata": { "type": "object" }
+  },
+  "required": ["schemaVersion", "messageType", "messageVersion", "message", "metadata"]
+}`
+
+// isDefaultEnvelopeSchema reports whether schema is the default envelope schema, ignoring insignificant whitespace.
+func isDefaultEnvelopeSchema(schema string) bool {
+	var got, want bytes.Buffer
+	if json.Compact(&got, []byte(schema)) != nil || json.Compact(&want, []byte(defaultEnvelopeSchema)) != nil {
+		return false
+	}
+	return bytes.Equal(got.Bytes(), want.Bytes())
+}
+
+// envelopeField is a required top-level envelope field and the JSON type the default schema demands.
+type envelopeField struct {
+	name     string
+	jsonType string
+}
+
+// envelopeFields lists the required fields of the default envelope schema in schema order.
+var envelopeFields = []envelopeField{
+	{"schemaVersion", "string"},
+	{"messageType", "string"},
+	{"messageVersion", "string"},
+	{"message", "object"},
+	{"metadata", "object"},
+}
+
+// decodeEnvelope validates raw against the default envelope schema and decodes it in a single step.
+// It classifies failures like schema validation followed by json.Unmarshal would: malformed JSON and
+// schema violations are FailEnvelopeSchema (ErrInvalidEnvelope), while a valid envelope whose metadata
+// does not fit MessageMetadata is FailEnvelopeParse (ErrFailedToParseEnvelope).
+func decodeEnvelope(raw []byte) (MessageEnvelope, FailureKind, error) {
+	var env MessageEnvelope
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return env, FailEnvelopeSchema, envelopeSchemaError("(root): Invalid type. Expected: object, given: " + typeErr.Value)
+		}
+		return env, FailEnvelopeSchema, fmt.Errorf("%w: %v", ErrInvalidEnvelope, fmt.Errorf("%w: %v", jsonschema.ErrSchemaValidationSystem, err))
+	}
+	if fields == nil {
+		return env, FailEnvelopeSchema, envelopeSchemaError("(root): Invalid type. Expected: object, given: null")
+	}
+
+	var desc string
+	for _, f := range envelopeFields {
+		v, ok := fields[f.name]
+		switch {
+		case !ok:
+			desc += fmt.Sprintf("- (root): %s is required; ", f.name)
+		case jsonTypeOf(v) != f.jsonType:
+			desc += fmt.Sprintf("- %s: Invalid type. Expected: %s, given: %s; ", f.name, f.jsonType, jsonTypeOf(v))
+		}
+	}
+	if desc != "" {
+		return env, FailEnvelopeSchema, fmt.Errorf("%w: %v", ErrInvalidEnvelope, fmt.Errorf("%w: %s", jsonschema.ErrSchemaValidationFailed, desc))
+	}
+
+	// The values are known to be well-formed strings and objects, so in practice only metadata can fail to decode.
+	env.Message = fields["message"]
+	if err := errors.Join(
+		json.Unmarshal(fields["schemaVersion"], &env.SchemaVersion),
+		json.Unmarshal(fields["messageType"], &env.MessageType),
+		json.Unmarshal(fields["messageVersion"], &env.MessageVersion),
+		json.Unmarshal(fields["metadata"], &env.Metadata),
+	); err != nil {
+		return env, FailEnvelopeParse, fmt.Errorf("%w: %v", ErrFailedToParseEnvelope, err)
+	}
+	return env, FailNone, nil
+}
+
+// envelopeSchemaError formats a single schema violation like jsonschema.FormatErrors does.
+func envelopeSchemaError(desc string) error {
+	return fmt.Errorf("%w: %v", ErrInvalidEnvelope, fmt.Errorf("%w: - %s; ", jsonschema.ErrSchemaValidationFailed, desc))
+}
+
+// jsonTypeOf returns the JSON Schema type name of a well-formed JSON value.
+func jsonTypeOf(v json.RawMessage) string {
+	v = bytes.TrimLeft(v, " \t\r\n")
+	if len(v) == 0 {
+		return "null"
+	}
+	switch v[0] {
+	case '"':
+		return "string"
+	case '{':
+		return "object"
+	case '[':
+		return "array"
+	case 't', 'f':
+		return "boolean"
+	case 'n':
+		return "null"
+	}
+	if bytes.ContainsAny(v, ".eE") {
+		return "number"
+	}
+	return "integer"
+}
+
+// EnvelopeCodec extracts the message type, version, payload and metadata from a raw SQS message body.
+// The router uses NewJSONEnvelopeCodec for the envelope schema passed to NewRouter unless WithEnvelopeCodec
+// sets another codec, e.g. a MappedEnvelopeCodec for producers with a different message format.
+//
+// Decode should return errors wrapping ErrInvalidEnvelope when raw does not have the expected structure;
+// they are reported as FailEnvelopeSchema. Any other error is reported as FailEnvelopeParse and wrapped in
+// ErrFailedToParseEnvelope unless it already wraps it. Implementations must be safe for concurrent use.
+type EnvelopeCodec interface {
+	Decode(raw []byte) (MessageEnvelope, error)
+}
+
+// JSONEnvelopeCodec decodes the sqsrouter envelope format after validating it against an envelope schema.
+type JSONEnvelopeCodec struct {
+	schema *gojsonschema.Schema
+	// native is set when schema is the default one, which decodeEnvelope implements natively.
+	native bool
+}
+
+// NewJSONEnvelopeCodec compiles envelopeSchema, usually EnvelopeSchema, into a JSONEnvelopeCodec.
+// The default schema is handled by a native decoder; any other schema is validated with JSON Schema before
+// the envelope is unmarshaled.
+func NewJSONEnvelopeCodec(envelopeSchema string) (*JSONEnvelopeCodec, error) {
+	compiled, err := jsonschema.NewSchema(jsonschema.NewStringLoader(envelopeSchema))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEnvelopeSchema, err)
+	}
+	return &JSONEnvelopeCodec{schema: compiled, native: isDefaultEnvelopeSchema(envelopeSchema)}, nil
+}
+
+// Decode implements EnvelopeCodec.
+func (c *JSONEnvelopeCodec) Decode(raw []byte) (MessageEnvelope, error) {
+	if c.native {
+		envelope, _, err := decodeEnvelope(raw)
+		return envelope, err
+	}
+
+	var envelope MessageEnvelope
+	res, err := jsonschema.ValidateSchema(c.schema, jsonschema.NewBytesLoader(raw))
+	if validationErr := jsonschema.FormatErrors(res, err); validationErr != nil {
+		return envelope, fmt.Errorf("%w: %v", ErrInvalidEnvelope, validationErr)
+	}
+	if err := json.Unmarshal(raw, &envelope); err != nil {
+		return envelope, fmt.Errorf("%w: %v", ErrFailedToParseEnvelope, err)
+	}
+	return envelope, nil
+}
+
+// parseEnvelope decodes the raw envelope with the router's codec and classifies failures.
+func (r *Router) parseEnvelope(raw []byte) (MessageEnvelope, FailureKind, error) {
+	envelope, err := r.envelopeCodec.Decode(raw)
+	switch {
+	case err == nil:
+		return envelope, FailNone, nil
+	case errors.Is(err, ErrInvalidEnvelope):
+		return envelope, FailEnvelopeSchema, err
+	case errors.Is(err, ErrFailedToParseEnvelope):
+		return envelope, FailEnvelopeParse, err
+	default:
+		return envelope, FailEnvelopeParse, fmt.Errorf("%w: %v", ErrFailedToParseEnvelope, err)
+	}
+}
+
+// metadataWithExtensions is the metadata JSON passed to handlers and predicates. Extensions are encoded
+// here rather than through the MessageMetadata field, which is excluded from decoding.
+type metadataWithExtensions struct {
+	MessageMetadata
+	Extensions map[string]any `json:"extensions,omitempty"`
+}
+
+// marshalMetadata encodes metadata for handlers and predicates, including its extensions.
+func marshalMetadata(meta MessageMetadata) ([]byte, error) {
+	return json.Marshal(metadataWithExtensions{MessageMetadata: meta, Extensions: meta.Extensions})
+}
+
+// unmarshalMetadata decodes metadata encoded by marshalMetadata.
+func unmarshalMetadata(data []byte, meta *MessageMetadata) error {
+	var v metadataWithExtensions
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*meta = v.MessageMetadata
+	meta.Extensions = v.Extensions
+	return nil
+}
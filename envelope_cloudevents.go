@@ -0,0 +1,220 @@
+package sqsrouter
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// CloudEventsSpecVersion is the CloudEvents specification version CloudEventsCodec accepts.
+const CloudEventsSpecVersion = "1.0"
+
+// DefaultCloudEventsVersionAttribute is the extension attribute CloudEventsCodec reads message versions from
+// unless configured otherwise.
+const DefaultCloudEventsVersionAttribute = "dataversion"
+
+// cloudEventsMappedAttributes are the attributes CloudEventsCodec maps onto envelope fields.
+// All other attributes are kept in MessageMetadata.Extensions.
+var cloudEventsMappedAttributes = map[string]bool{
+	"specversion": true, "id": true, "source": true, "type": true, "time": true, "data": true, "data_base64": true,
+}
+
+// CloudEventsOption configures a CloudEventsCodec.
+type CloudEventsOption func(*cloudEventsConfig)
+
+type cloudEventsConfig struct {
+	versionAttribute  string
+	versionDataSchema bool
+	defaultVersion    string
+}
+
+// CloudEventsVersionFromExtension reads the message version from the named extension attribute,
+// a string or an integer. The default is DefaultCloudEventsVersionAttribute.
+func CloudEventsVersionFromExtension(name string) CloudEventsOption {
+	return func(c *cloudEventsConfig) { c.versionAttribute, c.versionDataSchema = name, false }
+}
+
+// CloudEventsVersionFromDataSchema reads the message version from the last path segment of the dataschema
+// URI, without a ".json" suffix: "https://schemas.example.com/orders/v2" and ".../orders/v2.json" are "v2".
+// The segment is only taken as a version when it starts with a digit, or with "v" or "V" and a digit, so
+// ".../schemas/orders" has no version: such events get the CloudEventsDefaultVersion, or fail without one.
+func CloudEventsVersionFromDataSchema() CloudEventsOption {
+	return func(c *cloudEventsConfig) { c.versionAttribute, c.versionDataSchema = "", true }
+}
+
+// CloudEventsDefaultVersion sets the version of events that do not carry one. Without it such events
+// fail with ErrInvalidEnvelope.
+func CloudEventsDefaultVersion(version string) CloudEventsOption {
+	return func(c *cloudEventsConfig) { c.defaultVersion = version }
+}
+
+// CloudEventsCodec is an EnvelopeCodec for CloudEvents 1.0 in structured JSON mode:
+//
+//	router, err := sqsrouter.NewRouter(sqsrouter.EnvelopeSchema,
+//		sqsrouter.WithEnvelopeCodec(sqsrouter.NewCloudEventsCodec(sqsrouter.CloudEventsDefaultVersion("1.0"))))
+//
+// Events are routed on type and the version read from the configured source. The event data is the payload,
+// so it is validated against the schema registered for the type and version; data_base64 is decoded and must
+// hold JSON, and an event without data has the payload null. specversion becomes the envelope SchemaVersion,
+// and id, source and time become the metadata MessageID, Source and Timestamp. The optional subject,
+// dataschema and datacontenttype attributes and all extension attributes are kept in the metadata Extensions.
+//
+// Events that are not valid CloudEvents 1.0, lack a version or carry both data and data_base64 fail with
+// ErrInvalidEnvelope (FailEnvelopeSchema).
+type CloudEventsCodec struct {
+	cfg cloudEventsConfig
+}
+
+// NewCloudEventsCodec returns a CloudEventsCodec configured by opts.
+func NewCloudEventsCodec(opts ...CloudEventsOption) *CloudEventsCodec {
+	cfg := cloudEventsConfig{versionAttribute: DefaultCloudEventsVersionAttribute}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return &CloudEventsCodec{cfg: cfg}
+}
+
+// Decode implements EnvelopeCodec.
+func (c *CloudEventsCodec) Decode(raw []byte) (MessageEnvelope, error) {
+	var env MessageEnvelope
+	var attrs map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &attrs); err != nil || attrs == nil {
+		return env, fmt.Errorf("%w: a CloudEvent must be a JSON object", ErrInvalidEnvelope)
+	}
+
+	var err error
+	if env.SchemaVersion, err = cloudEventString(attrs, "specversion", true); err != nil {
+		return env, err
+	}
+	if env.SchemaVersion != CloudEventsSpecVersion {
+		return env, fmt.Errorf("%w: unsupported CloudEvents specversion %q", ErrInvalidEnvelope, env.SchemaVersion)
+	}
+	if env.MessageType, err = cloudEventString(attrs, "type", true); err != nil {
+		return env, err
+	}
+	if env.Metadata.MessageID, err = cloudEventString(attrs, "id", true); err != nil {
+		return env, err
+	}
+	if env.Metadata.Source, err = cloudEventString(attrs, "source", true); err != nil {
+		return env, err
+	}
+	if env.Metadata.Timestamp, err = cloudEventString(attrs, "time", false); err != nil {
+		return env, err
+	}
+	if env.MessageVersion, err = c.version(attrs); err != nil {
+		return env, err
+	}
+	if env.Message, err = cloudEventData(attrs); err != nil {
+		return env, err
+	}
+
+	for name, v := range attrs {
+		if cloudEventsMappedAttributes[name] {
+			continue
+		}
+		var value any
+		if err := json.Unmarshal(v, &value); err != nil {
+			return env, fmt.Errorf("%w: attribute %s: %v", ErrFailedToParseEnvelope, name, err)
+		}
+		if env.Metadata.Extensions == nil {
+			env.Metadata.Extensions = make(map[string]any)
+		}
+		env.Metadata.Extensions[name] = value
+	}
+	return env, nil
+}
+
+// version returns the message version of the event from the configured source.
+func (c *CloudEventsCodec) version(attrs map[string]json.RawMessage) (string, error) {
+	var version string
+	switch {
+	case c.cfg.versionDataSchema:
+		schema, err := cloudEventString(attrs, "dataschema", false)
+		if err != nil {
+			return "", err
+		}
+		version = dataSchemaVersion(schema)
+	case c.cfg.versionAttribute != "":
+		if v, ok := attrs[c.cfg.versionAttribute]; ok {
+			switch jsonTypeOf(v) {
+			case "string":
+				if err := json.Unmarshal(v, &version); err != nil {
+					return "", fmt.Errorf("%w: attribute %s: %v", ErrInvalidEnvelope, c.cfg.versionAttribute, err)
+				}
+			case "integer":
+				version = string(bytes.TrimSpace(v))
+			default:
+				return "", fmt.Errorf("%w: attribute %s must be a string or integer, given: %s", ErrInvalidEnvelope, c.cfg.versionAttribute, jsonTypeOf(v))
+			}
+		}
+	}
+	if version == "" {
+		version = c.cfg.defaultVersion
+	}
+	if version == "" {
+		return "", fmt.Errorf("%w: the CloudEvent has no message version", ErrInvalidEnvelope)
+	}
+	return version, nil
+}
+
+// dataSchemaVersion returns the last path segment of a dataschema URI without a ".json" suffix, or empty
+// when there is none or it does not look like a version: a digit, or "v" or "V" followed by a digit.
+func dataSchemaVersion(schema string) string {
+	u, err := url.Parse(schema)
+	if err != nil || u.Path == "" {
+		return ""
+	}
+	base := strings.TrimSuffix(path.Base(u.Path), ".json")
+	digits := strings.TrimPrefix(strings.TrimPrefix(base, "v"), "V")
+	if digits == "" || digits[0] < '0' || digits[0] > '9' || len(base)-len(digits) > 1 {
+		return ""
+	}
+	return base
+}
+
+// cloudEventString returns a string attribute; required attributes must be present and not empty.
+func cloudEventString(attrs map[string]json.RawMessage, name string, required bool) (string, error) {
+	v, ok := attrs[name]
+	if !ok {
+		if required {
+			return "", fmt.Errorf("%w: CloudEvents attribute %s is required", ErrInvalidEnvelope, name)
+		}
+		return "", nil
+	}
+	var s string
+	if jsonTypeOf(v) != "string" || json.Unmarshal(v, &s) != nil {
+		return "", fmt.Errorf("%w: CloudEvents attribute %s must be a string, given: %s", ErrInvalidEnvelope, name, jsonTypeOf(v))
+	}
+	if required && s == "" {
+		return "", fmt.Errorf("%w: CloudEvents attribute %s must not be empty", ErrInvalidEnvelope, name)
+	}
+	return s, nil
+}
+
+// cloudEventData returns the event data as JSON, decoding data_base64.
+func cloudEventData(attrs map[string]json.RawMessage) (json.RawMessage, error) {
+	data, hasData := attrs["data"]
+	encoded, hasBase64 := attrs["data_base64"]
+	switch {
+	case hasData && hasBase64:
+		return nil, fmt.Errorf("%w: a CloudEvent must not carry both data and data_base64", ErrInvalidEnvelope)
+	case hasData:
+		return data, nil
+	case hasBase64:
+		var s string
+		if jsonTypeOf(encoded) != "string" || json.Unmarshal(encoded, &s) != nil {
+			return nil, fmt.Errorf("%w: CloudEvents attribute data_base64 must be a string", ErrInvalidEnvelope)
+		}
+		decoded, err := base64.StdEncoding.DecodeString(s)
+		if err != nil || !json.Valid(decoded) {
+			return nil, fmt.Errorf("%w: data_base64 must hold base64-encoded JSON", ErrInvalidEnvelope)
+		}
+		return decoded, nil
+	default:
+		return json.RawMessage("null"), nil
+	}
+}
@@ -0,0 +1,138 @@
+package sqsrouter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const cloudEvent = `{"specversion":"1.0","type":"com.example.order.placed","source":"/shop","id":"e-1",` +
+	`"time":"2024-01-01T00:00:00Z","subject":"order-7","dataversion":"v2","tenant":"acme","data":{"orderId":"o-7"}}`
+
+func TestCloudEventsCodec_Decode(t *testing.T) {
+	got, err := NewCloudEventsCodec().Decode([]byte(cloudEvent))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	want := MessageEnvelope{
+		SchemaVersion:  "1.0",
+		MessageType:    "com.example.order.placed",
+		MessageVersion: "v2",
+		Message:        json.RawMessage(`{"orderId":"o-7"}`),
+		Metadata: MessageMetadata{
+			Timestamp:  "2024-01-01T00:00:00Z",
+			Source:     "/shop",
+			MessageID:  "e-1",
+			Extensions: map[string]any{"subject": "order-7", "dataversion": "v2", "tenant": "acme"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestCloudEventsCodec_Version(t *testing.T) {
+	const base = `"specversion":"1.0","type":"T","source":"s","id":"1","data":{}`
+	cases := []struct {
+		name  string
+		codec *CloudEventsCodec
+		raw   string
+		want  string
+	}{
+		{"integer extension", NewCloudEventsCodec(), `{` + base + `,"dataversion":3}`, "3"},
+		{"named extension", NewCloudEventsCodec(CloudEventsVersionFromExtension("schemaver")), `{` + base + `,"schemaver":"1.2"}`, "1.2"},
+		{"default", NewCloudEventsCodec(CloudEventsDefaultVersion("v1")), `{` + base + `}`, "v1"},
+		{"data schema", NewCloudEventsCodec(CloudEventsVersionFromDataSchema()), `{` + base + `,"dataschema":"https://schemas.example.com/orders/v4.json"}`, "v4"},
+		{"data schema without path", NewCloudEventsCodec(CloudEventsVersionFromDataSchema(), CloudEventsDefaultVersion("v1")), `{` + base + `,"dataschema":"https://schemas.example.com"}`, "v1"},
+		{"data schema without version", NewCloudEventsCodec(CloudEventsVersionFromDataSchema(), CloudEventsDefaultVersion("v1")), `{` + base + `,"dataschema":"https://schemas.example.com/schemas/orders"}`, "v1"},
+		{"numeric data schema", NewCloudEventsCodec(CloudEventsVersionFromDataSchema()), `{` + base + `,"dataschema":"https://schemas.example.com/orders/2.1.json"}`, "2.1"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			env, err := tc.codec.Decode([]byte(tc.raw))
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if env.MessageVersion != tc.want {
+				t.Errorf("version = %q, want %q", env.MessageVersion, tc.want)
+			}
+		})
+	}
+}
+
+func TestCloudEventsCodec_Data(t *testing.T) {
+	codec := NewCloudEventsCodec(CloudEventsDefaultVersion("v1"))
+	const base = `"specversion":"1.0","type":"T","source":"s","id":"1"`
+
+	env, err := codec.Decode([]byte(`{` + base + `,"data_base64":"eyJhIjoxfQ=="}`))
+	if err != nil || string(env.Message) != `{"a":1}` {
+		t.Errorf("data_base64: message %s, err %v", env.Message, err)
+	}
+	env, err = codec.Decode([]byte(`{` + base + `}`))
+	if err != nil || string(env.Message) != `null` {
+		t.Errorf("no data: message %s, err %v", env.Message, err)
+	}
+}
+
+func TestCloudEventsCodec_Invalid(t *testing.T) {
+	codec := NewCloudEventsCodec()
+	for name, raw := range map[string]string{
+		"not an object":       `[]`,
+		"missing specversion": `{"type":"T","source":"s","id":"1","dataversion":"v1"}`,
+		"wrong specversion":   `{"specversion":"0.3","type":"T","source":"s","id":"1","dataversion":"v1"}`,
+		"missing id":          `{"specversion":"1.0","type":"T","source":"s","dataversion":"v1"}`,
+		"empty type":          `{"specversion":"1.0","type":"","source":"s","id":"1","dataversion":"v1"}`,
+		"numeric source":      `{"specversion":"1.0","type":"T","source":1,"id":"1","dataversion":"v1"}`,
+		"no version":          `{"specversion":"1.0","type":"T","source":"s","id":"1"}`,
+		"boolean version":     `{"specversion":"1.0","type":"T","source":"s","id":"1","dataversion":true}`,
+		"data and base64":     `{"specversion":"1.0","type":"T","source":"s","id":"1","dataversion":"v1","data":{},"data_base64":"e30="}`,
+		"base64 not JSON":     `{"specversion":"1.0","type":"T","source":"s","id":"1","dataversion":"v1","data_base64":"aGVsbG8="}`,
+	} {
+		if _, err := codec.Decode([]byte(raw)); !errors.Is(err, ErrInvalidEnvelope) {
+			t.Errorf("%s: err = %v, want ErrInvalidEnvelope", name, err)
+		}
+	}
+
+	schemaCodec := NewCloudEventsCodec(CloudEventsVersionFromDataSchema())
+	raw := `{"specversion":"1.0","type":"T","source":"s","id":"1","dataschema":"https://schemas.example.com/schemas/orders"}`
+	if _, err := schemaCodec.Decode([]byte(raw)); !errors.Is(err, ErrInvalidEnvelope) {
+		t.Errorf("data schema without version: err = %v, want ErrInvalidEnvelope", err)
+	}
+}
+
+func TestCloudEventsCodec_Route(t *testing.T) {
+	r, err := NewRouter(EnvelopeSchema, WithEnvelopeCodec(NewCloudEventsCodec()))
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+	if err := r.RegisterSchema("com.example.order.placed", "v2", `{"type":"object","required":["orderId"]}`); err != nil {
+		t.Fatalf("register schema: %v", err)
+	}
+	var meta struct {
+		MessageID  string         `json:"messageId"`
+		Extensions map[string]any `json:"extensions"`
+	}
+	r.Register("com.example.order.placed", "v2", func(_ context.Context, _ []byte, metaJSON []byte) HandlerResult {
+		if err := json.Unmarshal(metaJSON, &meta); err != nil {
+			t.Errorf("metadata: %v", err)
+		}
+		return HandlerResult{ShouldDelete: true}
+	})
+
+	rr := r.Route(context.Background(), []byte(cloudEvent))
+	if rr.FailureKind != FailNone || rr.MessageID != "e-1" {
+		t.Fatalf("unexpected result: %+v", rr)
+	}
+	if meta.Extensions["tenant"] != "acme" {
+		t.Errorf("extensions = %v", meta.Extensions)
+	}
+
+	// The data is validated against the payload schema.
+	invalid := `{"specversion":"1.0","type":"com.example.order.placed","source":"/shop","id":"e-2","dataversion":"v2","data":{}}`
+	rr = r.Route(context.Background(), []byte(invalid))
+	if rr.FailureKind != FailPayloadSchema {
+		t.Errorf("kind = %v, want FailPayloadSchema", rr.FailureKind)
+	}
+}
@@ -0,0 +1,215 @@
+package sqsrouter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// EnvelopeMapping locates the envelope fields of another message format with JSON pointers (RFC 6901),
+// e.g. "/detail-type" or "/header/version". Except for MessageType and Message, a pointer left empty
+// means the format has no such field.
+type EnvelopeMapping struct {
+	// MessageType locates the message type, a string. It is required.
+	MessageType string
+	// MessageVersion locates the message version, a string or a number.
+	MessageVersion string
+	// DefaultVersion is the version of messages without one, or with an empty one.
+	DefaultVersion string
+	// Message locates the payload, which must be an object. The empty pointer is the whole message.
+	Message string
+	// Metadata locates an object decoded into MessageMetadata.
+	Metadata string
+	// MessageID, Timestamp and Source locate individual metadata strings. They take precedence over the
+	// fields of Metadata.
+	MessageID string
+	Timestamp string
+	Source    string
+	// SchemaVersion locates the envelope schema version, a string.
+	SchemaVersion string
+}
+
+// MappedEnvelopeCodec is an EnvelopeCodec for JSON messages whose routing fields have other names or
+// nesting than the sqsrouter envelope, e.g.
+//
+//	codec, err := sqsrouter.NewMappedEnvelopeCodec(sqsrouter.EnvelopeMapping{
+//		MessageType:    "/header/type",
+//		MessageVersion: "/header/version",
+//		Message:        "/body",
+//		MessageID:      "/header/id",
+//	})
+//	router, err := sqsrouter.NewRouter(sqsrouter.EnvelopeSchema, sqsrouter.WithEnvelopeCodec(codec))
+//
+// Messages must be JSON objects. A missing or mistyped message type, version or payload fails with
+// ErrInvalidEnvelope (FailEnvelopeSchema); mistyped metadata fails with ErrFailedToParseEnvelope
+// (FailEnvelopeParse). The payload is passed on with its original encoding.
+type MappedEnvelopeCodec struct {
+	mapping EnvelopeMapping
+	// Parsed pointers; nil for unmapped fields and for the empty Message pointer.
+	messageType, messageVersion, message, metadata []string
+	messageID, timestamp, source, schemaVersion    []string
+}
+
+// NewMappedEnvelopeCodec returns a MappedEnvelopeCodec for m, or ErrInvalidEnvelopeMapping when
+// MessageType is empty, neither MessageVersion nor DefaultVersion is set, or a pointer is invalid.
+func NewMappedEnvelopeCodec(m EnvelopeMapping) (*MappedEnvelopeCodec, error) {
+	if m.MessageType == "" {
+		return nil, fmt.Errorf("%w: MessageType is required", ErrInvalidEnvelopeMapping)
+	}
+	if m.MessageVersion == "" && m.DefaultVersion == "" {
+		return nil, fmt.Errorf("%w: MessageVersion or DefaultVersion is required", ErrInvalidEnvelopeMapping)
+	}
+	c := &MappedEnvelopeCodec{mapping: m}
+	fields := []struct {
+		name    string
+		pointer string
+		tokens  *[]string
+	}{
+		{"MessageType", m.MessageType, &c.messageType},
+		{"MessageVersion", m.MessageVersion, &c.messageVersion},
+		{"Message", m.Message, &c.message},
+		{"Metadata", m.Metadata, &c.metadata},
+		{"MessageID", m.MessageID, &c.messageID},
+		{"Timestamp", m.Timestamp, &c.timestamp},
+		{"Source", m.Source, &c.source},
+		{"SchemaVersion", m.SchemaVersion, &c.schemaVersion},
+	}
+	for _, f := range fields {
+		tokens, err := parseJSONPointer(f.pointer)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidEnvelopeMapping, f.name, err)
+		}
+		*f.tokens = tokens
+	}
+	return c, nil
+}
+
+// Decode implements EnvelopeCodec.
+func (c *MappedEnvelopeCodec) Decode(raw []byte) (MessageEnvelope, error) {
+	var env MessageEnvelope
+	var root map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &root); err != nil || root == nil {
+		return env, fmt.Errorf("%w: message must be a JSON object", ErrInvalidEnvelope)
+	}
+	doc := rawDocument{raw: raw, root: root}
+
+	v, ok := doc.lookup(c.messageType)
+	if !ok {
+		return env, fmt.Errorf("%w: message type %q is required", ErrInvalidEnvelope, c.mapping.MessageType)
+	}
+	if jsonTypeOf(v) != "string" || json.Unmarshal(v, &env.MessageType) != nil {
+		return env, fmt.Errorf("%w: message type %q must be a string, given: %s", ErrInvalidEnvelope, c.mapping.MessageType, jsonTypeOf(v))
+	}
+
+	env.MessageVersion = c.mapping.DefaultVersion
+	if c.messageVersion != nil {
+		if v, ok := doc.lookup(c.messageVersion); ok {
+			var version string
+			switch jsonTypeOf(v) {
+			case "string":
+				if err := json.Unmarshal(v, &version); err != nil {
+					return env, fmt.Errorf("%w: message version %q: %v", ErrInvalidEnvelope, c.mapping.MessageVersion, err)
+				}
+			case "number", "integer":
+				version = string(bytes.TrimSpace(v))
+			default:
+				return env, fmt.Errorf("%w: message version %q must be a string or number, given: %s", ErrInvalidEnvelope, c.mapping.MessageVersion, jsonTypeOf(v))
+			}
+			if version != "" {
+				env.MessageVersion = version
+			}
+		}
+	}
+	if env.MessageVersion == "" {
+		return env, fmt.Errorf("%w: message version %q is required", ErrInvalidEnvelope, c.mapping.MessageVersion)
+	}
+
+	v, ok = doc.lookup(c.message)
+	if !ok || jsonTypeOf(v) != "object" {
+		return env, fmt.Errorf("%w: message %q must be an object", ErrInvalidEnvelope, c.mapping.Message)
+	}
+	env.Message = v
+
+	if c.schemaVersion != nil {
+		if v, ok := doc.lookup(c.schemaVersion); ok {
+			if jsonTypeOf(v) != "string" || json.Unmarshal(v, &env.SchemaVersion) != nil {
+				return env, fmt.Errorf("%w: schema version %q must be a string, given: %s", ErrInvalidEnvelope, c.mapping.SchemaVersion, jsonTypeOf(v))
+			}
+		}
+	}
+
+	if c.metadata != nil {
+		if v, ok := doc.lookup(c.metadata); ok {
+			if err := json.Unmarshal(v, &env.Metadata); err != nil {
+				return env, fmt.Errorf("%w: %v", ErrFailedToParseEnvelope, err)
+			}
+		}
+	}
+	for _, f := range []struct {
+		tokens []string
+		dst    *string
+	}{
+		{c.messageID, &env.Metadata.MessageID},
+		{c.timestamp, &env.Metadata.Timestamp},
+		{c.source, &env.Metadata.Source},
+	} {
+		if f.tokens == nil {
+			continue
+		}
+		if v, ok := doc.lookup(f.tokens); ok {
+			if err := json.Unmarshal(v, f.dst); err != nil {
+				return env, fmt.Errorf("%w: %v", ErrFailedToParseEnvelope, err)
+			}
+		}
+	}
+	return env, nil
+}
+
+// rawDocument resolves JSON pointers in a JSON object without decoding the values they refer to.
+type rawDocument struct {
+	raw  json.RawMessage
+	root map[string]json.RawMessage
+}
+
+// lookup returns the raw value tokens refer to; the empty pointer is the whole document.
+func (d rawDocument) lookup(tokens []string) (json.RawMessage, bool) {
+	if len(tokens) == 0 {
+		return d.raw, true
+	}
+	v, ok := d.root[tokens[0]]
+	if !ok {
+		return nil, false
+	}
+	return lookupRawJSON(v, tokens[1:])
+}
+
+// lookupRawJSON returns the raw value tokens refer to in v, decoding only the objects and arrays on the way.
+func lookupRawJSON(v json.RawMessage, tokens []string) (json.RawMessage, bool) {
+	for _, tok := range tokens {
+		switch jsonTypeOf(v) {
+		case "object":
+			var node map[string]json.RawMessage
+			if json.Unmarshal(v, &node) != nil {
+				return nil, false
+			}
+			next, ok := node[tok]
+			if !ok {
+				return nil, false
+			}
+			v = next
+		case "array":
+			var node []json.RawMessage
+			if json.Unmarshal(v, &node) != nil {
+				return nil, false
+			}
+			i, ok := jsonPointerIndex(tok, len(node))
+			if !ok {
+				return nil, false
+			}
+			v = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
@@ -0,0 +1,114 @@
+package sqsrouter
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMappedEnvelopeCodec_Decode(t *testing.T) {
+	codec, err := NewMappedEnvelopeCodec(EnvelopeMapping{
+		MessageType:    "/header/type",
+		MessageVersion: "/header/version",
+		DefaultVersion: "1",
+		Message:        "/body",
+		Metadata:       "/meta",
+		MessageID:      "/header/ids/0",
+		SchemaVersion:  "/spec",
+	})
+	if err != nil {
+		t.Fatalf("new codec: %v", err)
+	}
+
+	cases := []struct {
+		name string
+		raw  string
+		want MessageEnvelope
+		err  error
+	}{
+		{
+			name: "all fields",
+			raw:  `{"spec":"2","header":{"type":"OrderPlaced","version":"3","ids":["h-1"]},"body":{"id": 1},"meta":{"source":"shop","messageId":"m-1"}}`,
+			want: MessageEnvelope{SchemaVersion: "2", MessageType: "OrderPlaced", MessageVersion: "3", Message: []byte(`{"id": 1}`),
+				Metadata: MessageMetadata{Source: "shop", MessageID: "h-1"}},
+		},
+		{
+			name: "numeric version",
+			raw:  `{"header":{"type":"OrderPlaced","version":2},"body":{}}`,
+			want: MessageEnvelope{MessageType: "OrderPlaced", MessageVersion: "2", Message: []byte(`{}`)},
+		},
+		{
+			name: "default version",
+			raw:  `{"header":{"type":"OrderPlaced"},"body":{}}`,
+			want: MessageEnvelope{MessageType: "OrderPlaced", MessageVersion: "1", Message: []byte(`{}`)},
+		},
+		{"not an object", `[1]`, MessageEnvelope{}, ErrInvalidEnvelope},
+		{"malformed", `{"header":`, MessageEnvelope{}, ErrInvalidEnvelope},
+		{"missing type", `{"header":{},"body":{}}`, MessageEnvelope{}, ErrInvalidEnvelope},
+		{"type is a number", `{"header":{"type":1},"body":{}}`, MessageEnvelope{}, ErrInvalidEnvelope},
+		{"version is an object", `{"header":{"type":"T","version":{}},"body":{}}`, MessageEnvelope{}, ErrInvalidEnvelope},
+		{"missing body", `{"header":{"type":"T"}}`, MessageEnvelope{}, ErrInvalidEnvelope},
+		{"body is a string", `{"header":{"type":"T"},"body":"x"}`, MessageEnvelope{}, ErrInvalidEnvelope},
+		{"spec is a number", `{"spec":2,"header":{"type":"T"},"body":{}}`, MessageEnvelope{}, ErrInvalidEnvelope},
+		{"metadata mistyped", `{"header":{"type":"T"},"body":{},"meta":{"source":1}}`, MessageEnvelope{}, ErrFailedToParseEnvelope},
+		{"message id mistyped", `{"header":{"type":"T","ids":[1]},"body":{}}`, MessageEnvelope{}, ErrFailedToParseEnvelope},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := codec.Decode([]byte(tc.raw))
+			if tc.err != nil {
+				if !errors.Is(err, tc.err) {
+					t.Fatalf("err = %v, want %v", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if got.SchemaVersion != tc.want.SchemaVersion || got.MessageType != tc.want.MessageType ||
+				got.MessageVersion != tc.want.MessageVersion || string(got.Message) != string(tc.want.Message) ||
+				!reflect.DeepEqual(got.Metadata, tc.want.Metadata) {
+				t.Errorf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMappedEnvelopeCodec_WholeMessage(t *testing.T) {
+	codec, err := NewMappedEnvelopeCodec(EnvelopeMapping{MessageType: "/kind", DefaultVersion: "v1"})
+	if err != nil {
+		t.Fatalf("new codec: %v", err)
+	}
+	r, err := NewRouter(EnvelopeSchema, WithEnvelopeCodec(codec))
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+	var got string
+	r.Register("UserCreated", "v1", func(_ context.Context, msg []byte, _ []byte) HandlerResult {
+		got = string(msg)
+		return HandlerResult{ShouldDelete: true}
+	})
+
+	raw := `{"kind":"UserCreated","name":"Ada"}`
+	rr := r.Route(context.Background(), []byte(raw))
+
+	if rr.FailureKind != FailNone || !rr.HandlerResult.ShouldDelete {
+		t.Fatalf("unexpected result: %+v", rr)
+	}
+	if got != raw {
+		t.Errorf("payload = %s, want the whole message", got)
+	}
+}
+
+func TestNewMappedEnvelopeCodec_Invalid(t *testing.T) {
+	for name, m := range map[string]EnvelopeMapping{
+		"no type":         {DefaultVersion: "v1"},
+		"no version":      {MessageType: "/type"},
+		"invalid pointer": {MessageType: "/type", DefaultVersion: "v1", Message: "body"},
+	} {
+		if _, err := NewMappedEnvelopeCodec(m); !errors.Is(err, ErrInvalidEnvelopeMapping) {
+			t.Errorf("%s: err = %v, want ErrInvalidEnvelopeMapping", name, err)
+		}
+	}
+}
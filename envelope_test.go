@@ -0,0 +1,147 @@
+package sqsrouter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// envelopeCases covers valid envelopes and every way the default envelope schema can reject one.
+var envelopeCases = []struct {
+	name string
+	raw  string
+	kind FailureKind
+}{
+	{"valid", `{"schemaVersion":"1.0","messageType":"T","messageVersion":"v1","message":{"a":[1,2]},"metadata":{"messageId":"m","timestamp":"t","source":"s"}}`, FailNone},
+	{"valid with extra fields", `{"schemaVersion":"1.0","messageType":"T","messageVersion":"v1","message":{},"metadata":{"extra":1},"traceId":"x"}`, FailNone},
+	{"metadata extensions are ignored", `{"schemaVersion":"1.0","messageType":"T","messageVersion":"v1","message":{},"metadata":{"extensions":"x"}}`, FailNone},
+	{"malformed json", `{"schemaVersion":`, FailEnvelopeSchema},
+	{"not json", `hello`, FailEnvelopeSchema},
+	{"array", `[1,2]`, FailEnvelopeSchema},
+	{"string", `"envelope"`, FailEnvelopeSchema},
+	{"null", `null`, FailEnvelopeSchema},
+	{"missing messageType", `{"schemaVersion":"1.0","messageVersion":"v1","message":{},"metadata":{}}`, FailEnvelopeSchema},
+	{"missing everything", `{}`, FailEnvelopeSchema},
+	{"messageType is a number", `{"schemaVersion":"1.0","messageType":1,"messageVersion":"v1","message":{},"metadata":{}}`, FailEnvelopeSchema},
+	{"message is an array", `{"schemaVersion":"1.0","messageType":"T","messageVersion":"v1","message":[],"metadata":{}}`, FailEnvelopeSchema},
+	{"message is null", `{"schemaVersion":"1.0","messageType":"T","messageVersion":"v1","message":null,"metadata":{}}`, FailEnvelopeSchema},
+	{"metadata is a string", `{"schemaVersion":"1.0","messageType":"T","messageVersion":"v1","message":{},"metadata":"m"}`, FailEnvelopeSchema},
+	{"metadata field has wrong type", `{"schemaVersion":"1.0","messageType":"T","messageVersion":"v1","message":{},"metadata":{"messageId":42}}`, FailEnvelopeParse},
+}
+
+// TestDecodeEnvelopeMatchesSchemaValidation checks that the native decoder classifies and decodes
+// envelopes exactly like JSON Schema validation of the default schema followed by json.Unmarshal.
+func TestDecodeEnvelopeMatchesSchemaValidation(t *testing.T) {
+	native, err := NewRouter(EnvelopeSchema)
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+	// The same schema with a description is not recognized as the default and takes the JSON Schema path.
+	custom := strings.Replace(EnvelopeSchema, `"type": "object",`, `"type": "object", "description": "custom",`, 1)
+	schemaRouter, err := NewRouter(custom)
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+	nativeCodec, schemaCodec := native.envelopeCodec.(*JSONEnvelopeCodec), schemaRouter.envelopeCodec.(*JSONEnvelopeCodec)
+	if !nativeCodec.native || schemaCodec.native {
+		t.Fatalf("native = %v/%v, want true/false", nativeCodec.native, schemaCodec.native)
+	}
+
+	for _, tc := range envelopeCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotEnv, gotKind, gotErr := native.parseEnvelope([]byte(tc.raw))
+			wantEnv, wantKind, wantErr := schemaRouter.parseEnvelope([]byte(tc.raw))
+
+			if gotKind != tc.kind || wantKind != tc.kind {
+				t.Fatalf("kind native=%v schema=%v, want %v (native err: %v, schema err: %v)", gotKind, wantKind, tc.kind, gotErr, wantErr)
+			}
+			for _, sentinel := range []error{ErrInvalidEnvelope, ErrFailedToParseEnvelope} {
+				if errors.Is(gotErr, sentinel) != errors.Is(wantErr, sentinel) {
+					t.Errorf("errors.Is(%v): native=%v schema=%v", sentinel, gotErr, wantErr)
+				}
+			}
+			if tc.kind == FailNone && !reflect.DeepEqual(gotEnv, wantEnv) {
+				t.Errorf("envelope native=%+v schema=%+v", gotEnv, wantEnv)
+			}
+			// Schema violations are reported with the same descriptions gojsonschema produces.
+			if wantErr != nil && strings.Contains(wantErr.Error(), "schema validation failed") && gotErr.Error() != wantErr.Error() {
+				t.Errorf("error native=%q schema=%q", gotErr, wantErr)
+			}
+		})
+	}
+}
+
+func TestDecodeEnvelopeIgnoresExtensions(t *testing.T) {
+	raw := `{"schemaVersion":"1.0","messageType":"T","messageVersion":"v1","message":{},"metadata":{"messageId":"m","extensions":{"tenant":"acme"}}}`
+	env, kind, err := decodeEnvelope([]byte(raw))
+	if kind != FailNone || err != nil {
+		t.Fatalf("kind = %v, err = %v", kind, err)
+	}
+	if env.Metadata.MessageID != "m" || env.Metadata.Extensions != nil {
+		t.Errorf("metadata = %+v, want extensions left to codecs", env.Metadata)
+	}
+}
+
+func TestIsDefaultEnvelopeSchema(t *testing.T) {
+	if !isDefaultEnvelopeSchema(EnvelopeSchema) {
+		t.Fatal("EnvelopeSchema must be recognized")
+	}
+	compact := `{"$schema":"http://json-schema.org/draft-07/schema#","type":"object","properties":{"schemaVersion":{"type":"string"},"messageType":{"type":"string"},"messageVersion":{"type":"string"},"message":{"type":"object"},"metadata":{"type":"object"}},"required":["schemaVersion","messageType","messageVersion","message","metadata"]}`
+	if !isDefaultEnvelopeSchema(compact) {
+		t.Fatal("whitespace differences must not matter")
+	}
+	if isDefaultEnvelopeSchema(`{"type":"object"}`) {
+		t.Fatal("a different schema must not be recognized")
+	}
+}
+
+// codecFunc adapts a function to EnvelopeCodec.
+type codecFunc func(raw []byte) (MessageEnvelope, error)
+
+func (f codecFunc) Decode(raw []byte) (MessageEnvelope, error) { return f(raw) }
+
+func TestWithEnvelopeCodec(t *testing.T) {
+	boom := errors.New("boom")
+	cases := []struct {
+		name    string
+		err     error
+		kind    FailureKind
+		wantErr error
+	}{
+		{"decoded", nil, FailNone, nil},
+		{"invalid envelope", fmt.Errorf("%w: no type", ErrInvalidEnvelope), FailEnvelopeSchema, ErrInvalidEnvelope},
+		{"parse failure", fmt.Errorf("%w: bad metadata", ErrFailedToParseEnvelope), FailEnvelopeParse, ErrFailedToParseEnvelope},
+		{"other error", boom, FailEnvelopeParse, ErrFailedToParseEnvelope},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			codec := codecFunc(func(raw []byte) (MessageEnvelope, error) {
+				return MessageEnvelope{MessageType: "T", MessageVersion: "v1", Message: raw}, tc.err
+			})
+			r, err := NewRouter(EnvelopeSchema, WithEnvelopeCodec(codec))
+			if err != nil {
+				t.Fatalf("new router: %v", err)
+			}
+			var got []byte
+			r.Register("T", "v1", func(_ context.Context, msg []byte, _ []byte) HandlerResult {
+				got = msg
+				return HandlerResult{ShouldDelete: true}
+			})
+
+			rr := r.Route(context.Background(), []byte(`{"any":"format"}`))
+
+			if rr.FailureKind != tc.kind {
+				t.Fatalf("kind = %v, want %v (err: %v)", rr.FailureKind, tc.kind, rr.HandlerResult.Error)
+			}
+			if tc.wantErr != nil && !errors.Is(rr.HandlerResult.Error, tc.wantErr) {
+				t.Errorf("err = %v, want %v", rr.HandlerResult.Error, tc.wantErr)
+			}
+			if tc.err == nil && string(got) != `{"any":"format"}` {
+				t.Errorf("payload = %s", got)
+			}
+		})
+	}
+}
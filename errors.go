@@ -5,12 +5,21 @@ import "errors"
 var (
 	ErrInvalidEnvelopeSchema  = errors.New("invalid envelope schema")
 	ErrInvalidSchema          = errors.New("invalid schema")
+	ErrInvalidEnvelopeMapping = errors.New("invalid envelope mapping")
 	ErrSchemaValidationSystem = errors.New("schema validation system error")
 	ErrSchemaValidationFailed = errors.New("schema validation failed")
 	ErrInvalidEnvelope        = errors.New("invalid envelope")
 	ErrFailedToParseEnvelope  = errors.New("failed to parse envelope")
 	ErrInvalidMessagePayload  = errors.New("invalid message payload")
+	ErrFailedToDecodePayload  = errors.New("failed to decode message payload")
 	ErrNoHandlerRegistered    = errors.New("no handler registered")
+	ErrInvalidHandlerKey      = errors.New("invalid handler key")
+	ErrAmbiguousHandlerKey    = errors.New("ambiguous handler key")
+	ErrNoPredicateMatched     = errors.New("no predicate matched")
+	ErrInvalidCanarySplit     = errors.New("invalid canary split")
+	ErrInvalidFanOut          = errors.New("invalid fan-out registration")
+	ErrInvalidUpcaster        = errors.New("invalid upcaster")
+	ErrUpcastFailed           = errors.New("upcast failed")
 	ErrMiddleware             = errors.New("middleware")
 	ErrPanic                  = errors.New("panic recovered")
 )
@@ -16,19 +16,6 @@ import (
     "github.com/hatsunemiku3939/sqsrouter/consumer"
 )
 
-// --- Schemas ---
-
-var userProfileSchema = `{
-  "$schema": "http://json-schema.org/draft-07/schema#",
-  "type": "object",
-  "properties": {
-    "userId": { "type": "string" },
-    "username": { "type": "string" },
-    "email": { "type": "string", "format": "email" }
-  },
-  "required": ["userId", "username", "email"]
-}`
-
 // --- Constants for Message Types and Versions ---
 const (
 	MsgTypeUpdateUserProfile = "updateUserProfile"
@@ -38,10 +25,11 @@ const (
 // --- Message Payloads & Metadata ---
 
 // UserProfileMessage defines the structure for the "updateUserProfile" message payload.
+// Its payload schema is generated from the struct tags.
 type UserProfileMessage struct {
-	UserID   string `json:"userId"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
+	UserID   string `json:"userId" jsonschema:"required"`
+	Username string `json:"username" jsonschema:"required"`
+	Email    string `json:"email" jsonschema:"required,format=email"`
 }
 
 // --- Message Handlers ---
@@ -105,13 +93,24 @@ func main() {
 	}
 
 	router.Register(MsgTypeUpdateUserProfile, MsgVersion1_0, UpdateUserProfileV1Handler)
+	userProfileSchema, err := sqsrouter.GenerateSchema[UserProfileMessage]()
+	if err != nil {
+		log.Fatalf("FATAL: Could not generate schema: %v", err)
+	}
 	if err := router.RegisterSchema(MsgTypeUpdateUserProfile, MsgVersion1_0, userProfileSchema); err != nil {
 		log.Fatalf("FATAL: Could not register schema: %v", err)
 	}
 
 	// --- 4. Setup and Start the Consumer ---
-    c := consumer.NewConsumer(sqsClient, queueURL, router)
-    c.Start(appCtx)
+	consumerCfg, err := consumer.ConfigFromEnv()
+	if err != nil {
+		log.Fatalf("FATAL: Invalid consumer configuration: %v", err)
+	}
+	c, err := consumer.NewConsumer(sqsClient, queueURL, router, consumer.WithConfig(consumerCfg))
+	if err != nil {
+		log.Fatalf("FATAL: Could not initialize consumer: %v", err)
+	}
+	c.Start(appCtx)
 
 	log.Println("Application has shut down.")
 }
@@ -1,6 +1,9 @@
 package sqsrouter
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // FailureKind enumerates where in the pipeline a failure occurred.
 // Keeping constant names identical to previous subpackage for continuity.
@@ -24,12 +27,48 @@ const (
 	FailHandlerPanic
 	// FailMiddlewareError indicates an error was returned by the middleware-wrapped core pipeline.
 	FailMiddlewareError
+	// FailPayloadDecode indicates the message payload could not be decoded into the type of a typed handler.
+	// Like FailPayloadSchema it is permanent: the same payload will never decode.
+	FailPayloadDecode
+	// FailUpcast indicates a registered upcaster could not convert the payload to a newer message version.
+	// Upcasters are expected to be pure, so it is treated as permanent like FailPayloadSchema.
+	FailUpcast
 )
 
+// String returns the snake_case name of the failure kind, as used in log records.
+func (k FailureKind) String() string {
+	switch k {
+	case FailNone:
+		return "none"
+	case FailEnvelopeSchema:
+		return "envelope_schema"
+	case FailEnvelopeParse:
+		return "envelope_parse"
+	case FailPayloadSchema:
+		return "payload_schema"
+	case FailNoHandler:
+		return "no_handler"
+	case FailHandlerError:
+		return "handler_error"
+	case FailHandlerPanic:
+		return "handler_panic"
+	case FailMiddlewareError:
+		return "middleware_error"
+	case FailPayloadDecode:
+		return "payload_decode"
+	case FailUpcast:
+		return "upcast"
+	default:
+		return "unknown"
+	}
+}
+
 // FailureResult represents the delete decision and error to attach.
+// RetryAfter is the delay before a retained message should be retried; zero keeps the queue's visibility timeout.
 type FailureResult struct {
 	ShouldDelete bool
 	Error        error
+	RetryAfter   time.Duration
 }
 
 // FailurePolicy decides the final FailureResult given a failure classification and current decision.
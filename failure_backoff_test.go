@@ -0,0 +1,78 @@
+package sqsrouter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestExponentialBackoffPolicyDelays(t *testing.T) {
+	p := ExponentialBackoffPolicy{Next: SQSRedrivePolicy{}, Base: time.Second, Max: 10 * time.Second}
+
+	cases := []struct {
+		receiveCount int
+		want         time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{50, 10 * time.Second},
+	}
+
+	for _, tc := range cases {
+		ctx := WithSQSAttributes(context.Background(), &SQSAttributes{ApproximateReceiveCount: tc.receiveCount})
+		got := p.Decide(ctx, FailHandlerError, errors.New("boom"), FailureResult{})
+		if got.ShouldDelete {
+			t.Fatalf("receiveCount %d: expected retry", tc.receiveCount)
+		}
+		if got.RetryAfter != tc.want {
+			t.Fatalf("receiveCount %d: RetryAfter = %s, want %s", tc.receiveCount, got.RetryAfter, tc.want)
+		}
+	}
+}
+
+func TestExponentialBackoffPolicyWithoutReceiveCount(t *testing.T) {
+	p := ExponentialBackoffPolicy{}
+	got := p.Decide(context.Background(), FailMiddlewareError, errors.New("mw"), FailureResult{})
+	if got.RetryAfter != DefaultBackoffBase {
+		t.Fatalf("RetryAfter = %s, want %s", got.RetryAfter, DefaultBackoffBase)
+	}
+}
+
+func TestExponentialBackoffPolicyJitter(t *testing.T) {
+	p := ExponentialBackoffPolicy{Next: SQSRedrivePolicy{}, Base: time.Second, Max: time.Minute, Jitter: 0.5}
+	ctx := WithSQSAttributes(context.Background(), &SQSAttributes{ApproximateReceiveCount: 3})
+
+	for i := 0; i < 100; i++ {
+		got := p.Decide(ctx, FailHandlerError, errors.New("boom"), FailureResult{})
+		if got.RetryAfter < 2*time.Second || got.RetryAfter > 4*time.Second {
+			t.Fatalf("RetryAfter = %s, want within [2s, 4s]", got.RetryAfter)
+		}
+	}
+}
+
+func TestExponentialBackoffPolicyKeepsDeleteDecisions(t *testing.T) {
+	p := ExponentialBackoffPolicy{}
+	ctx := WithSQSAttributes(context.Background(), &SQSAttributes{ApproximateReceiveCount: 2})
+
+	got := p.Decide(ctx, FailPayloadSchema, errors.New("payload"), FailureResult{})
+	if !got.ShouldDelete || got.RetryAfter != 0 {
+		t.Fatalf("permanent failure should be deleted without delay, got %+v", got)
+	}
+
+	got = p.Decide(ctx, FailNone, nil, FailureResult{ShouldDelete: false})
+	if got.RetryAfter != 0 {
+		t.Fatalf("FailNone must pass through, got %+v", got)
+	}
+}
+
+func TestExponentialBackoffPolicyKeepsExplicitDelay(t *testing.T) {
+	p := ExponentialBackoffPolicy{}
+	got := p.Decide(context.Background(), FailHandlerError, errors.New("boom"), FailureResult{RetryAfter: 42 * time.Second})
+	if got.RetryAfter != 42*time.Second {
+		t.Fatalf("RetryAfter = %s, want handler-provided 42s", got.RetryAfter)
+	}
+}
@@ -24,6 +24,8 @@ func TestImmediateDeletePolicyDecide(t *testing.T) {
         {"FailEnvelopeSchema_delete", FailEnvelopeSchema, errors.New("schema"), base, true, true},
         {"FailEnvelopeParse_delete", FailEnvelopeParse, errors.New("parse"), base, true, true},
         {"FailPayloadSchema_delete", FailPayloadSchema, errors.New("payload"), base, true, true},
+        {"FailPayloadDecode_delete", FailPayloadDecode, errors.New("decode"), base, true, true},
+        {"FailUpcast_delete", FailUpcast, errors.New("upcast"), base, true, true},
         {"FailNoHandler_delete", FailNoHandler, errors.New("nohandler"), base, true, true},
         {"FailHandlerError_respect_handler", FailHandlerError, errors.New("handler"), base, false, true},
         {"FailHandlerPanic_delete", FailHandlerPanic, errors.New("panic"), base, true, true},
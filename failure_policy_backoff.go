@@ -0,0 +1,77 @@
+package sqsrouter
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// Defaults used by ExponentialBackoffPolicy when its fields are left zero.
+const (
+	DefaultBackoffBase = time.Second
+	DefaultBackoffMax  = 15 * time.Minute
+)
+
+// ExponentialBackoffPolicy delays retries exponentially in the message's ApproximateReceiveCount.
+// The delete decision is taken by Next (ImmediateDeletePolicy when nil); for failures that are retained,
+// RetryAfter is set to Base * 2^(receiveCount-1), capped at Max. A RetryAfter already chosen by the
+// handler or by Next is kept.
+//
+// Jitter is the fraction (0-1) of the delay that is randomized away, spreading retries of messages that
+// failed together. The receive count is read from the context (see ReceiveCountFromContext); without it
+// the first-attempt delay is used.
+type ExponentialBackoffPolicy struct {
+	Next   FailurePolicy
+	Base   time.Duration
+	Max    time.Duration
+	Jitter float64
+}
+
+// Decide implements the FailurePolicy interface with exponential retry delays.
+func (p ExponentialBackoffPolicy) Decide(ctx context.Context, kind FailureKind, inner error, current FailureResult) FailureResult {
+	next := p.Next
+	if next == nil {
+		next = ImmediateDeletePolicy{}
+	}
+	res := next.Decide(ctx, kind, inner, current)
+	if kind == FailNone || res.ShouldDelete || res.RetryAfter > 0 {
+		return res
+	}
+
+	attempt, ok := ReceiveCountFromContext(ctx)
+	if !ok || attempt < 1 {
+		attempt = 1
+	}
+	res.RetryAfter = p.delay(attempt)
+	return res
+}
+
+// delay computes the jittered backoff for the given 1-based attempt.
+func (p ExponentialBackoffPolicy) delay(attempt int) time.Duration {
+	base, limit := p.Base, p.Max
+	if base <= 0 {
+		base = DefaultBackoffBase
+	}
+	if limit <= 0 {
+		limit = DefaultBackoffMax
+	}
+
+	d := base
+	for i := 1; i < attempt && d < limit; i++ {
+		d *= 2
+	}
+	if d > limit {
+		d = limit
+	}
+
+	if p.Jitter > 0 {
+		j := p.Jitter
+		if j > 1 {
+			j = 1
+		}
+		if spread := int64(float64(d) * j); spread > 0 {
+			d -= time.Duration(rand.Int64N(spread + 1)) //nolint:gosec // jitter does not need a CSPRNG
+		}
+	}
+	return d
+}
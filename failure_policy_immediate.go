@@ -11,7 +11,7 @@ func (p ImmediateDeletePolicy) Decide(_ context.Context, kind FailureKind, inner
 	switch kind {
 	case FailNone:
 		return current
-	case FailEnvelopeSchema, FailEnvelopeParse, FailPayloadSchema, FailNoHandler, FailHandlerPanic:
+	case FailEnvelopeSchema, FailEnvelopeParse, FailPayloadSchema, FailPayloadDecode, FailUpcast, FailNoHandler, FailHandlerPanic:
 		current.ShouldDelete = true
 		if inner != nil && current.Error == nil {
 			current.Error = inner
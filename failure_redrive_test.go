@@ -18,6 +18,8 @@ func TestSQSRedrivePolicyAllFailuresShouldNotDelete(t *testing.T) {
         FailHandlerError,
         FailHandlerPanic,
         FailMiddlewareError,
+        FailPayloadDecode,
+        FailUpcast,
     }
 
     for _, k := range kinds {
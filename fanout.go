@@ -0,0 +1,302 @@
+package sqsrouter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+)
+
+// FanOutHandler is one of the handlers registered with RegisterFanOut or AddFanOutHandler.
+// Name identifies the handler in errors, aggregation and completion tracking; it must be unique per key.
+type FanOutHandler struct {
+	Name    string
+	Handler MessageHandler
+}
+
+// FanOutResult is the outcome of one fan-out handler for a message.
+// Skipped is set when a FanOutTracker reported the handler as already completed; Result is then a success.
+type FanOutResult struct {
+	Name    string
+	Result  HandlerResult
+	Skipped bool
+}
+
+// FanOutAggregator combines the results of all fan-out handlers, in registration order, into the
+// HandlerResult of the message.
+type FanOutAggregator func(results []FanOutResult) HandlerResult
+
+// AllSucceed is the default FanOutAggregator. The message is deleted only when every handler asked for
+// deletion without an error. Otherwise it is retained, with the handlers' errors joined (prefixed with
+// their names) and the longest RetryAfter of the handlers that retained it.
+func AllSucceed(results []FanOutResult) HandlerResult {
+	out := HandlerResult{ShouldDelete: true}
+	var errs []error
+	for _, res := range results {
+		if res.Result.Error != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", res.Name, res.Result.Error))
+		}
+		if !res.Result.ShouldDelete || res.Result.Error != nil {
+			out.ShouldDelete = false
+			out.RetryAfter = max(out.RetryAfter, res.Result.RetryAfter)
+		}
+	}
+	out.Error = errors.Join(errs...)
+	if out.ShouldDelete {
+		out.RetryAfter = 0
+	}
+	return out
+}
+
+// FanOutTracker records which fan-out handlers have completed a message, so that a redelivered message
+// only reruns the handlers that did not complete. The messageID passed to the tracker is the type:version
+// the fan-out is registered under and the envelope metadata messageId, or the SQS message ID when it is
+// empty, joined by a slash, e.g. "OrderPlaced:v1/m-1"; messages without either ID are not tracked. Fan-outs
+// of different types and versions can therefore share a tracker, and handler names only need to be unique
+// per fan-out. Implementations must be safe for concurrent use.
+type FanOutTracker interface {
+	// Completed reports whether handler has completed the message.
+	Completed(ctx context.Context, messageID, handler string) (bool, error)
+	// MarkCompleted records that handler completed the message.
+	MarkCompleted(ctx context.Context, messageID, handler string) error
+	// Forget drops the records of a message once every handler has completed it.
+	Forget(ctx context.Context, messageID string) error
+}
+
+// FanOutOption configures a fan-out registration.
+type FanOutOption func(*fanOutConfig)
+
+type fanOutConfig struct {
+	parallel   bool
+	aggregator FanOutAggregator
+	tracker    FanOutTracker
+}
+
+// FanOutParallel runs the handlers concurrently instead of one after another in registration order.
+func FanOutParallel() FanOutOption {
+	return func(c *fanOutConfig) { c.parallel = true }
+}
+
+// WithFanOutAggregator sets the rule that determines the final HandlerResult. The default is AllSucceed.
+func WithFanOutAggregator(a FanOutAggregator) FanOutOption {
+	return func(c *fanOutConfig) { c.aggregator = a }
+}
+
+// WithFanOutTracker skips handlers that already completed a redelivered message. A handler has completed
+// a message when it returned ShouldDelete without an error. Tracker errors never fail a message: if
+// Completed fails the handler runs again, and if MarkCompleted fails it may run again on redelivery.
+func WithFanOutTracker(t FanOutTracker) FanOutOption {
+	return func(c *fanOutConfig) { c.tracker = t }
+}
+
+// RegisterFanOut registers several handlers for one message type and version. Every handler runs for
+// each message, sequentially by default, and the aggregator combines their results. A handler panic
+// fails the whole message with FailHandlerPanic, after the other handlers have finished.
+// An error is returned, and nothing is registered, when handlers is empty, a name is empty or repeated,
+// or the routing policy rejects the handler key. Registering the key again replaces all of its handlers
+// and options; use AddFanOutHandler to add handlers independently.
+func (r *Router) RegisterFanOut(messageType, messageVersion string, handlers []FanOutHandler, opts ...FanOutOption) error {
+	key := makeKey(messageType, messageVersion)
+	if len(handlers) == 0 {
+		return fmt.Errorf("%w for %s: no handlers", ErrInvalidFanOut, key)
+	}
+	cfg := fanOutConfig{aggregator: AllSucceed}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	f := &fanOut{key: key, cfg: cfg, logger: r.logger}
+	for _, h := range handlers {
+		if err := f.add(h); err != nil {
+			return err
+		}
+	}
+	return r.register(messageType, messageVersion, handlerEntry{handler: f.handle, fanOut: f}, nil)
+}
+
+// AddFanOutHandler adds one handler to the fan-out of a message type and version, so that independent
+// packages can each contribute a handler for the same event. The fan-out is created with the default
+// options when the key has no handler yet; opts are applied on top of the options of an existing fan-out.
+// Handlers run in the order they were added. An error is returned, and nothing is registered, when the
+// name is empty or already taken, a handler registered with Register or RegisterTyped exists for the key,
+// or the routing policy rejects the handler key.
+func (r *Router) AddFanOutHandler(messageType, messageVersion string, handler FanOutHandler, opts ...FanOutOption) error {
+	key := makeKey(messageType, messageVersion)
+	return r.updateTable(func(t *routingTable) error {
+		// Published fan-outs are shared with in-flight messages, so a copy is extended.
+		f := &fanOut{key: key, cfg: fanOutConfig{aggregator: AllSucceed}, logger: r.logger}
+		if cur, exists := t.handlers[key]; exists {
+			if cur.fanOut == nil {
+				return fmt.Errorf("%w for %s: a handler that is not a fan-out is registered", ErrInvalidFanOut, key)
+			}
+			f.cfg = cur.fanOut.cfg
+			f.handlers = slices.Clone(cur.fanOut.handlers)
+		} else if err := r.validateKey(t, key); err != nil {
+			return err
+		}
+		for _, opt := range opts {
+			opt(&f.cfg)
+		}
+		if err := f.add(handler); err != nil {
+			return err
+		}
+		t.handlers[key] = handlerEntry{handler: f.handle, fanOut: f}
+		return nil
+	})
+}
+
+// fanOut is the MessageHandler registered by RegisterFanOut and AddFanOutHandler.
+type fanOut struct {
+	// key is the type:version the fan-out is registered under.
+	key      string
+	handlers []FanOutHandler
+	cfg      fanOutConfig
+	logger   *slog.Logger
+}
+
+// add appends h, rejecting handlers without a name or function and repeated names.
+func (f *fanOut) add(h FanOutHandler) error {
+	if h.Name == "" || h.Handler == nil {
+		return fmt.Errorf("%w for %s: handlers need a name and a function", ErrInvalidFanOut, f.key)
+	}
+	if slices.ContainsFunc(f.handlers, func(o FanOutHandler) bool { return o.Name == h.Name }) {
+		return fmt.Errorf("%w for %s: duplicate handler %q", ErrInvalidFanOut, f.key, h.Name)
+	}
+	f.handlers = append(f.handlers, h)
+	return nil
+}
+
+func (f *fanOut) handle(ctx context.Context, messageJSON []byte, metadataJSON []byte) HandlerResult {
+	messageID := ""
+	if f.cfg.tracker != nil {
+		if id := fanOutMessageID(ctx, metadataJSON); id != "" {
+			messageID = f.key + "/" + id
+		}
+	}
+
+	results := make([]FanOutResult, len(f.handlers))
+	var panicked any
+	var panicOnce sync.Once
+	run := func(i int) {
+		h := f.handlers[i]
+		results[i].Name = h.Name
+		if messageID != "" {
+			if done, err := f.cfg.tracker.Completed(ctx, messageID, h.Name); err == nil && done {
+				results[i].Skipped = true
+				results[i].Result = HandlerResult{ShouldDelete: true}
+				return
+			}
+		}
+		defer func() {
+			// Let every handler finish before the first panic is re-raised to the router.
+			if rec := recover(); rec != nil {
+				panicOnce.Do(func() { panicked = rec })
+			}
+		}()
+		res := h.Handler(ctx, messageJSON, metadataJSON)
+		results[i].Result = res
+		if messageID != "" && res.ShouldDelete && res.Error == nil {
+			if err := f.cfg.tracker.MarkCompleted(ctx, messageID, h.Name); err != nil {
+				f.logger.LogAttrs(ctx, slog.LevelWarn, "failed to record fan-out handler completion",
+					slog.String(LogKeyMessageID, messageID),
+					slog.String(LogKeyFanOutHandler, h.Name),
+					slog.String(LogKeyError, err.Error()),
+				)
+			}
+		}
+	}
+
+	if f.cfg.parallel {
+		var wg sync.WaitGroup
+		for i := range f.handlers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				run(i)
+			}()
+		}
+		wg.Wait()
+	} else {
+		for i := range f.handlers {
+			run(i)
+		}
+	}
+	if panicked != nil {
+		panic(panicked)
+	}
+
+	result := f.cfg.aggregator(results)
+	if messageID != "" && allCompleted(results) {
+		if err := f.cfg.tracker.Forget(ctx, messageID); err != nil {
+			f.logger.LogAttrs(ctx, slog.LevelWarn, "failed to forget fan-out completions",
+				slog.String(LogKeyMessageID, messageID),
+				slog.String(LogKeyError, err.Error()),
+			)
+		}
+	}
+	return result
+}
+
+// allCompleted reports whether every handler has completed the message.
+func allCompleted(results []FanOutResult) bool {
+	for _, res := range results {
+		if !res.Result.ShouldDelete || res.Result.Error != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// fanOutMessageID returns the ID completions are tracked under: the metadata messageId, or the SQS message ID.
+func fanOutMessageID(ctx context.Context, metadataJSON []byte) string {
+	var meta MessageMetadata
+	if json.Unmarshal(metadataJSON, &meta) == nil && meta.MessageID != "" {
+		return meta.MessageID
+	}
+	if attrs, ok := SQSAttributesFromContext(ctx); ok {
+		return attrs.MessageID
+	}
+	return ""
+}
+
+// MemoryFanOutTracker is an in-process FanOutTracker. It only helps when a message is redelivered to the
+// same process, and it keeps the records of messages that never complete (e.g. moved to a DLQ) until the
+// process exits; use a shared store with expiry for durable tracking across consumers.
+type MemoryFanOutTracker struct {
+	mu   sync.Mutex
+	done map[string]map[string]struct{}
+}
+
+// NewMemoryFanOutTracker returns an empty MemoryFanOutTracker.
+func NewMemoryFanOutTracker() *MemoryFanOutTracker {
+	return &MemoryFanOutTracker{done: make(map[string]map[string]struct{})}
+}
+
+// Completed implements FanOutTracker.
+func (t *MemoryFanOutTracker) Completed(_ context.Context, messageID, handler string) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, ok := t.done[messageID][handler]
+	return ok, nil
+}
+
+// MarkCompleted implements FanOutTracker.
+func (t *MemoryFanOutTracker) MarkCompleted(_ context.Context, messageID, handler string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.done[messageID] == nil {
+		t.done[messageID] = make(map[string]struct{})
+	}
+	t.done[messageID][handler] = struct{}{}
+	return nil
+}
+
+// Forget implements FanOutTracker.
+func (t *MemoryFanOutTracker) Forget(_ context.Context, messageID string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.done, messageID)
+	return nil
+}
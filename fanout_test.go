@@ -0,0 +1,331 @@
+package sqsrouter
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const fanOutMessage = `{"schemaVersion":"1.0","messageType":"OrderPlaced","messageVersion":"v1","message":{"id":1},"metadata":{"messageId":"m-1"}}`
+
+// recordingHandler returns a handler that appends name to calls and returns res.
+func recordingHandler(mu *sync.Mutex, calls *[]string, name string, res HandlerResult) FanOutHandler {
+	return FanOutHandler{Name: name, Handler: func(context.Context, []byte, []byte) HandlerResult {
+		mu.Lock()
+		defer mu.Unlock()
+		*calls = append(*calls, name)
+		return res
+	}}
+}
+
+func TestRegisterFanOut_Sequential(t *testing.T) {
+	r, err := NewRouter(EnvelopeSchema)
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+	var mu sync.Mutex
+	var calls []string
+	err = r.RegisterFanOut("OrderPlaced", "v1", []FanOutHandler{
+		recordingHandler(&mu, &calls, "projection", HandlerResult{ShouldDelete: true}),
+		recordingHandler(&mu, &calls, "notify", HandlerResult{ShouldDelete: true}),
+	})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	rr := r.Route(context.Background(), []byte(fanOutMessage))
+
+	if rr.FailureKind != FailNone || !rr.HandlerResult.ShouldDelete {
+		t.Fatalf("unexpected result: %+v", rr)
+	}
+	if strings.Join(calls, ",") != "projection,notify" {
+		t.Errorf("calls = %v, want registration order", calls)
+	}
+}
+
+func TestRegisterFanOut_AllSucceed(t *testing.T) {
+	boom := errors.New("smtp down")
+	r, err := NewRouter(EnvelopeSchema, WithFailurePolicy(SQSRedrivePolicy{}))
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+	var mu sync.Mutex
+	var calls []string
+	err = r.RegisterFanOut("OrderPlaced", "v1", []FanOutHandler{
+		recordingHandler(&mu, &calls, "projection", HandlerResult{ShouldDelete: true}),
+		recordingHandler(&mu, &calls, "notify", HandlerResult{Error: boom, RetryAfter: 30 * time.Second}),
+		recordingHandler(&mu, &calls, "audit", HandlerResult{ShouldDelete: false, RetryAfter: time.Second}),
+	})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	rr := r.Route(context.Background(), []byte(fanOutMessage))
+
+	if len(calls) != 3 {
+		t.Fatalf("every handler must run, calls = %v", calls)
+	}
+	if rr.HandlerResult.ShouldDelete || rr.FailureKind != FailHandlerError {
+		t.Fatalf("message must be retained as a handler error: %+v", rr)
+	}
+	if !errors.Is(rr.HandlerResult.Error, boom) || !strings.Contains(rr.HandlerResult.Error.Error(), "notify: smtp down") {
+		t.Errorf("error = %v, want the named handler error", rr.HandlerResult.Error)
+	}
+	if rr.HandlerResult.RetryAfter != 30*time.Second {
+		t.Errorf("RetryAfter = %v, want the longest handler delay", rr.HandlerResult.RetryAfter)
+	}
+}
+
+func TestRegisterFanOut_Parallel(t *testing.T) {
+	r, err := NewRouter(EnvelopeSchema)
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+	var running, peak atomic.Int32
+	release := make(chan struct{})
+	h := func(context.Context, []byte, []byte) HandlerResult {
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		if n == 2 {
+			close(release)
+		}
+		<-release
+		running.Add(-1)
+		return HandlerResult{ShouldDelete: true}
+	}
+	err = r.RegisterFanOut("OrderPlaced", "v1", []FanOutHandler{{Name: "a", Handler: h}, {Name: "b", Handler: h}}, FanOutParallel())
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	done := make(chan RoutedResult)
+	go func() { done <- r.Route(context.Background(), []byte(fanOutMessage)) }()
+	select {
+	case rr := <-done:
+		if !rr.HandlerResult.ShouldDelete || peak.Load() != 2 {
+			t.Fatalf("result %+v, peak concurrency %d", rr, peak.Load())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("parallel handlers did not run concurrently")
+	}
+}
+
+func TestRegisterFanOut_Panic(t *testing.T) {
+	for _, parallel := range []bool{false, true} {
+		r, err := NewRouter(EnvelopeSchema)
+		if err != nil {
+			t.Fatalf("new router: %v", err)
+		}
+		var mu sync.Mutex
+		var calls []string
+		var opts []FanOutOption
+		if parallel {
+			opts = append(opts, FanOutParallel())
+		}
+		err = r.RegisterFanOut("OrderPlaced", "v1", []FanOutHandler{
+			{Name: "bad", Handler: func(context.Context, []byte, []byte) HandlerResult { panic("boom") }},
+			recordingHandler(&mu, &calls, "good", HandlerResult{ShouldDelete: true}),
+		}, opts...)
+		if err != nil {
+			t.Fatalf("register: %v", err)
+		}
+
+		rr := r.Route(context.Background(), []byte(fanOutMessage))
+
+		if rr.FailureKind != FailHandlerPanic || !errors.Is(rr.HandlerResult.Error, ErrPanic) {
+			t.Errorf("parallel=%v: result %+v, want FailHandlerPanic", parallel, rr)
+		}
+		if len(calls) != 1 {
+			t.Errorf("parallel=%v: the other handlers must still run, calls = %v", parallel, calls)
+		}
+	}
+}
+
+func TestRegisterFanOut_Tracker(t *testing.T) {
+	r, err := NewRouter(EnvelopeSchema, WithFailurePolicy(SQSRedrivePolicy{}))
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+	tracker := NewMemoryFanOutTracker()
+	var mu sync.Mutex
+	var calls []string
+	notifyFails := true
+	err = r.RegisterFanOut("OrderPlaced", "v1", []FanOutHandler{
+		recordingHandler(&mu, &calls, "projection", HandlerResult{ShouldDelete: true}),
+		{Name: "notify", Handler: func(context.Context, []byte, []byte) HandlerResult {
+			calls = append(calls, "notify")
+			if notifyFails {
+				return HandlerResult{Error: errors.New("smtp down")}
+			}
+			return HandlerResult{ShouldDelete: true}
+		}},
+	}, WithFanOutTracker(tracker))
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	if rr := r.Route(context.Background(), []byte(fanOutMessage)); rr.HandlerResult.ShouldDelete {
+		t.Fatalf("first delivery must be retained: %+v", rr)
+	}
+	notifyFails = false
+	calls = nil
+	if rr := r.Route(context.Background(), []byte(fanOutMessage)); !rr.HandlerResult.ShouldDelete {
+		t.Fatalf("redelivery should complete: %+v", rr)
+	}
+	if strings.Join(calls, ",") != "notify" {
+		t.Errorf("redelivery calls = %v, want only the handler that failed", calls)
+	}
+	if done, _ := tracker.Completed(context.Background(), "OrderPlaced:v1/m-1", "projection"); done {
+		t.Error("records must be forgotten once every handler completed")
+	}
+}
+
+func TestRegisterFanOut_TrackerUsesSQSMessageID(t *testing.T) {
+	r, err := NewRouter(EnvelopeSchema)
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+	tracker := NewMemoryFanOutTracker()
+	err = r.RegisterFanOut("OrderPlaced", "v1", []FanOutHandler{
+		{Name: "a", Handler: func(context.Context, []byte, []byte) HandlerResult { return HandlerResult{ShouldDelete: true} }},
+		{Name: "b", Handler: func(context.Context, []byte, []byte) HandlerResult { return HandlerResult{} }},
+	}, WithFanOutTracker(tracker))
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	ctx := WithSQSAttributes(context.Background(), &SQSAttributes{MessageID: "sqs-1"})
+	r.Route(ctx, []byte(`{"schemaVersion":"1.0","messageType":"OrderPlaced","messageVersion":"v1","message":{},"metadata":{}}`))
+
+	if done, _ := tracker.Completed(ctx, "OrderPlaced:v1/sqs-1", "a"); !done {
+		t.Error("completion should be tracked under the SQS message ID")
+	}
+}
+
+func TestRegisterFanOut_TrackerSeparatesTypes(t *testing.T) {
+	r, err := NewRouter(EnvelopeSchema)
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+	tracker := NewMemoryFanOutTracker()
+	var mu sync.Mutex
+	var calls []string
+	for _, typ := range []string{"OrderPlaced", "OrderShipped"} {
+		err := r.RegisterFanOut(typ, "v1", []FanOutHandler{
+			recordingHandler(&mu, &calls, typ+"/audit", HandlerResult{ShouldDelete: true}),
+			{Name: "notify", Handler: func(context.Context, []byte, []byte) HandlerResult { return HandlerResult{} }},
+		}, WithFanOutTracker(tracker))
+		if err != nil {
+			t.Fatalf("register %s: %v", typ, err)
+		}
+	}
+
+	r.Route(context.Background(), []byte(fanOutMessage))
+	r.Route(context.Background(), []byte(strings.Replace(fanOutMessage, "OrderPlaced", "OrderShipped", 1)))
+
+	if strings.Join(calls, ",") != "OrderPlaced/audit,OrderShipped/audit" {
+		t.Errorf("calls = %v, want both types handled despite the shared message ID", calls)
+	}
+	for _, id := range []string{"OrderPlaced:v1/m-1", "OrderShipped:v1/m-1"} {
+		if done, _ := tracker.Completed(context.Background(), id, "notify"); done {
+			t.Errorf("%s: notify must not be marked completed", id)
+		}
+	}
+}
+
+func TestAddFanOutHandler(t *testing.T) {
+	r, err := NewRouter(EnvelopeSchema, WithFailurePolicy(SQSRedrivePolicy{}))
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+	var mu sync.Mutex
+	var calls []string
+	if err := r.AddFanOutHandler("OrderPlaced", "v1", recordingHandler(&mu, &calls, "projection", HandlerResult{ShouldDelete: true})); err != nil {
+		t.Fatalf("add projection: %v", err)
+	}
+	err = r.AddFanOutHandler("OrderPlaced", "v1", recordingHandler(&mu, &calls, "notify", HandlerResult{Error: errors.New("smtp down")}),
+		FanOutParallel())
+	if err != nil {
+		t.Fatalf("add notify: %v", err)
+	}
+
+	rr := r.Route(context.Background(), []byte(fanOutMessage))
+	if rr.HandlerResult.ShouldDelete || !strings.Contains(rr.HandlerResult.Error.Error(), "notify: smtp down") {
+		t.Fatalf("unexpected result: %+v", rr)
+	}
+	if len(calls) != 2 {
+		t.Errorf("calls = %v, want both handlers", calls)
+	}
+	if f := r.table.Load().handlers["OrderPlaced:v1"].fanOut; f == nil || !f.cfg.parallel || len(f.handlers) != 2 {
+		t.Errorf("fan-out = %+v, want both handlers and the added option", f)
+	}
+
+	if err := r.AddFanOutHandler("OrderPlaced", "v1", recordingHandler(&mu, &calls, "notify", HandlerResult{})); !errors.Is(err, ErrInvalidFanOut) {
+		t.Errorf("duplicate name: err = %v, want ErrInvalidFanOut", err)
+	}
+	r.Register("Invoice", "v1", func(context.Context, []byte, []byte) HandlerResult { return HandlerResult{} })
+	if err := r.AddFanOutHandler("Invoice", "v1", recordingHandler(&mu, &calls, "audit", HandlerResult{})); !errors.Is(err, ErrInvalidFanOut) {
+		t.Errorf("plain handler: err = %v, want ErrInvalidFanOut", err)
+	}
+}
+
+func TestRegisterFanOut_CustomAggregator(t *testing.T) {
+	r, err := NewRouter(EnvelopeSchema)
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+	var got []FanOutResult
+	anySucceeds := func(results []FanOutResult) HandlerResult {
+		got = results
+		for _, res := range results {
+			if res.Result.ShouldDelete {
+				return HandlerResult{ShouldDelete: true}
+			}
+		}
+		return HandlerResult{}
+	}
+	err = r.RegisterFanOut("OrderPlaced", "v1", []FanOutHandler{
+		{Name: "a", Handler: func(context.Context, []byte, []byte) HandlerResult { return HandlerResult{Error: errors.New("x")} }},
+		{Name: "b", Handler: func(context.Context, []byte, []byte) HandlerResult { return HandlerResult{ShouldDelete: true} }},
+	}, WithFanOutAggregator(anySucceeds))
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	if rr := r.Route(context.Background(), []byte(fanOutMessage)); !rr.HandlerResult.ShouldDelete || rr.FailureKind != FailNone {
+		t.Fatalf("unexpected result: %+v", rr)
+	}
+	if len(got) != 2 || got[0].Name != "a" || got[1].Name != "b" {
+		t.Errorf("aggregator got %+v, want results in registration order", got)
+	}
+}
+
+func TestRegisterFanOut_Validation(t *testing.T) {
+	r, err := NewRouter(EnvelopeSchema)
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+	ok := func(context.Context, []byte, []byte) HandlerResult { return HandlerResult{} }
+	cases := map[string][]FanOutHandler{
+		"empty":     nil,
+		"no name":   {{Handler: ok}},
+		"no func":   {{Name: "a"}},
+		"duplicate": {{Name: "a", Handler: ok}, {Name: "a", Handler: ok}},
+	}
+	for name, handlers := range cases {
+		if err := r.RegisterFanOut("T", "v1", handlers); !errors.Is(err, ErrInvalidFanOut) {
+			t.Errorf("%s: err = %v, want ErrInvalidFanOut", name, err)
+		}
+	}
+	if len(r.table.Load().handlers) != 0 {
+		t.Error("invalid registrations must not register anything")
+	}
+}
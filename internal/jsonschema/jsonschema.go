@@ -9,6 +9,7 @@ import (
 type (
 	ValidationResult = gojsonschema.Result
 	JSONLoader       = gojsonschema.JSONLoader
+	Schema           = gojsonschema.Schema
 )
 
 func NewStringLoader(s string) gojsonschema.JSONLoader {
@@ -27,6 +28,12 @@ func Validate(schemaLoader gojsonschema.JSONLoader, docLoader gojsonschema.JSONL
 	return gojsonschema.Validate(schemaLoader, docLoader)
 }
 
+// ValidateSchema validates a document against a schema compiled once with NewSchema.
+// Unlike Validate, it does not re-parse and re-compile the schema on every call.
+func ValidateSchema(schema *gojsonschema.Schema, docLoader gojsonschema.JSONLoader) (*gojsonschema.Result, error) {
+	return schema.Validate(docLoader)
+}
+
 func FormatErrors(result *gojsonschema.Result, err error) error {
 	if err != nil {
 		return fmt.Errorf("%w: %v", ErrSchemaValidationSystem, err)
@@ -0,0 +1,107 @@
+package jsonschema
+
+import "testing"
+
+// orderSchema is a payload schema of realistic size: nested objects, arrays, enums and formats.
+const orderSchema = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "required": ["orderId", "customer", "items", "currency", "total", "status", "createdAt"],
+  "properties": {
+    "orderId": { "type": "string", "minLength": 8, "maxLength": 64 },
+    "status": { "type": "string", "enum": ["created", "paid", "shipped", "delivered", "cancelled"] },
+    "createdAt": { "type": "string", "format": "date-time" },
+    "currency": { "type": "string", "pattern": "^[A-Z]{3}$" },
+    "total": { "type": "number", "minimum": 0 },
+    "notes": { "type": "string", "maxLength": 500 },
+    "tags": { "type": "array", "items": { "type": "string" }, "maxItems": 20 },
+    "customer": {
+      "type": "object",
+      "required": ["id", "email"],
+      "properties": {
+        "id": { "type": "string" },
+        "email": { "type": "string", "format": "email" },
+        "name": { "type": "string" },
+        "phone": { "type": "string" },
+        "address": {
+          "type": "object",
+          "required": ["line1", "city", "country"],
+          "properties": {
+            "line1": { "type": "string" },
+            "line2": { "type": "string" },
+            "city": { "type": "string" },
+            "postalCode": { "type": "string" },
+            "country": { "type": "string", "pattern": "^[A-Z]{2}$" }
+          },
+          "additionalProperties": false
+        }
+      }
+    },
+    "items": {
+      "type": "array",
+      "minItems": 1,
+      "items": {
+        "type": "object",
+        "required": ["sku", "quantity", "unitPrice"],
+        "properties": {
+          "sku": { "type": "string" },
+          "name": { "type": "string" },
+          "quantity": { "type": "integer", "minimum": 1 },
+          "unitPrice": { "type": "number", "minimum": 0 },
+          "discount": { "type": "number", "minimum": 0, "maximum": 1 }
+        },
+        "additionalProperties": false
+      }
+    }
+  }
+}`
+
+const orderDocument = `{
+  "orderId": "ord-20240101-0001",
+  "status": "paid",
+  "createdAt": "2024-01-01T12:00:00Z",
+  "currency": "EUR",
+  "total": 129.9,
+  "tags": ["gift", "express"],
+  "customer": {
+    "id": "cus-42",
+    "email": "miku@example.com",
+    "name": "Hatsune Miku",
+    "address": { "line1": "1 Main St", "city": "Sapporo", "postalCode": "060-0000", "country": "JP" }
+  },
+  "items": [
+    { "sku": "SKU-1", "name": "Leek", "quantity": 3, "unitPrice": 9.9 },
+    { "sku": "SKU-2", "name": "Headset", "quantity": 1, "unitPrice": 100.2, "discount": 0.1 }
+  ]
+}`
+
+// BenchmarkValidate compares validating against a schema loader, which compiles the schema on every call,
+// with validating against a schema compiled once.
+func BenchmarkValidate(b *testing.B) {
+	doc := []byte(orderDocument)
+
+	b.Run("loader", func(b *testing.B) {
+		loader := NewStringLoader(orderSchema)
+		b.ReportAllocs()
+		for b.Loop() {
+			res, err := Validate(loader, NewBytesLoader(doc))
+			if ferr := FormatErrors(res, err); ferr != nil {
+				b.Fatal(ferr)
+			}
+		}
+	})
+
+	b.Run("compiled", func(b *testing.B) {
+		schema, err := NewSchema(NewStringLoader(orderSchema))
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ReportAllocs()
+		for b.Loop() {
+			res, err := ValidateSchema(schema, NewBytesLoader(doc))
+			if ferr := FormatErrors(res, err); ferr != nil {
+				b.Fatal(ferr)
+			}
+		}
+	})
+}
@@ -76,3 +76,21 @@ func TestFormatErrors_SystemError(t *testing.T) {
 type assertError struct{}
 
 func (assertError) Error() string { return "system boom" }
+
+func TestValidateSchema_Compiled(t *testing.T) {
+	schema := `{"$schema":"http://json-schema.org/draft-07/schema#","type":"object","properties":{"age":{"type":"integer"}},"required":["age"]}`
+	compiled, err := NewSchema(NewStringLoader(schema))
+	if err != nil {
+		t.Fatalf("schema should be valid: %v", err)
+	}
+
+	res, err := ValidateSchema(compiled, NewBytesLoader([]byte(`{"age":39}`)))
+	if ferr := FormatErrors(res, err); ferr != nil {
+		t.Fatalf("expected document to be valid, got: %v", ferr)
+	}
+
+	res, err = ValidateSchema(compiled, NewBytesLoader([]byte(`{"age":"39"}`)))
+	if ferr := FormatErrors(res, err); !errors.Is(ferr, ErrSchemaValidationFailed) {
+		t.Fatalf("expected ErrSchemaValidationFailed, got: %v", ferr)
+	}
+}
@@ -0,0 +1,37 @@
+package sqsrouter
+
+import "log/slog"
+
+// Attribute keys used by the router and the consumer in structured log records.
+// They are exported so that custom middlewares and handlers can log with the same keys.
+const (
+	LogKeyQueue          = "queue"
+	LogKeyMessageType    = "message_type"
+	LogKeyMessageVersion = "message_version"
+	LogKeyMessageID      = "message_id"
+	LogKeyFailureKind    = "failure_kind"
+	LogKeyDuration       = "duration"
+	LogKeyDeleted        = "deleted"
+	LogKeyRetryAfter     = "retry_after"
+	LogKeyError          = "error"
+	LogKeyHandlerKey     = "handler_key"
+	LogKeyFallback       = "fallback"
+	LogKeyVariant        = "variant"
+	// Keys of the consumer's receive, FIFO and heartbeat records.
+	LogKeySQSMessageID   = "sqs_message_id"
+	LogKeyMessageGroupID = "message_group_id"
+	LogKeyCount          = "count"
+	LogKeyInFlight       = "in_flight"
+	LogKeyHeld           = "held"
+	LogKeyMaxExtension   = "max_extension"
+	// LogKeyFanOutHandler names the fan-out handler a record is about.
+	LogKeyFanOutHandler = "fanout_handler"
+)
+
+// loggerOrDiscard returns l, or a logger that drops every record when l is nil.
+func loggerOrDiscard(l *slog.Logger) *slog.Logger {
+	if l == nil {
+		return slog.New(slog.DiscardHandler)
+	}
+	return l
+}
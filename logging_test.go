@@ -0,0 +1,76 @@
+package sqsrouter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestFailureKindString(t *testing.T) {
+	cases := map[FailureKind]string{
+		FailNone:            "none",
+		FailEnvelopeSchema:  "envelope_schema",
+		FailEnvelopeParse:   "envelope_parse",
+		FailPayloadSchema:   "payload_schema",
+		FailNoHandler:       "no_handler",
+		FailHandlerError:    "handler_error",
+		FailHandlerPanic:    "handler_panic",
+		FailMiddlewareError: "middleware_error",
+		FailPayloadDecode:   "payload_decode",
+		FailUpcast:          "upcast",
+		FailureKind(99):     "unknown",
+	}
+	for kind, want := range cases {
+		if got := kind.String(); got != want {
+			t.Errorf("FailureKind(%d).String() = %q, want %q", int(kind), got, want)
+		}
+	}
+}
+
+func TestRouterLogsRoutedMessage(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	router, err := NewRouter(EnvelopeSchema, WithLogger(logger))
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+
+	rr := router.Route(context.Background(), []byte(`{"schemaVersion":"1.0","messageType":"T","messageVersion":"v1","message":{},"metadata":{"messageId":"m-1"}}`))
+	if rr.FailureKind != FailNoHandler {
+		t.Fatalf("FailureKind = %v, want %v", rr.FailureKind, FailNoHandler)
+	}
+
+	var rec map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("decode log record %q: %v", buf.String(), err)
+	}
+	want := map[string]any{
+		"msg":                "message routed",
+		"level":              "DEBUG",
+		LogKeyMessageType:    "T",
+		LogKeyMessageVersion: "v1",
+		LogKeyMessageID:      "m-1",
+		LogKeyFailureKind:    "no_handler",
+		LogKeyDeleted:        true, // ImmediateDeletePolicy drops unroutable messages
+	}
+	for k, v := range want {
+		if rec[k] != v {
+			t.Errorf("%s = %v, want %v", k, rec[k], v)
+		}
+	}
+	if _, ok := rec[LogKeyDuration]; !ok {
+		t.Errorf("missing %s attribute", LogKeyDuration)
+	}
+}
+
+func TestRouterWithNilLoggerDisablesLogging(t *testing.T) {
+	router, err := NewRouter(EnvelopeSchema, WithLogger(nil))
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+	if router.logger.Enabled(context.Background(), slog.LevelError) {
+		t.Fatal("nil logger should discard every record")
+	}
+}
@@ -0,0 +1,63 @@
+package sqsrouter
+
+import "time"
+
+// Metrics receives counters and timings from the Router and the consumer.
+// Implementations must be safe for concurrent use. Labels are low-cardinality strings:
+// message types and versions, failure kinds and queue URLs.
+// The router reports the version a message arrived with, also when upcasters converted it,
+// matching RoutedResult.MessageVersion and the log records.
+//
+// Embed NopMetrics to implement only the methods an exporter cares about.
+type Metrics interface {
+	// MessageRouted is called once per Router.Route call with the outcome and the total routing time.
+	// kind is FailNone when the message was handled successfully.
+	MessageRouted(messageType, messageVersion string, kind FailureKind, d time.Duration)
+	// HandlerDuration is called after a registered handler returned.
+	HandlerDuration(messageType, messageVersion string, d time.Duration)
+	// MessagesReceived is called with the number of messages returned by a ReceiveMessage call.
+	MessagesReceived(queue string, n int)
+	// ReceiveError is called when a ReceiveMessage call fails.
+	ReceiveError(queue string)
+	// MessageDeleted is called when a handled message was deleted from its queue.
+	MessageDeleted(queue string)
+	// DeleteError is called when deleting a handled message failed.
+	DeleteError(queue string)
+	// MessageRetried is called when a message is kept for another delivery.
+	// delay is the requested retry delay, or zero when the queue's visibility timeout applies.
+	MessageRetried(queue string, delay time.Duration)
+}
+
+// NopMetrics discards all metrics. It is the default of the Router and the consumer.
+type NopMetrics struct{}
+
+var _ Metrics = NopMetrics{}
+
+// MessageRouted implements Metrics.
+func (NopMetrics) MessageRouted(string, string, FailureKind, time.Duration) {}
+
+// HandlerDuration implements Metrics.
+func (NopMetrics) HandlerDuration(string, string, time.Duration) {}
+
+// MessagesReceived implements Metrics.
+func (NopMetrics) MessagesReceived(string, int) {}
+
+// ReceiveError implements Metrics.
+func (NopMetrics) ReceiveError(string) {}
+
+// MessageDeleted implements Metrics.
+func (NopMetrics) MessageDeleted(string) {}
+
+// DeleteError implements Metrics.
+func (NopMetrics) DeleteError(string) {}
+
+// MessageRetried implements Metrics.
+func (NopMetrics) MessageRetried(string, time.Duration) {}
+
+// metricsOrNop returns m, or NopMetrics when m is nil.
+func metricsOrNop(m Metrics) Metrics {
+	if m == nil {
+		return NopMetrics{}
+	}
+	return m
+}
@@ -0,0 +1,101 @@
+package sqsrouter
+
+import (
+	"expvar"
+	"time"
+)
+
+// ExpvarMetrics is a Metrics implementation backed by the standard library expvar package.
+// The counters are published as one JSON object under the given name on /debug/vars:
+//
+//	{
+//	  "routed":              {"<type>:<version>": n},
+//	  "succeeded":           {"<type>:<version>": n},
+//	  "failures":            {"<failure kind>": n},
+//	  "routing_ns":          {"<type>:<version>": total nanoseconds},
+//	  "handler_calls":       {"<type>:<version>": n},
+//	  "handler_ns":          {"<type>:<version>": total nanoseconds},
+//	  "received":            {"<queue>": n},
+//	  "receive_errors":      {"<queue>": n},
+//	  "deleted":             {"<queue>": n},
+//	  "delete_errors":       {"<queue>": n},
+//	  "retried":             {"<queue>": n}
+//	}
+//
+// Durations are exported as running totals; divide by the matching count for an average.
+type ExpvarMetrics struct {
+	root          *expvar.Map
+	routed        *expvar.Map
+	succeeded     *expvar.Map
+	failures      *expvar.Map
+	routingNanos  *expvar.Map
+	handlerCalls  *expvar.Map
+	handlerNanos  *expvar.Map
+	received      *expvar.Map
+	receiveErrors *expvar.Map
+	deleted       *expvar.Map
+	deleteErrors  *expvar.Map
+	retried       *expvar.Map
+}
+
+var _ Metrics = (*ExpvarMetrics)(nil)
+
+// NewExpvarMetrics publishes a new set of counters under name.
+// Like expvar.Publish, it panics if name is already in use.
+func NewExpvarMetrics(name string) *ExpvarMetrics {
+	m := &ExpvarMetrics{root: expvar.NewMap(name)}
+	sub := func(key string) *expvar.Map {
+		v := new(expvar.Map).Init()
+		m.root.Set(key, v)
+		return v
+	}
+	m.routed = sub("routed")
+	m.succeeded = sub("succeeded")
+	m.failures = sub("failures")
+	m.routingNanos = sub("routing_ns")
+	m.handlerCalls = sub("handler_calls")
+	m.handlerNanos = sub("handler_ns")
+	m.received = sub("received")
+	m.receiveErrors = sub("receive_errors")
+	m.deleted = sub("deleted")
+	m.deleteErrors = sub("delete_errors")
+	m.retried = sub("retried")
+	return m
+}
+
+// Map returns the published expvar map, e.g. for reading the counters in tests.
+func (m *ExpvarMetrics) Map() *expvar.Map { return m.root }
+
+// MessageRouted implements Metrics.
+func (m *ExpvarMetrics) MessageRouted(messageType, messageVersion string, kind FailureKind, d time.Duration) {
+	key := makeKey(messageType, messageVersion)
+	m.routed.Add(key, 1)
+	m.routingNanos.Add(key, d.Nanoseconds())
+	if kind == FailNone {
+		m.succeeded.Add(key, 1)
+		return
+	}
+	m.failures.Add(kind.String(), 1)
+}
+
+// HandlerDuration implements Metrics.
+func (m *ExpvarMetrics) HandlerDuration(messageType, messageVersion string, d time.Duration) {
+	key := makeKey(messageType, messageVersion)
+	m.handlerCalls.Add(key, 1)
+	m.handlerNanos.Add(key, d.Nanoseconds())
+}
+
+// MessagesReceived implements Metrics.
+func (m *ExpvarMetrics) MessagesReceived(queue string, n int) { m.received.Add(queue, int64(n)) }
+
+// ReceiveError implements Metrics.
+func (m *ExpvarMetrics) ReceiveError(queue string) { m.receiveErrors.Add(queue, 1) }
+
+// MessageDeleted implements Metrics.
+func (m *ExpvarMetrics) MessageDeleted(queue string) { m.deleted.Add(queue, 1) }
+
+// DeleteError implements Metrics.
+func (m *ExpvarMetrics) DeleteError(queue string) { m.deleteErrors.Add(queue, 1) }
+
+// MessageRetried implements Metrics.
+func (m *ExpvarMetrics) MessageRetried(queue string, _ time.Duration) { m.retried.Add(queue, 1) }
@@ -0,0 +1,120 @@
+package sqsrouter
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// recordingMetrics records the Router hooks it receives.
+type recordingMetrics struct {
+	NopMetrics
+	mu       sync.Mutex
+	routed   []FailureKind
+	handlers []string
+}
+
+func (m *recordingMetrics) MessageRouted(_, _ string, kind FailureKind, _ time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.routed = append(m.routed, kind)
+}
+
+func (m *recordingMetrics) HandlerDuration(messageType, messageVersion string, _ time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handlers = append(m.handlers, makeKey(messageType, messageVersion))
+}
+
+func TestRouterReportsMetrics(t *testing.T) {
+	m := &recordingMetrics{}
+	router, err := NewRouter(EnvelopeSchema, WithMetrics(m))
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+	router.Register("ok", "v1", func(context.Context, []byte, []byte) HandlerResult {
+		return HandlerResult{ShouldDelete: true}
+	})
+	router.Register("fail", "v1", func(context.Context, []byte, []byte) HandlerResult {
+		return HandlerResult{Error: errors.New("boom")}
+	})
+
+	router.Route(context.Background(), []byte(`{"schemaVersion":"1.0","messageType":"ok","messageVersion":"v1","message":{},"metadata":{}}`))
+	router.Route(context.Background(), []byte(`{"schemaVersion":"1.0","messageType":"fail","messageVersion":"v1","message":{},"metadata":{}}`))
+	router.Route(context.Background(), []byte(`{"schemaVersion":"1.0","messageType":"none","messageVersion":"v1","message":{},"metadata":{}}`))
+	router.Route(context.Background(), []byte(`not json`))
+
+	wantRouted := []FailureKind{FailNone, FailHandlerError, FailNoHandler, FailEnvelopeSchema}
+	if len(m.routed) != len(wantRouted) {
+		t.Fatalf("routed = %v, want %v", m.routed, wantRouted)
+	}
+	for i := range wantRouted {
+		if m.routed[i] != wantRouted[i] {
+			t.Errorf("routed[%d] = %v, want %v", i, m.routed[i], wantRouted[i])
+		}
+	}
+	if len(m.handlers) != 2 || m.handlers[0] != "ok:v1" || m.handlers[1] != "fail:v1" {
+		t.Errorf("handler durations recorded for %v, want [ok:v1 fail:v1]", m.handlers)
+	}
+}
+
+func expvarInt(t *testing.T, m *expvar.Map, sub, key string) int64 {
+	t.Helper()
+	inner, ok := m.Get(sub).(*expvar.Map)
+	if !ok {
+		t.Fatalf("missing expvar map %q", sub)
+	}
+	v, ok := inner.Get(key).(*expvar.Int)
+	if !ok {
+		return 0
+	}
+	return v.Value()
+}
+
+// expvarTestRuns makes the published names unique, as expvar names cannot be reused within a process (-count=N).
+var expvarTestRuns atomic.Int64
+
+func TestExpvarMetrics(t *testing.T) {
+	name := fmt.Sprintf("sqsrouter_test_metrics_%d", expvarTestRuns.Add(1))
+	m := NewExpvarMetrics(name)
+
+	m.MessageRouted("T", "v1", FailNone, time.Millisecond)
+	m.MessageRouted("T", "v1", FailPayloadSchema, 2*time.Millisecond)
+	m.HandlerDuration("T", "v1", 3*time.Millisecond)
+	m.MessagesReceived("q", 4)
+	m.ReceiveError("q")
+	m.MessageDeleted("q")
+	m.DeleteError("q")
+	m.MessageRetried("q", time.Second)
+
+	root := m.Map()
+	if expvar.Get(name) != root {
+		t.Fatal("metrics must be published under the given name")
+	}
+	checks := []struct {
+		sub, key string
+		want     int64
+	}{
+		{"routed", "T:v1", 2},
+		{"succeeded", "T:v1", 1},
+		{"failures", "payload_schema", 1},
+		{"routing_ns", "T:v1", int64(3 * time.Millisecond)},
+		{"handler_calls", "T:v1", 1},
+		{"handler_ns", "T:v1", int64(3 * time.Millisecond)},
+		{"received", "q", 4},
+		{"receive_errors", "q", 1},
+		{"deleted", "q", 1},
+		{"delete_errors", "q", 1},
+		{"retried", "q", 1},
+	}
+	for _, c := range checks {
+		if got := expvarInt(t, root, c.sub, c.key); got != c.want {
+			t.Errorf("%s[%s] = %d, want %d", c.sub, c.key, got, c.want)
+		}
+	}
+}
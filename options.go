@@ -1,5 +1,7 @@
 package sqsrouter
 
+import "log/slog"
+
 // RouterOption configures a Router at construction time.
 type RouterOption func(*Router)
 
@@ -12,3 +14,33 @@ func WithFailurePolicy(p FailurePolicy) RouterOption {
 func WithRoutingPolicy(p RoutingPolicy) RouterOption {
 	return func(r *Router) { r.routingPolicy = p }
 }
+
+// WithEnvelopeCodec sets the codec that decodes raw messages into envelopes, replacing the one built from
+// the envelope schema passed to NewRouter. A nil codec keeps the schema-based codec.
+func WithEnvelopeCodec(c EnvelopeCodec) RouterOption {
+	return func(r *Router) {
+		if c != nil {
+			r.envelopeCodec = c
+		}
+	}
+}
+
+// WithUnwrapping makes the Router accept messages delivered by an SNS subscription without raw message
+// delivery or by an EventBridge rule. The SNS Notification or EventBridge event is removed and its Message or
+// detail is decoded as the envelope; the wrapper fields are recorded in RouteState.Wrappers. Other messages
+// are routed unchanged.
+func WithUnwrapping() RouterOption {
+	return func(r *Router) { r.unwrap = true }
+}
+
+// WithLogger sets the logger the Router writes a debug record to for every routed message.
+// A nil logger disables logging. Without this option slog.Default() is used.
+func WithLogger(l *slog.Logger) RouterOption {
+	return func(r *Router) { r.logger = loggerOrDiscard(l) }
+}
+
+// WithMetrics sets the Metrics the Router reports routing outcomes and handler latency to.
+// A nil value disables metrics, which is also the default.
+func WithMetrics(m Metrics) RouterOption {
+	return func(r *Router) { r.metrics = metricsOrNop(m) }
+}
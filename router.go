@@ -2,9 +2,12 @@ package sqsrouter
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/hatsunemiku3939/sqsrouter/internal/jsonschema"
 )
@@ -29,39 +32,45 @@ func (e coreFailureErr) Error() string {
 func (e coreFailureErr) Unwrap() error { return e.cause }
 
 // NewRouter creates and initializes a new Router with a given envelope schema.
+// The envelope schema is compiled once and reused for every routed message. It is not used when
+// WithEnvelopeCodec sets another codec, but must still compile.
 func NewRouter(envelopeSchema string, opts ...RouterOption) (*Router, error) {
-	loader := jsonschema.NewStringLoader(envelopeSchema)
-	if _, err := jsonschema.NewSchema(loader); err != nil {
-		return nil, fmt.Errorf("%w: %v", ErrInvalidEnvelopeSchema, err)
+	// Compile the envelope schema once; every routed message is validated against the compiled form.
+	codec, err := NewJSONEnvelopeCodec(envelopeSchema)
+	if err != nil {
+		return nil, err
 	}
 
 	r := &Router{
-		handlers:       make(map[string]MessageHandler),
-		schemas:        make(map[string]jsonschema.JSONLoader),
-		envelopeSchema: loader,
-		middlewares:    nil,
-		routingPolicy:  ExactMatchPolicy{},
-		failurePolicy:  ImmediateDeletePolicy{},
+		envelopeCodec: codec,
+		routingPolicy: ExactMatchPolicy{},
+		failurePolicy: ImmediateDeletePolicy{},
+		logger:        slog.Default(),
+		metrics:       NopMetrics{},
 	}
 	for _, opt := range opts {
 		opt(r)
 	}
+	r.table.Store(r.newRoutingTable())
 	return r, nil
 }
 
 // Use appends one or more middlewares to the router.
 // Middlewares are applied in reverse registration order (last added runs first)
-// when wrapping the core routing function in Route. Concurrency-safe.
+// when wrapping the core routing function. Concurrency-safe.
+//
+// A Middleware is a factory: it is called to build the chain each time the routing table is rebuilt
+// (by Use, Register, RegisterSchema and the other registration methods), not once per message. The
+// HandlerFunc it returns is shared by all messages routed through that table, concurrently. State
+// created in the factory body therefore outlives a single message and must be safe for concurrent use;
+// per-message work and state belong inside the returned HandlerFunc.
 func (r *Router) Use(mw ...Middleware) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
 	if len(mw) == 0 {
 		return
 	}
-	newSlice := make([]Middleware, 0, len(r.middlewares)+len(mw))
-	newSlice = append(newSlice, r.middlewares...)
-	newSlice = append(newSlice, mw...)
-	r.middlewares = newSlice
+	r.changeTable(func(t *routingTable) {
+		t.middlewares = append(t.middlewares, mw...)
+	})
 }
 
 // makeKey creates a consistent key for maps from message type and version.
@@ -70,100 +79,172 @@ func makeKey(messageType, messageVersion string) string {
 }
 
 // Register adds a new message handler for a specific message type and version.
+// It panics if the routing policy rejects the handler key, e.g. an ambiguous WildcardPolicy pattern;
+// use TryRegister to handle that error instead. Policies without a HandlerKeyValidator accept every key.
 func (r *Router) Register(messageType, messageVersion string, handler MessageHandler) {
+	if err := r.TryRegister(messageType, messageVersion, handler); err != nil {
+		panic(err)
+	}
+}
+
+// TryRegister is like Register but returns the error of a rejected handler key, such as
+// ErrInvalidHandlerKey or ErrAmbiguousHandlerKey, instead of panicking. Nothing is registered then.
+func (r *Router) TryRegister(messageType, messageVersion string, handler MessageHandler) error {
+	return r.register(messageType, messageVersion, handlerEntry{handler: handler}, nil)
+}
+
+// RegisterDefault sets the handler for messages the routing policy finds no handler for, e.g. to park
+// unknown message types on another queue or forward them elsewhere instead of failing with FailNoHandler.
+// The default handler is selected under DefaultHandlerKey, and RouteState.RoutingDecision reports
+// FallbackDefaultHandler. Registering again replaces it; a nil handler removes it.
+func (r *Router) RegisterDefault(handler MessageHandler) {
+	r.changeTable(func(t *routingTable) {
+		t.defaultHandler = nil
+		if handler != nil {
+			t.defaultHandler = &handlerEntry{handler: handler}
+		}
+	})
+}
+
+// typedSchema is a payload schema generated for a handler by RegisterTyped.
+type typedSchema struct {
+	schema *jsonschema.Schema
+	// replace makes it replace a schema registered with RegisterSchema.
+	replace bool
+}
+
+// register publishes entry as the handler for the given message type and version, together with the
+// generated schema when it is not nil. A generated schema replaces a schema registered with RegisterSchema
+// only when its replace flag is set. Nothing is registered when the routing policy rejects the key.
+func (r *Router) register(messageType, messageVersion string, entry handlerEntry, schema *typedSchema) error {
 	key := makeKey(messageType, messageVersion)
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	r.handlers[key] = handler
+	return r.updateTable(func(t *routingTable) error {
+		if err := r.validateKey(t, key); err != nil {
+			return err
+		}
+		t.handlers[key] = entry
+		if _, exists := t.schemas[key]; schema != nil && (!exists || t.generated[key] || schema.replace) {
+			t.schemas[key] = schema.schema
+			t.generated[key] = true
+		}
+		return nil
+	})
+}
+
+// validateKey checks a handler key with the routing policy, if it is a HandlerKeyValidator, against the
+// other keys registered in t.
+func (r *Router) validateKey(t *routingTable, key string) error {
+	v, ok := r.routingPolicy.(HandlerKeyValidator)
+	if !ok {
+		return nil
+	}
+	registered := make([]HandlerKey, 0, len(t.handlers))
+	for k := range t.handlers {
+		if k != key {
+			registered = append(registered, HandlerKey(k))
+		}
+	}
+	slices.Sort(registered)
+	return v.ValidateKey(HandlerKey(key), registered)
 }
 
 // RegisterSchema adds a JSON schema for validating a specific message type and version.
+// The schema is compiled once here; routed messages are validated against the compiled schema.
 func (r *Router) RegisterSchema(messageType, messageVersion string, schema string) error {
-	loader := jsonschema.NewStringLoader(schema)
-	if _, err := jsonschema.NewSchema(loader); err != nil {
+	compiled, err := jsonschema.NewSchema(jsonschema.NewStringLoader(schema))
+	if err != nil {
 		return fmt.Errorf("%w for %s:%s: %v", ErrInvalidSchema, messageType, messageVersion, err)
 	}
 
 	key := makeKey(messageType, messageVersion)
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	r.schemas[key] = loader
+	r.changeTable(func(t *routingTable) {
+		t.schemas[key] = compiled
+		delete(t.generated, key)
+	})
 	return nil
 }
 
 // coreRoute executes the core routing pipeline without middleware.
 // Steps:
-//  1. Validate the raw envelope against the configured envelope schema. (important-comment)
-//  2. Unmarshal the envelope and derive the handler key.
+//  1. Unwrap SNS and EventBridge deliveries if enabled, then validate the envelope with the configured codec (natively for the default schema). (important-comment)
+//  2. Unmarshal the envelope, upcast older message versions and derive the handler key.
 //  3. Resolve the registered handler and optional payload schema.
 //  4. If a schema exists, validate the message payload.
-//  5. Marshal metadata and invoke the resolved handler. (important-comment)
+//  5. Marshal metadata (or decode the payload for typed handlers) and invoke the resolved handler. (important-comment)
 //
 // Behavior:
 //   - On failures within core routing, the Policy is consulted immediately and the decided RoutedResult is returned with a nil error.
 //   - Any panics from user handlers are not recovered here; they bubble up to the outer Route guard which maps them to FailHandlerPanic via Policy.
-func (r *Router) coreRoute(ctx context.Context, state *RouteState) (RoutedResult, error) {
-	// Step 1: Validate the envelope structure before any parsing.
-	res, err := jsonschema.Validate(r.envelopeSchema, jsonschema.NewBytesLoader(state.Raw))
-	if validationErr := jsonschema.FormatErrors(res, err); validationErr != nil {
+func (r *Router) coreRoute(ctx context.Context, t *routingTable, state *RouteState) (RoutedResult, error) {
+	// Steps 1-2: Validate the envelope structure and parse it to extract routing metadata and payload.
+	envelope, kind, err := r.decodeBody(state)
+	if err != nil {
 		rr := RoutedResult{
 			MessageType:    "unknown",
 			MessageVersion: "unknown",
 			HandlerResult: HandlerResult{
 				ShouldDelete: false,
-				Error:        fmt.Errorf("%w: %v", ErrInvalidEnvelope, validationErr),
+				Error:        err,
 			},
 		}
-		pr := r.failurePolicy.Decide(ctx, FailEnvelopeSchema, rr.HandlerResult.Error, FailureResult{ShouldDelete: rr.HandlerResult.ShouldDelete, Error: rr.HandlerResult.Error})
-		rr.HandlerResult.ShouldDelete = pr.ShouldDelete
-		rr.HandlerResult.Error = pr.Error
-		return rr, coreFailureErr{kind: FailEnvelopeSchema, cause: rr.HandlerResult.Error}
+		r.applyFailurePolicy(ctx, kind, rr.HandlerResult.Error, &rr)
+		return rr, coreFailureErr{kind: kind, cause: rr.HandlerResult.Error}
 	}
-
-	// Step 2: Parse the envelope to extract routing metadata and payload.
-	var envelope MessageEnvelope
-	if err := json.Unmarshal(state.Raw, &envelope); err != nil {
+	state.Envelope = &envelope
+	// Upcast older versions so the message is routed, validated and handled as the newest version.
+	// Results keep reporting the version the message arrived with.
+	messageVersion := envelope.MessageVersion
+	if err := t.upcast(ctx, state); err != nil {
 		rr := RoutedResult{
-			MessageType:    "unknown",
-			MessageVersion: "unknown",
+			MessageType:    envelope.MessageType,
+			MessageVersion: messageVersion,
 			HandlerResult: HandlerResult{
 				ShouldDelete: false,
-				Error:        fmt.Errorf("%w: %v", ErrFailedToParseEnvelope, err),
+				Error:        err,
 			},
+			MessageID: envelope.Metadata.MessageID,
+			Timestamp: envelope.Metadata.Timestamp,
 		}
-		pr := r.failurePolicy.Decide(ctx, FailEnvelopeParse, rr.HandlerResult.Error, FailureResult{ShouldDelete: rr.HandlerResult.ShouldDelete, Error: rr.HandlerResult.Error})
-		rr.HandlerResult.ShouldDelete = pr.ShouldDelete
-		rr.HandlerResult.Error = pr.Error
-		return rr, coreFailureErr{kind: FailEnvelopeParse, cause: rr.HandlerResult.Error}
+		r.applyFailurePolicy(ctx, FailUpcast, rr.HandlerResult.Error, &rr)
+		return rr, coreFailureErr{kind: FailUpcast, cause: rr.HandlerResult.Error}
 	}
-	state.Envelope = &envelope
 	// Decide handler using routing policy.
-	r.mu.RLock()
-	available := make([]HandlerKey, 0, len(r.handlers))
-	for k := range r.handlers {
-		available = append(available, HandlerKey(k))
+	decision := r.decideRouting(ctx, &envelope, t.keys)
+
+	// Step 3: Resolve handler and optional payload schema from the routing table snapshot.
+	// Messages without a handler go to the default handler, if one is registered.
+	entry, handlerExists := t.handlers[string(decision.Key)]
+	if !handlerExists && t.defaultHandler != nil {
+		entry, handlerExists = *t.defaultHandler, true
+		decision = RoutingDecision{Key: DefaultHandlerKey, Fallback: FallbackDefaultHandler}
 	}
-	r.mu.RUnlock()
-	decided := r.routingPolicy.Decide(ctx, &envelope, available)
-	state.HandlerKey = string(decided)
-
-	// Step 3: Resolve handler and optional payload schema under read lock.
-	r.mu.RLock()
-	handler, handlerExists := r.handlers[state.HandlerKey]
-	schemaLoader, schemaExists := r.schemas[state.HandlerKey]
-	r.mu.RUnlock()
-	state.Handler = handler
-	state.Schema = schemaLoader
+	schema, schemaExists := t.schemas[string(decision.Key)]
+	if !schemaExists && decision.Variant != "" {
+		// A variant without a schema of its own is validated like the plain type:version it was selected for.
+		schema, schemaExists = t.schemas[strings.TrimSuffix(string(decision.Key), variantSeparator+decision.Variant)]
+	}
+	state.HandlerKey = string(decision.Key)
+	state.RoutingDecision = decision
+	state.Handler = entry.handler
+	state.Schema = schema
 	state.HandlerExists = handlerExists
 	state.SchemaExists = schemaExists
+	if decision.Fallback != "" {
+		r.logger.LogAttrs(ctx, slog.LevelDebug, "routing fallback applied",
+			slog.String(LogKeyMessageType, envelope.MessageType),
+			slog.String(LogKeyMessageVersion, envelope.MessageVersion),
+			slog.String(LogKeyHandlerKey, state.HandlerKey),
+			slog.String(LogKeyFallback, decision.Fallback),
+		)
+	}
 
 	// Step 4: If a schema is registered, validate the message payload.
 	if schemaExists {
-		res, err := jsonschema.Validate(schemaLoader, jsonschema.NewBytesLoader(envelope.Message))
+		res, err := jsonschema.ValidateSchema(schema, jsonschema.NewBytesLoader(envelope.Message))
 		if validationErr := jsonschema.FormatErrors(res, err); validationErr != nil {
 			rr := RoutedResult{
 				MessageType:    envelope.MessageType,
-				MessageVersion: envelope.MessageVersion,
+				MessageVersion: messageVersion,
 				HandlerResult: HandlerResult{
 					ShouldDelete: false,
 					Error:        fmt.Errorf("%w: %v", ErrInvalidMessagePayload, validationErr),
@@ -171,28 +252,28 @@ func (r *Router) coreRoute(ctx context.Context, state *RouteState) (RoutedResult
 				MessageID: envelope.Metadata.MessageID,
 				Timestamp: envelope.Metadata.Timestamp,
 			}
-			pr := r.failurePolicy.Decide(ctx, FailPayloadSchema, rr.HandlerResult.Error, FailureResult{ShouldDelete: rr.HandlerResult.ShouldDelete, Error: rr.HandlerResult.Error})
-			rr.HandlerResult.ShouldDelete = pr.ShouldDelete
-			rr.HandlerResult.Error = pr.Error
+			r.applyFailurePolicy(ctx, FailPayloadSchema, rr.HandlerResult.Error, &rr)
 			return rr, coreFailureErr{kind: FailPayloadSchema, cause: rr.HandlerResult.Error}
 		}
 	}
 
 	// Step 5: Ensure a handler exists for the resolved key; otherwise fail fast for this message.
 	if !handlerExists {
+		noHandlerErr := fmt.Errorf("%w for %s", ErrNoHandlerRegistered, state.HandlerKey)
+		if decision.Err != nil {
+			noHandlerErr = fmt.Errorf("%w: %w", ErrNoHandlerRegistered, decision.Err)
+		}
 		rr := RoutedResult{
 			MessageType:    envelope.MessageType,
-			MessageVersion: envelope.MessageVersion,
+			MessageVersion: messageVersion,
 			HandlerResult: HandlerResult{
 				ShouldDelete: false,
-				Error:        fmt.Errorf("%w for %s", ErrNoHandlerRegistered, state.HandlerKey),
+				Error:        noHandlerErr,
 			},
 			MessageID: envelope.Metadata.MessageID,
 			Timestamp: envelope.Metadata.Timestamp,
 		}
-		pr := r.failurePolicy.Decide(ctx, FailNoHandler, rr.HandlerResult.Error, FailureResult{ShouldDelete: rr.HandlerResult.ShouldDelete, Error: rr.HandlerResult.Error})
-		rr.HandlerResult.ShouldDelete = pr.ShouldDelete
-		rr.HandlerResult.Error = pr.Error
+		r.applyFailurePolicy(ctx, FailNoHandler, rr.HandlerResult.Error, &rr)
 		return rr, coreFailureErr{kind: FailNoHandler, cause: rr.HandlerResult.Error}
 	}
 
@@ -200,59 +281,126 @@ func (r *Router) coreRoute(ctx context.Context, state *RouteState) (RoutedResult
 	meta := envelope.Metadata
 	state.Metadata = &meta
 
-	// Marshal metadata to JSON so handler signature remains stable and decoupled.
-	metaJSON, err := json.Marshal(meta)
-	if err != nil {
-		rr := RoutedResult{
-			MessageType:    envelope.MessageType,
-			MessageVersion: envelope.MessageVersion,
-			HandlerResult: HandlerResult{
-				ShouldDelete: true,
-				Error:        fmt.Errorf("failed to marshal metadata: %w", err),
-			},
+	// Typed handlers decode the payload up front; a payload that does not fit is a failure of its own.
+	var call func(ctx context.Context) HandlerResult
+	if entry.bind != nil {
+		bound, err := entry.bind(&envelope)
+		if err != nil {
+			rr := RoutedResult{
+				MessageType:    envelope.MessageType,
+				MessageVersion: messageVersion,
+				HandlerResult: HandlerResult{
+					ShouldDelete: false,
+					Error:        err,
+				},
+				MessageID: meta.MessageID,
+				Timestamp: meta.Timestamp,
+			}
+			r.applyFailurePolicy(ctx, FailPayloadDecode, rr.HandlerResult.Error, &rr)
+			return rr, coreFailureErr{kind: FailPayloadDecode, cause: rr.HandlerResult.Error}
 		}
-		return rr, rr.HandlerResult.Error
+		call = bound
+	} else {
+		// Marshal metadata to JSON so handler signature remains stable and decoupled.
+		metaJSON, err := marshalMetadata(meta)
+		if err != nil {
+			rr := RoutedResult{
+				MessageType:    envelope.MessageType,
+				MessageVersion: messageVersion,
+				HandlerResult: HandlerResult{
+					ShouldDelete: true,
+					Error:        fmt.Errorf("failed to marshal metadata: %w", err),
+				},
+			}
+			return rr, rr.HandlerResult.Error
+		}
+		call = func(ctx context.Context) HandlerResult { return entry.handler(ctx, envelope.Message, metaJSON) }
 	}
+
 	// Invoke the resolved handler with payload and metadata.
 	// Do not recover here; allow panics to bubble to Route, which maps them to FailHandlerPanic via Policy.
-	handlerResult := handler(ctx, envelope.Message, metaJSON)
+	handlerStart := time.Now()
+	handlerResult := call(ctx)
+	r.metrics.HandlerDuration(envelope.MessageType, messageVersion, time.Since(handlerStart))
 
 	// Assemble the routed result from handler output.
 	rr := RoutedResult{
 		MessageType:    envelope.MessageType,
-		MessageVersion: envelope.MessageVersion,
+		MessageVersion: messageVersion,
 		HandlerResult:  handlerResult,
 		MessageID:      meta.MessageID,
 		Timestamp:      meta.Timestamp,
 	}
 	// If handler returned an error, consult Policy so it can be the final decider.
 	if handlerResult.Error != nil {
-		pr := r.failurePolicy.Decide(ctx, FailHandlerError, handlerResult.Error, FailureResult{ShouldDelete: rr.HandlerResult.ShouldDelete, Error: rr.HandlerResult.Error})
-		rr.HandlerResult.ShouldDelete = pr.ShouldDelete
-		rr.HandlerResult.Error = pr.Error
+		r.applyFailurePolicy(ctx, FailHandlerError, handlerResult.Error, &rr)
 		return rr, nil
 	}
 	// No error: return as-is.
 	return rr, nil
 }
 
+// decideRouting asks the routing policy for a handler, with its explanation when the policy provides one.
+func (r *Router) decideRouting(ctx context.Context, envelope *MessageEnvelope, keys []HandlerKey) RoutingDecision {
+	if d, ok := r.routingPolicy.(RoutingDecider); ok {
+		return d.DecideRouting(ctx, envelope, keys)
+	}
+	return RoutingDecision{Key: r.routingPolicy.Decide(ctx, envelope, keys)}
+}
+
+// applyFailurePolicy consults the failure policy for the given failure and writes its decision back into rr.
+func (r *Router) applyFailurePolicy(ctx context.Context, kind FailureKind, inner error, rr *RoutedResult) {
+	hr := &rr.HandlerResult
+	pr := r.failurePolicy.Decide(ctx, kind, inner, FailureResult{ShouldDelete: hr.ShouldDelete, Error: hr.Error, RetryAfter: hr.RetryAfter})
+	hr.ShouldDelete = pr.ShouldDelete
+	hr.Error = pr.Error
+	hr.RetryAfter = pr.RetryAfter
+	rr.FailureKind = kind
+}
+
 // Route validates and dispatches a raw message to the appropriate registered handler.
 func (r *Router) Route(ctx context.Context, rawMessage []byte) RoutedResult {
-	// Prepare per-message state container.
-	state := &RouteState{Raw: rawMessage}
-
-	r.mu.RLock()
-	mws := r.middlewares
-	r.mu.RUnlock()
+	start := time.Now()
+	routed := r.route(ctx, rawMessage)
+	d := time.Since(start)
+	r.metrics.MessageRouted(routed.MessageType, routed.MessageVersion, routed.FailureKind, d)
+	r.logRouted(ctx, routed, d)
+	return routed
+}
 
-	core := func(ctx context.Context, s *RouteState) (RoutedResult, error) {
-		return r.coreRoute(ctx, s)
+// logRouted writes a debug record describing the outcome of Route.
+func (r *Router) logRouted(ctx context.Context, routed RoutedResult, d time.Duration) {
+	if !r.logger.Enabled(ctx, slog.LevelDebug) {
+		return
 	}
+	attrs := []slog.Attr{
+		slog.String(LogKeyMessageType, routed.MessageType),
+		slog.String(LogKeyMessageVersion, routed.MessageVersion),
+		slog.String(LogKeyMessageID, routed.MessageID),
+		slog.String(LogKeyFailureKind, routed.FailureKind.String()),
+		slog.Duration(LogKeyDuration, d),
+		slog.Bool(LogKeyDeleted, routed.HandlerResult.ShouldDelete),
+	}
+	if routed.Variant != "" {
+		attrs = append(attrs, slog.String(LogKeyVariant, routed.Variant))
+	}
+	if routed.HandlerResult.Error != nil {
+		attrs = append(attrs, slog.String(LogKeyError, routed.HandlerResult.Error.Error()))
+	}
+	r.logger.LogAttrs(ctx, slog.LevelDebug, "message routed", attrs...)
+}
 
-	for i := len(mws) - 1; i >= 0; i-- {
-		core = mws[i](core)
+// route runs the middleware-wrapped routing pipeline under a panic guard.
+func (r *Router) route(ctx context.Context, rawMessage []byte) RoutedResult {
+	// Prepare per-message state container.
+	state := &RouteState{Raw: rawMessage}
+	if attrs, ok := SQSAttributesFromContext(ctx); ok {
+		state.SQS = attrs
 	}
 
+	// The chain of this snapshot already wraps coreRoute in the registered middlewares.
+	core := r.table.Load().chain
+
 	// Execute the middleware-wrapped core with an outermost panic recovery guard.
 	var routed RoutedResult
 	var err error
@@ -268,6 +416,9 @@ func (r *Router) Route(ctx context.Context, rawMessage []byte) RoutedResult {
 				if state.Envelope != nil {
 					msgType = state.Envelope.MessageType
 					msgVer = state.Envelope.MessageVersion
+					if state.OriginalVersion != "" {
+						msgVer = state.OriginalVersion
+					}
 					msgID = state.Envelope.Metadata.MessageID
 					timestamp = state.Envelope.Metadata.Timestamp
 				}
@@ -282,9 +433,7 @@ func (r *Router) Route(ctx context.Context, rawMessage []byte) RoutedResult {
 					Timestamp: timestamp,
 				}
 
-				pr := r.failurePolicy.Decide(ctx, FailHandlerPanic, tmp.HandlerResult.Error, FailureResult{ShouldDelete: tmp.HandlerResult.ShouldDelete, Error: tmp.HandlerResult.Error})
-				tmp.HandlerResult.ShouldDelete = pr.ShouldDelete
-				tmp.HandlerResult.Error = pr.Error
+				r.applyFailurePolicy(ctx, FailHandlerPanic, tmp.HandlerResult.Error, &tmp)
 				routed = tmp
 
 				err = nil
@@ -296,6 +445,9 @@ func (r *Router) Route(ctx context.Context, rawMessage []byte) RoutedResult {
 		routed, err = core(ctx, state)
 	}()
 
+	// The selected variant is part of the routing decision, whichever path produced the result.
+	routed.Variant = state.RoutingDecision.Variant
+
 	if !panicOccurred && err != nil {
 		// If the error originated from coreRoute (already policy-decided), do not re-apply policy.
 		var cfe coreFailureErr
@@ -303,9 +455,7 @@ func (r *Router) Route(ctx context.Context, rawMessage []byte) RoutedResult {
 			return routed
 		}
 		// Else, treat as middleware error and consult policy once.
-		pr := r.failurePolicy.Decide(ctx, FailMiddlewareError, err, FailureResult{ShouldDelete: routed.HandlerResult.ShouldDelete, Error: routed.HandlerResult.Error})
-		routed.HandlerResult.ShouldDelete = pr.ShouldDelete
-		routed.HandlerResult.Error = pr.Error
+		r.applyFailurePolicy(ctx, FailMiddlewareError, err, &routed)
 		return routed
 	}
 
@@ -314,15 +464,6 @@ func (r *Router) Route(ctx context.Context, rawMessage []byte) RoutedResult {
 
 // --- Schemas ---
 
-var EnvelopeSchema = `{
-  "$schema": "http://json-schema.org/draft-07/schema#",
-  "type": "object",
-  "properties": {
-    "schemaVersion": { "type": "string" },
-    "messageType": { "type": "string" },
-    "messageVersion": { "type": "string" },
-    "message": { "type": "object" },
-    "metadata": { "type": "object" }
-  },
-  "required": ["schemaVersion", "messageType", "messageVersion", "message", "metadata"]
-}`
+// EnvelopeSchema is the default envelope schema. Routers created with it decode envelopes with a
+// native validator instead of running JSON Schema validation for every message.
+var EnvelopeSchema = defaultEnvelopeSchema
@@ -0,0 +1,94 @@
+package sqsrouter
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const benchPayloadSchema = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "required": ["orderId", "customerId", "items", "total", "status"],
+  "properties": {
+    "orderId": { "type": "string", "minLength": 8 },
+    "customerId": { "type": "string" },
+    "status": { "type": "string", "enum": ["created", "paid", "shipped", "cancelled"] },
+    "total": { "type": "number", "minimum": 0 },
+    "items": {
+      "type": "array",
+      "minItems": 1,
+      "items": {
+        "type": "object",
+        "required": ["sku", "quantity"],
+        "properties": {
+          "sku": { "type": "string" },
+          "quantity": { "type": "integer", "minimum": 1 }
+        }
+      }
+    }
+  }
+}`
+
+const benchMessage = `{"schemaVersion":"1.0","messageType":"order.created","messageVersion":"1.0",` +
+	`"message":{"orderId":"ord-00000001","customerId":"cus-42","status":"paid","total":42.5,` +
+	`"items":[{"sku":"SKU-1","quantity":2},{"sku":"SKU-2","quantity":1}]},` +
+	`"metadata":{"timestamp":"2024-01-01T00:00:00Z","source":"bench","messageId":"m-1"}}`
+
+// BenchmarkRoute measures the per-message cost of the full routing pipeline, including envelope and
+// payload schema validation, with the native envelope decoder and with JSON Schema envelope validation.
+func BenchmarkRoute(b *testing.B) {
+	customEnvelope := strings.Replace(EnvelopeSchema, `"type": "object",`, `"type": "object", "description": "custom",`, 1)
+	for name, envelopeSchema := range map[string]string{"native": EnvelopeSchema, "schema": customEnvelope} {
+		b.Run("envelope="+name, func(b *testing.B) {
+			router, err := NewRouter(envelopeSchema, WithLogger(nil))
+			if err != nil {
+				b.Fatal(err)
+			}
+			if err := router.RegisterSchema("order.created", "1.0", benchPayloadSchema); err != nil {
+				b.Fatal(err)
+			}
+			router.Register("order.created", "1.0", func(context.Context, []byte, []byte) HandlerResult {
+				return HandlerResult{ShouldDelete: true}
+			})
+
+			raw := []byte(benchMessage)
+			ctx := context.Background()
+			b.ReportAllocs()
+			for b.Loop() {
+				if rr := router.Route(ctx, raw); rr.HandlerResult.Error != nil {
+					b.Fatal(rr.HandlerResult.Error)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkRouteParallel measures Route under contention from concurrent consumers.
+func BenchmarkRouteParallel(b *testing.B) {
+	router, err := NewRouter(EnvelopeSchema, WithLogger(nil))
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := router.RegisterSchema("order.created", "1.0", benchPayloadSchema); err != nil {
+		b.Fatal(err)
+	}
+	router.Register("order.created", "1.0", func(context.Context, []byte, []byte) HandlerResult {
+		return HandlerResult{ShouldDelete: true}
+	})
+	for i := 0; i < 50; i++ {
+		router.Register("other", strconv.Itoa(i), func(context.Context, []byte, []byte) HandlerResult {
+			return HandlerResult{ShouldDelete: true}
+		})
+	}
+
+	raw := []byte(benchMessage)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		ctx := context.Background()
+		for pb.Next() {
+			router.Route(ctx, raw)
+		}
+	})
+}
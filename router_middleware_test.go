@@ -148,3 +148,39 @@ func TestNoMiddlewareCompatibility(t *testing.T) {
 		t.Fatalf("unexpected result without middleware: %+v", rr)
 	}
 }
+
+func TestMiddlewareSeesSQSAttributes(t *testing.T) {
+	router, err := NewRouter(EnvelopeSchema)
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+
+	var seen *SQSAttributes
+	router.Use(func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, s *RouteState) (RoutedResult, error) {
+			seen = s.SQS
+			return next(ctx, s)
+		}
+	})
+	var handlerAttrs *SQSAttributes
+	router.Register("T", "v1", func(ctx context.Context, msgJSON []byte, metaJSON []byte) HandlerResult {
+		handlerAttrs, _ = SQSAttributesFromContext(ctx)
+		return HandlerResult{ShouldDelete: true}
+	})
+
+	attrs := &SQSAttributes{MessageID: "sqs-1", ApproximateReceiveCount: 3, AWSTraceHeader: "Root=1-abc"}
+	raw := []byte(`{"schemaVersion":"1.0","messageType":"T","messageVersion":"v1","message":{},"metadata":{}}`)
+	_ = router.Route(WithSQSAttributes(context.Background(), attrs), raw)
+
+	if seen != attrs {
+		t.Fatalf("RouteState.SQS = %+v, want %+v", seen, attrs)
+	}
+	if handlerAttrs != attrs {
+		t.Fatalf("handler context attributes = %+v, want %+v", handlerAttrs, attrs)
+	}
+
+	_ = router.Route(context.Background(), raw)
+	if seen != nil {
+		t.Fatalf("RouteState.SQS should be nil without attributes, got %+v", seen)
+	}
+}
@@ -6,6 +6,7 @@ import (
     "fmt"
     "sync"
     "testing"
+    "time"
 
     "github.com/stretchr/testify/assert"
     "github.com/stretchr/testify/require"
@@ -96,7 +97,7 @@ func TestRouter_Register(t *testing.T) {
 	r.Register(testMessageType, testMessageVersion, testSuccessHandler)
 
 	key := makeKey(testMessageType, testMessageVersion)
-	_, exists := r.handlers[key]
+	_, exists := r.table.Load().handlers[key]
 	assert.True(t, exists, "Handler should be registered")
 }
 
@@ -108,7 +109,7 @@ func TestRouter_RegisterSchema(t *testing.T) {
 		assert.NoError(t, err)
 
 		key := makeKey(testMessageType, testMessageVersion)
-		_, exists := r.schemas[key]
+		_, exists := r.table.Load().schemas[key]
 		assert.True(t, exists, "Schema should be registered")
 	})
 
@@ -192,6 +193,35 @@ func TestRouter_Route(t *testing.T) {
 		assert.False(t, result.HandlerResult.ShouldDelete)
 	})
 
+	t.Run("should carry retry delay from handler", func(t *testing.T) {
+		r := newTestRouter(t)
+		r.Register(testMessageType, testMessageVersion, func(_ context.Context, _, _ []byte) HandlerResult {
+			return HandlerResult{ShouldDelete: false, Error: errors.New("throttled"), RetryAfter: 30 * time.Second}
+		})
+
+		payload := `{"userId": "123", "username": "test"}`
+		msg := createTestMessage(t, testMessageType, testMessageVersion, payload)
+
+		result := r.Route(context.Background(), msg)
+
+		assert.False(t, result.HandlerResult.ShouldDelete)
+		assert.Equal(t, 30*time.Second, result.HandlerResult.RetryAfter)
+	})
+
+	t.Run("policy can set retry delay", func(t *testing.T) {
+		r, err := NewRouter(testEnvelopeSchema, WithFailurePolicy(ExponentialBackoffPolicy{Base: time.Second}))
+		require.NoError(t, err)
+		r.Register(testMessageType, testMessageVersion, testRetryHandler)
+
+		payload := `{"userId": "123", "username": "test"}`
+		msg := createTestMessage(t, testMessageType, testMessageVersion, payload)
+
+		result := r.Route(WithSQSAttributes(context.Background(), &SQSAttributes{ApproximateReceiveCount: 3}), msg)
+
+		assert.False(t, result.HandlerResult.ShouldDelete)
+		assert.Equal(t, 4*time.Second, result.HandlerResult.RetryAfter)
+	})
+
 	t.Run("should fail for unregistered handler", func(t *testing.T) {
 		r := newTestRouter(t) // No handlers registered
 
@@ -307,6 +337,6 @@ func TestRouter_Concurrency(t *testing.T) {
 
 	// Verify the new handler was registered
 	key := makeKey("another.type", "1.0")
-	_, exists := r.handlers[key]
+	_, exists := r.table.Load().handlers[key]
 	assert.True(t, exists, "New handler should be registered concurrently")
 }
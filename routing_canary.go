@@ -0,0 +1,169 @@
+package sqsrouter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"sync"
+)
+
+// CanaryFallbackVariant is reported by CanaryPolicy in RoutingDecision.Fallback when the split selected a
+// variant handler rather than the one registered for the plain type:version.
+const CanaryFallbackVariant = "canary_variant"
+
+// canaryBuckets is the resolution of a split: a variant's share is rounded to 1/canaryBuckets of the traffic.
+const canaryBuckets = 10000
+
+// CanaryVariant is one handler of a canary split. Name "" is the handler registered for the plain
+// type:version; any other name is the handler registered under Variant(messageVersion, Name).
+type CanaryVariant struct {
+	Name   string
+	Weight int
+}
+
+// CanarySplit divides the traffic of one message type and version between handler variants.
+type CanarySplit struct {
+	// Variants receive messages in proportion to their weights. Each variant owns a contiguous range of the
+	// hash space in the order given, so list the canary first: raising its weight then only moves entities
+	// onto it, and entities already routed to it stay there.
+	Variants []CanaryVariant
+	// HashPointer is a JSON pointer to the payload field that identifies the entity, e.g. "/orderId".
+	// Messages of the same entity go to the same variant. When empty, or when the field is missing,
+	// metadata.messageId is hashed instead.
+	HashPointer string
+}
+
+// CanaryPolicy splits the traffic of a message type and version between several handler implementations
+// by consistent hashing, e.g. to send 5% of orders to a rewritten handler. Splits are set with Set and
+// can be changed at runtime. Messages of types without a split are routed by exact match, and so are
+// messages hashed to a variant without a registered handler, so a split can be set before its handler
+// is deployed.
+// Variants are validated against the payload schema of the plain type:version unless they have their own.
+// The selected variant is reported in RoutedResult.Variant. A CanaryPolicy is safe for concurrent use.
+type CanaryPolicy struct {
+	mu     sync.RWMutex
+	splits map[string]*canarySplit
+}
+
+type canarySplit struct {
+	variants []string
+	// bounds[i] is the exclusive upper bucket of variants[i].
+	bounds  []uint64
+	pointer []string
+}
+
+// NewCanaryPolicy returns a CanaryPolicy without splits.
+func NewCanaryPolicy() *CanaryPolicy {
+	return &CanaryPolicy{splits: make(map[string]*canarySplit)}
+}
+
+// Set installs or replaces the split for a message type and version. Weights must not be negative and
+// must not all be zero; variants with weight zero receive no traffic.
+func (p *CanaryPolicy) Set(messageType, messageVersion string, split CanarySplit) error {
+	key := makeKey(messageType, messageVersion)
+	total := 0
+	seen := make(map[string]bool, len(split.Variants))
+	for _, v := range split.Variants {
+		if v.Weight < 0 {
+			return fmt.Errorf("%w for %s: variant %q has negative weight %d", ErrInvalidCanarySplit, key, v.Name, v.Weight)
+		}
+		if seen[v.Name] {
+			return fmt.Errorf("%w for %s: duplicate variant %q", ErrInvalidCanarySplit, key, v.Name)
+		}
+		seen[v.Name] = true
+		total += v.Weight
+	}
+	if total == 0 {
+		return fmt.Errorf("%w for %s: total weight must be positive", ErrInvalidCanarySplit, key)
+	}
+	pointer, err := parseJSONPointer(split.HashPointer)
+	if err != nil {
+		return fmt.Errorf("%w for %s: %v", ErrInvalidCanarySplit, key, err)
+	}
+
+	s := &canarySplit{pointer: pointer}
+	cum := 0
+	for _, v := range split.Variants {
+		cum += v.Weight
+		s.variants = append(s.variants, v.Name)
+		s.bounds = append(s.bounds, uint64(cum)*canaryBuckets/uint64(total)) //nolint:gosec // weights are not negative
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.splits[key] = s
+	return nil
+}
+
+// Remove deletes the split for a message type and version; its messages are routed by exact match again.
+func (p *CanaryPolicy) Remove(messageType, messageVersion string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.splits, makeKey(messageType, messageVersion))
+}
+
+// Decide returns the key of the selected handler; otherwise empty.
+func (p *CanaryPolicy) Decide(ctx context.Context, envelope *MessageEnvelope, available []HandlerKey) HandlerKey { //nolint:revive
+	return p.DecideRouting(ctx, envelope, available).Key
+}
+
+// DecideRouting returns the handler of the variant the message hashes to.
+func (p *CanaryPolicy) DecideRouting(ctx context.Context, envelope *MessageEnvelope, available []HandlerKey) RoutingDecision { //nolint:revive
+	p.mu.RLock()
+	split := p.splits[makeKey(envelope.MessageType, envelope.MessageVersion)]
+	p.mu.RUnlock()
+	if split == nil {
+		return RoutingDecision{Key: ExactMatchPolicy{}.Decide(ctx, envelope, available)}
+	}
+
+	bucket := canaryHash(split.entity(envelope)) % canaryBuckets
+	for i, bound := range split.bounds {
+		if bucket >= bound {
+			continue
+		}
+		name := split.variants[i]
+		variantKey := HandlerKey(makeKey(envelope.MessageType, Variant(envelope.MessageVersion, name)))
+		if name == "" || !slices.Contains(available, variantKey) {
+			return RoutingDecision{Key: ExactMatchPolicy{}.Decide(ctx, envelope, available)}
+		}
+		return RoutingDecision{
+			Key:      variantKey,
+			Fallback: CanaryFallbackVariant,
+			Variant:  name,
+		}
+	}
+	return RoutingDecision{} // unreachable: the last bound is canaryBuckets
+}
+
+// entity returns the value that identifies the message's entity for hashing. Only the objects and arrays
+// on the way to the hash pointer are decoded; strings are hashed unquoted, other values as compact JSON.
+func (s *canarySplit) entity(envelope *MessageEnvelope) string {
+	if s.pointer != nil {
+		if v, ok := lookupRawJSON(envelope.Message, s.pointer); ok {
+			switch jsonTypeOf(v) {
+			case "null":
+			case "string":
+				var str string
+				if json.Unmarshal(v, &str) == nil {
+					return str
+				}
+			default:
+				var b bytes.Buffer
+				if json.Compact(&b, v) == nil {
+					return b.String()
+				}
+			}
+		}
+	}
+	return envelope.Metadata.MessageID
+}
+
+// canaryHash hashes an entity with 64-bit FNV-1a.
+func canaryHash(entity string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(entity)) //nolint:errcheck // hash.Hash.Write never returns an error
+	return h.Sum64()
+}
@@ -0,0 +1,225 @@
+package sqsrouter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func canaryEnvelope(messageID, payload string) *MessageEnvelope {
+	return &MessageEnvelope{
+		MessageType:    "orderPlaced",
+		MessageVersion: "2.0",
+		Message:        []byte(payload),
+		Metadata:       MessageMetadata{MessageID: messageID},
+	}
+}
+
+// canaryKeys are the handler keys registered for the variants used in these tests.
+var canaryKeys = []HandlerKey{"orderPlaced:2.0", "orderPlaced:2.0@a", "orderPlaced:2.0@b", "orderPlaced:2.0@legacy", "orderPlaced:2.0@rewrite"}
+
+func TestCanaryPolicy_Split(t *testing.T) {
+	t.Parallel()
+	p := NewCanaryPolicy()
+	err := p.Set("orderPlaced", "2.0", CanarySplit{Variants: []CanaryVariant{{Name: "rewrite", Weight: 5}, {Name: "", Weight: 95}}})
+	if err != nil {
+		t.Fatalf("set: %v", err)
+	}
+
+	counts := map[RoutingDecision]int{}
+	const n = 20000
+	for i := range n {
+		counts[p.DecideRouting(context.Background(), canaryEnvelope(fmt.Sprintf("msg-%d", i), `{}`), canaryKeys)]++
+	}
+	canary := counts[RoutingDecision{Key: "orderPlaced:2.0@rewrite", Fallback: CanaryFallbackVariant, Variant: "rewrite"}]
+	baseline := counts[RoutingDecision{Key: "orderPlaced:2.0"}]
+	if canary+baseline != n {
+		t.Fatalf("unexpected decisions: %v", counts)
+	}
+	if canary < n*3/100 || canary > n*7/100 {
+		t.Errorf("canary received %d of %d messages, want about 5%%", canary, n)
+	}
+}
+
+func TestCanaryPolicy_StickyPerEntity(t *testing.T) {
+	t.Parallel()
+	p := NewCanaryPolicy()
+	set := func(canaryWeight int) {
+		t.Helper()
+		err := p.Set("orderPlaced", "2.0", CanarySplit{
+			Variants:    []CanaryVariant{{Name: "rewrite", Weight: canaryWeight}, {Name: "legacy", Weight: 100 - canaryWeight}},
+			HashPointer: "/orderId",
+		})
+		if err != nil {
+			t.Fatalf("set: %v", err)
+		}
+	}
+	variantOf := func(orderID int, messageID string) string {
+		env := canaryEnvelope(messageID, fmt.Sprintf(`{"orderId":%d}`, orderID))
+		return p.DecideRouting(context.Background(), env, canaryKeys).Variant
+	}
+
+	set(10)
+	before := map[int]string{}
+	for id := range 500 {
+		before[id] = variantOf(id, "a")
+		if got := variantOf(id, "b"); got != before[id] {
+			t.Fatalf("order %d routed to %q and %q for different messages", id, before[id], got)
+		}
+	}
+
+	// Ramping the canary up keeps every entity already on it there.
+	set(50)
+	moved := 0
+	for id, was := range before {
+		now := variantOf(id, "c")
+		if was == "rewrite" && now != "rewrite" {
+			t.Fatalf("order %d left the canary when its weight was raised", id)
+		}
+		if was != now {
+			moved++
+		}
+	}
+	if moved == 0 {
+		t.Error("raising the canary weight should move some orders onto it")
+	}
+}
+
+func TestCanaryPolicy_HashFallsBackToMessageID(t *testing.T) {
+	t.Parallel()
+	p := NewCanaryPolicy()
+	if err := p.Set("orderPlaced", "2.0", CanarySplit{Variants: []CanaryVariant{{Name: "a", Weight: 1}, {Name: "b", Weight: 1}}, HashPointer: "/orderId"}); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	seen := map[string]bool{}
+	for i := range 100 {
+		seen[p.DecideRouting(context.Background(), canaryEnvelope(fmt.Sprintf("m-%d", i), `{}`), canaryKeys).Variant] = true
+	}
+	if !seen["a"] || !seen["b"] {
+		t.Errorf("messages without the hash field should be spread by message ID, got %v", seen)
+	}
+}
+
+func TestCanaryPolicy_UnregisteredVariantFallsBack(t *testing.T) {
+	t.Parallel()
+	p := NewCanaryPolicy()
+	if err := p.Set("orderPlaced", "2.0", CanarySplit{Variants: []CanaryVariant{{Name: "rewrite", Weight: 1}}}); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	env := canaryEnvelope("m-1", `{}`)
+
+	if got := p.DecideRouting(context.Background(), env, []HandlerKey{"orderPlaced:2.0"}); got != (RoutingDecision{Key: "orderPlaced:2.0"}) {
+		t.Errorf("got %+v, want the plain handler", got)
+	}
+	if got := p.DecideRouting(context.Background(), env, nil); got != (RoutingDecision{}) {
+		t.Errorf("got %+v, want no handler", got)
+	}
+}
+
+func TestCanaryPolicy_HashPointerValues(t *testing.T) {
+	t.Parallel()
+	s := &canarySplit{pointer: []string{"order", "ids", "0"}}
+	for payload, want := range map[string]string{
+		`{"order":{"ids":["o-1"]}}`:      "o-1",
+		`{"order":{"ids":[ {"n": 1} ]}}`: `{"n":1}`,
+		`{"order":{"ids":[42]}}`:         "42",
+		`{"order":{"ids":[null]}}`:       "m-1",
+		`{"order":{"ids":[]}}`:           "m-1",
+		`{"order":"o-1"}`:                "m-1",
+	} {
+		if got := s.entity(canaryEnvelope("m-1", payload)); got != want {
+			t.Errorf("%s: entity = %q, want %q", payload, got, want)
+		}
+	}
+}
+
+func TestCanaryPolicy_SetValidation(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name  string
+		split CanarySplit
+	}{
+		{"no variants", CanarySplit{}},
+		{"zero total", CanarySplit{Variants: []CanaryVariant{{Name: "a"}}}},
+		{"negative", CanarySplit{Variants: []CanaryVariant{{Name: "a", Weight: 2}, {Name: "b", Weight: -1}}}},
+		{"duplicate", CanarySplit{Variants: []CanaryVariant{{Name: "a", Weight: 1}, {Name: "a", Weight: 1}}}},
+		{"bad pointer", CanarySplit{Variants: []CanaryVariant{{Name: "a", Weight: 1}}, HashPointer: "orderId"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if err := NewCanaryPolicy().Set("T", "v1", tc.split); !errors.Is(err, ErrInvalidCanarySplit) {
+				t.Fatalf("err = %v, want ErrInvalidCanarySplit", err)
+			}
+		})
+	}
+}
+
+func TestCanaryPolicy_Router(t *testing.T) {
+	p := NewCanaryPolicy()
+	r, err := NewRouter(EnvelopeSchema, WithRoutingPolicy(p))
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+	var handled string
+	r.Register("orderPlaced", "2.0", func(context.Context, []byte, []byte) HandlerResult {
+		handled = "legacy"
+		return HandlerResult{ShouldDelete: true}
+	})
+	r.Register("orderPlaced", Variant("2.0", "rewrite"), func(context.Context, []byte, []byte) HandlerResult {
+		handled = "rewrite"
+		return HandlerResult{ShouldDelete: true}
+	})
+	raw := []byte(`{"schemaVersion":"1.0","messageType":"orderPlaced","messageVersion":"2.0","message":{},"metadata":{"messageId":"m-1"}}`)
+
+	// Without a split the plain handler is used.
+	if rr := r.Route(context.Background(), raw); handled != "legacy" || rr.Variant != "" {
+		t.Fatalf("handled = %q, variant = %q", handled, rr.Variant)
+	}
+
+	// Weights are adjustable at runtime.
+	if err := p.Set("orderPlaced", "2.0", CanarySplit{Variants: []CanaryVariant{{Name: "rewrite", Weight: 1}, {Name: "", Weight: 0}}}); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	rr := r.Route(context.Background(), raw)
+	if handled != "rewrite" || rr.Variant != "rewrite" || rr.FailureKind != FailNone {
+		t.Fatalf("handled = %q, result = %+v", handled, rr)
+	}
+	if rr.MessageVersion != "2.0" {
+		t.Errorf("MessageVersion = %q, want the message's own version", rr.MessageVersion)
+	}
+
+	p.Remove("orderPlaced", "2.0")
+	if rr := r.Route(context.Background(), raw); handled != "legacy" || rr.Variant != "" {
+		t.Fatalf("after Remove: handled = %q, variant = %q", handled, rr.Variant)
+	}
+}
+
+func TestCanaryPolicy_VariantUsesBaseSchema(t *testing.T) {
+	p := NewCanaryPolicy()
+	r, err := NewRouter(EnvelopeSchema, WithRoutingPolicy(p))
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+	handled := false
+	r.Register("orderPlaced", "2.0", func(context.Context, []byte, []byte) HandlerResult {
+		return HandlerResult{ShouldDelete: true}
+	})
+	r.Register("orderPlaced", Variant("2.0", "rewrite"), func(context.Context, []byte, []byte) HandlerResult {
+		handled = true
+		return HandlerResult{ShouldDelete: true}
+	})
+	if err := r.RegisterSchema("orderPlaced", "2.0", `{"type":"object","required":["orderId"]}`); err != nil {
+		t.Fatalf("register schema: %v", err)
+	}
+	if err := p.Set("orderPlaced", "2.0", CanarySplit{Variants: []CanaryVariant{{Name: "rewrite", Weight: 1}}}); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+
+	raw := []byte(`{"schemaVersion":"1.0","messageType":"orderPlaced","messageVersion":"2.0","message":{},"metadata":{"messageId":"m-1"}}`)
+	rr := r.Route(context.Background(), raw)
+	if rr.FailureKind != FailPayloadSchema || handled {
+		t.Fatalf("FailureKind = %v, handled %v; want the canary slice validated like the baseline", rr.FailureKind, handled)
+	}
+}
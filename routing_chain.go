@@ -0,0 +1,56 @@
+package sqsrouter
+
+import "context"
+
+// DefaultHandlerKey is the handler key of the handler registered with Router.RegisterDefault.
+// It cannot collide with a type:version key.
+const DefaultHandlerKey HandlerKey = "default"
+
+// FallbackDefaultHandler is reported in RoutingDecision.Fallback when a message went to the default handler.
+const FallbackDefaultHandler = "default_handler"
+
+// ChainPolicy consults routing policies in order and uses the first non-empty HandlerKey, e.g.
+//
+//	sqsrouter.ChainPolicy{sqsrouter.ExactMatchPolicy{}, sqsrouter.WildcardPolicy{}, sqsrouter.SemverPolicy{}}
+//
+// Decisions of policies implementing RoutingDecider are passed through, so fallbacks and variants are still
+// reported; when no policy selects a handler, the first explanation (RoutingDecision.Err) is kept.
+// Registered keys must be accepted by every policy implementing HandlerKeyValidator.
+type ChainPolicy []RoutingPolicy
+
+// Decide returns the first non-empty key; otherwise empty.
+func (c ChainPolicy) Decide(ctx context.Context, envelope *MessageEnvelope, available []HandlerKey) HandlerKey { //nolint:revive
+	return c.DecideRouting(ctx, envelope, available).Key
+}
+
+// DecideRouting returns the decision of the first policy that selects a handler.
+func (c ChainPolicy) DecideRouting(ctx context.Context, envelope *MessageEnvelope, available []HandlerKey) RoutingDecision { //nolint:revive
+	var none RoutingDecision
+	for _, p := range c {
+		var d RoutingDecision
+		if decider, ok := p.(RoutingDecider); ok {
+			d = decider.DecideRouting(ctx, envelope, available)
+		} else {
+			d = RoutingDecision{Key: p.Decide(ctx, envelope, available)}
+		}
+		if d.Key != "" {
+			return d
+		}
+		if none.Err == nil {
+			none.Err = d.Err
+		}
+	}
+	return none
+}
+
+// ValidateKey returns the first error of the chained policies that validate keys.
+func (c ChainPolicy) ValidateKey(key HandlerKey, registered []HandlerKey) error {
+	for _, p := range c {
+		if v, ok := p.(HandlerKeyValidator); ok {
+			if err := v.ValidateKey(key, registered); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package sqsrouter
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// keyPolicy always returns the same key.
+type keyPolicy HandlerKey
+
+func (p keyPolicy) Decide(context.Context, *MessageEnvelope, []HandlerKey) HandlerKey { //nolint:revive
+	return HandlerKey(p)
+}
+
+func TestChainPolicy_Table(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	content := NewContentPolicy().When("T", "1.5", "x", Exists("/missing"))
+	keys := []HandlerKey{"T:1.2", "T:1.5@x", "a.*:v1"}
+
+	cases := []struct {
+		name  string
+		chain ChainPolicy
+		env   MessageEnvelope
+		want  RoutingDecision
+	}{
+		{
+			name:  "first non-empty wins",
+			chain: ChainPolicy{keyPolicy(""), keyPolicy("first"), keyPolicy("second")},
+			env:   MessageEnvelope{MessageType: "T", MessageVersion: "1.2"},
+			want:  RoutingDecision{Key: "first"},
+		},
+		{
+			name:  "exact before semver",
+			chain: ChainPolicy{ExactMatchPolicy{}, SemverPolicy{}},
+			env:   MessageEnvelope{MessageType: "T", MessageVersion: "1.2"},
+			want:  RoutingDecision{Key: "T:1.2"},
+		},
+		{
+			name:  "decisions pass through",
+			chain: ChainPolicy{ExactMatchPolicy{}, WildcardPolicy{}, SemverPolicy{}},
+			env:   MessageEnvelope{MessageType: "T", MessageVersion: "1.4"},
+			want:  RoutingDecision{Key: "T:1.2", Fallback: SemverFallbackOlder},
+		},
+		{
+			name:  "first explanation kept",
+			chain: ChainPolicy{content, WildcardPolicy{}},
+			env:   MessageEnvelope{MessageType: "T", MessageVersion: "1.5", Message: []byte(`{}`)},
+			want:  RoutingDecision{Err: ErrNoPredicateMatched},
+		},
+		{
+			name:  "empty chain",
+			chain: ChainPolicy{},
+			env:   MessageEnvelope{MessageType: "T", MessageVersion: "1.2"},
+			want:  RoutingDecision{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got := tc.chain.DecideRouting(ctx, &tc.env, keys)
+			if got.Key != tc.want.Key || got.Fallback != tc.want.Fallback || (got.Err == nil) != (tc.want.Err == nil) {
+				t.Fatalf("want %+v, got %+v", tc.want, got)
+			}
+			if tc.want.Err != nil && !errors.Is(got.Err, ErrNoPredicateMatched) {
+				t.Fatalf("err = %v, want ErrNoPredicateMatched", got.Err)
+			}
+			if key := tc.chain.Decide(ctx, &tc.env, keys); key != tc.want.Key {
+				t.Fatalf("Decide = %q, want %q", key, tc.want.Key)
+			}
+		})
+	}
+}
+
+func TestChainPolicy_ValidateKey(t *testing.T) {
+	t.Parallel()
+	chain := ChainPolicy{ExactMatchPolicy{}, WildcardPolicy{}}
+	if err := chain.ValidateKey("a.*:v1", []HandlerKey{"a.#:v1"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := chain.ValidateKey("*.b:v1", []HandlerKey{"a.*:v1"}); !errors.Is(err, ErrAmbiguousHandlerKey) {
+		t.Fatalf("err = %v, want ErrAmbiguousHandlerKey", err)
+	}
+}
+
+func TestRouter_RegisterDefault(t *testing.T) {
+	r, err := NewRouter(EnvelopeSchema, WithRoutingPolicy(ChainPolicy{ExactMatchPolicy{}, SemverPolicy{}}))
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+	var handled string
+	r.Register("Known", "1.0", func(context.Context, []byte, []byte) HandlerResult {
+		handled = "known"
+		return HandlerResult{ShouldDelete: true}
+	})
+	raw := func(msgType, version string) []byte {
+		return []byte(`{"schemaVersion":"1.0","messageType":"` + msgType + `","messageVersion":"` + version + `","message":{"a":1},"metadata":{"messageId":"m-1"}}`)
+	}
+
+	if rr := r.Route(context.Background(), raw("Unknown", "1.0")); rr.FailureKind != FailNoHandler {
+		t.Fatalf("without a default handler: FailureKind = %v, want FailNoHandler", rr.FailureKind)
+	}
+
+	var gotMsg, gotMeta string
+	r.RegisterDefault(func(_ context.Context, msg []byte, meta []byte) HandlerResult {
+		handled = "default"
+		gotMsg, gotMeta = string(msg), string(meta)
+		return HandlerResult{ShouldDelete: false}
+	})
+	var decision RoutingDecision
+	var stateKey string
+	r.Use(func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, s *RouteState) (RoutedResult, error) {
+			rr, err := next(ctx, s)
+			decision, stateKey = s.RoutingDecision, s.HandlerKey
+			return rr, err
+		}
+	})
+
+	rr := r.Route(context.Background(), raw("Unknown", "1.0"))
+	if rr.FailureKind != FailNone || handled != "default" || rr.HandlerResult.ShouldDelete {
+		t.Fatalf("default handler not used: handled=%q, %+v", handled, rr)
+	}
+	if gotMsg != `{"a":1}` || gotMeta != `{"timestamp":"","source":"","messageId":"m-1"}` {
+		t.Errorf("default handler got %s / %s", gotMsg, gotMeta)
+	}
+	if rr.MessageType != "Unknown" || stateKey != string(DefaultHandlerKey) {
+		t.Errorf("MessageType = %q, HandlerKey = %q", rr.MessageType, stateKey)
+	}
+	if decision != (RoutingDecision{Key: DefaultHandlerKey, Fallback: FallbackDefaultHandler}) {
+		t.Errorf("RoutingDecision = %+v", decision)
+	}
+
+	// Chained policies still take precedence over the default handler.
+	if rr := r.Route(context.Background(), raw("Known", "1.3")); handled != "known" || rr.FailureKind != FailNone {
+		t.Fatalf("semver fallback should win over the default handler: handled=%q", handled)
+	}
+
+	r.RegisterDefault(nil)
+	if rr := r.Route(context.Background(), raw("Unknown", "1.0")); rr.FailureKind != FailNoHandler {
+		t.Fatalf("after removing the default handler: FailureKind = %v", rr.FailureKind)
+	}
+}
@@ -0,0 +1,307 @@
+package sqsrouter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentFallbackVariant is reported by ContentPolicy in RoutingDecision.Fallback when a predicate selected a variant handler.
+const ContentFallbackVariant = "content_variant"
+
+// variantSeparator separates the message version from the variant name in a variant handler key.
+const variantSeparator = "@"
+
+// Variant returns the version under which the handler for a ContentPolicy variant is registered,
+// e.g. router.Register("Order", sqsrouter.Variant("v1", "eu"), euHandler) registers "Order:v1@eu".
+func Variant(messageVersion, variant string) string {
+	return messageVersion + variantSeparator + variant
+}
+
+// ContentPolicy routes messages of one type and version to handler variants chosen by predicates over
+// the decoded payload and metadata.
+//
+// Rules added with When are evaluated in order and the first matching rule selects the handler registered
+// under Variant(messageVersion, variant). Rules whose variant has no registered handler are skipped, so
+// a rule can be added before its handler is deployed. When no rule matches, the handler registered for
+// the plain type:version is used; without one the message fails as FailNoHandler with ErrNoPredicateMatched.
+// A variant's payload is validated against the schema registered for its own key, or else against the
+// schema registered for the plain type:version.
+// Messages of a type and version without rules are routed by exact match.
+//
+// The payload is decoded once per message, and only for messages that have rules.
+// A ContentPolicy is safe for concurrent use; rules may be added while messages are routed.
+type ContentPolicy struct {
+	mu    sync.RWMutex
+	rules map[string][]contentRule
+}
+
+type contentRule struct {
+	variant string
+	pred    Predicate
+}
+
+// NewContentPolicy returns a ContentPolicy without rules.
+func NewContentPolicy() *ContentPolicy {
+	return &ContentPolicy{rules: make(map[string][]contentRule)}
+}
+
+// When adds a rule routing messages of the given type and version that match pred to variant.
+// It returns p so rules can be chained.
+func (p *ContentPolicy) When(messageType, messageVersion, variant string, pred Predicate) *ContentPolicy {
+	key := makeKey(messageType, messageVersion)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rules[key] = append(p.rules[key], contentRule{variant: variant, pred: pred})
+	return p
+}
+
+// Decide returns the key of the selected handler; otherwise empty.
+func (p *ContentPolicy) Decide(ctx context.Context, envelope *MessageEnvelope, available []HandlerKey) HandlerKey { //nolint:revive
+	return p.DecideRouting(ctx, envelope, available).Key
+}
+
+// DecideRouting returns the selected handler, or ErrNoPredicateMatched when the message has rules but
+// neither a rule nor a default handler applies.
+func (p *ContentPolicy) DecideRouting(ctx context.Context, envelope *MessageEnvelope, available []HandlerKey) RoutingDecision { //nolint:revive
+	key := makeKey(envelope.MessageType, envelope.MessageVersion)
+	p.mu.RLock()
+	rules := p.rules[key]
+	p.mu.RUnlock()
+
+	exact := ExactMatchPolicy{}.Decide(ctx, envelope, available)
+	if len(rules) == 0 {
+		return RoutingDecision{Key: exact}
+	}
+
+	content := &Content{Envelope: envelope}
+	for _, rule := range rules {
+		variantKey := HandlerKey(makeKey(envelope.MessageType, Variant(envelope.MessageVersion, rule.variant)))
+		if !slices.Contains(available, variantKey) {
+			continue
+		}
+		if rule.pred(content) {
+			return RoutingDecision{
+				Key:      variantKey,
+				Fallback: ContentFallbackVariant,
+				Variant:  rule.variant,
+			}
+		}
+	}
+	if exact != "" {
+		return RoutingDecision{Key: exact}
+	}
+	return RoutingDecision{Err: fmt.Errorf("%w for %s", ErrNoPredicateMatched, key)}
+}
+
+// Predicate selects messages for a ContentPolicy rule.
+// Build predicates with Equals, In, Between, Exists, All, Any and OnMetadata, or write custom ones.
+type Predicate func(c *Content) bool
+
+// Content gives predicates access to a message's payload and metadata as JSON documents decoded
+// with encoding/json into any: objects are map[string]any and numbers are float64.
+// Documents are decoded once per message, on first use.
+type Content struct {
+	Envelope *MessageEnvelope
+
+	payload, metadata lazyDocument
+	// onMetadata makes the built-in predicates resolve their pointers against the metadata.
+	onMetadata bool
+}
+
+// Payload returns the decoded payload; ok is false when the payload is not valid JSON.
+func (c *Content) Payload() (doc any, ok bool) {
+	return c.payload.get(func() ([]byte, error) { return c.Envelope.Message, nil })
+}
+
+// Metadata returns the envelope metadata as a JSON document, with extensions under "extensions".
+func (c *Content) Metadata() (doc any, ok bool) {
+	return c.metadata.get(func() ([]byte, error) { return marshalMetadata(c.Envelope.Metadata) })
+}
+
+// document returns the document the built-in predicates evaluate.
+func (c *Content) document() (any, bool) {
+	if c.onMetadata {
+		return c.Metadata()
+	}
+	return c.Payload()
+}
+
+type lazyDocument struct {
+	done bool
+	ok   bool
+	doc  any
+}
+
+func (d *lazyDocument) get(raw func() ([]byte, error)) (any, bool) {
+	if !d.done {
+		d.done = true
+		if b, err := raw(); err == nil {
+			d.ok = json.Unmarshal(b, &d.doc) == nil
+		}
+	}
+	return d.doc, d.ok
+}
+
+// Equals matches when the value at the JSON pointer equals value, compared as JSON: value is converted
+// as if it were encoded with encoding/json, so Equals("/tier", 3) matches {"tier":3.0}.
+// It panics if pointer is not a valid JSON pointer or value cannot be encoded.
+func Equals(pointer string, value any) Predicate {
+	want := jsonValue(value)
+	return pointerPredicate(pointer, func(v any) bool { return reflect.DeepEqual(v, want) })
+}
+
+// In matches when the value at the JSON pointer equals one of values; see Equals.
+func In(pointer string, values ...any) Predicate {
+	wants := make([]any, len(values))
+	for i, v := range values {
+		wants[i] = jsonValue(v)
+	}
+	return pointerPredicate(pointer, func(v any) bool {
+		for _, want := range wants {
+			if reflect.DeepEqual(v, want) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Between matches when the value at the JSON pointer is a number within [lo, hi].
+// It panics if pointer is not a valid JSON pointer.
+func Between(pointer string, lo, hi float64) Predicate {
+	return pointerPredicate(pointer, func(v any) bool {
+		n, ok := v.(float64)
+		return ok && n >= lo && n <= hi
+	})
+}
+
+// Exists matches when the JSON pointer resolves, including to null.
+// It panics if pointer is not a valid JSON pointer.
+func Exists(pointer string) Predicate {
+	return pointerPredicate(pointer, func(any) bool { return true })
+}
+
+// All matches when every predicate matches.
+func All(preds ...Predicate) Predicate {
+	return func(c *Content) bool {
+		for _, pred := range preds {
+			if !pred(c) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Any matches when at least one predicate matches.
+func Any(preds ...Predicate) Predicate {
+	return func(c *Content) bool {
+		for _, pred := range preds {
+			if pred(c) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// OnMetadata makes the built-in predicates in pred resolve their pointers against the envelope metadata
+// instead of the payload, e.g. OnMetadata(Equals("/source", "billing")).
+func OnMetadata(pred Predicate) Predicate {
+	return func(c *Content) bool {
+		prev := c.onMetadata
+		c.onMetadata = true
+		defer func() { c.onMetadata = prev }()
+		return pred(c)
+	}
+}
+
+// pointerPredicate returns a predicate applying test to the value at pointer; it does not match when
+// the document is invalid or the pointer does not resolve.
+func pointerPredicate(pointer string, test func(v any) bool) Predicate {
+	tokens, err := parseJSONPointer(pointer)
+	if err != nil {
+		panic(err)
+	}
+	return func(c *Content) bool {
+		doc, ok := c.document()
+		if !ok {
+			return false
+		}
+		v, ok := resolveJSONPointer(doc, tokens)
+		return ok && test(v)
+	}
+}
+
+// jsonValue converts v to the form encoding/json decodes it into when decoding into any.
+func jsonValue(v any) any {
+	var out any
+	b, err := json.Marshal(v)
+	if err == nil {
+		err = json.Unmarshal(b, &out)
+	}
+	if err != nil {
+		panic(fmt.Sprintf("sqsrouter: predicate value %v cannot be encoded as JSON: %v", v, err))
+	}
+	return out
+}
+
+// jsonPointerUnescaper decodes "~1" and "~0" in reference tokens, in that order as RFC 6901 requires.
+var jsonPointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+
+// parseJSONPointer splits an RFC 6901 JSON pointer into unescaped reference tokens.
+func parseJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("sqsrouter: invalid JSON pointer %q: must be empty or start with /", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, tok := range tokens {
+		tokens[i] = jsonPointerUnescaper.Replace(tok)
+	}
+	return tokens, nil
+}
+
+// resolveJSONPointer returns the value tokens refer to in doc.
+func resolveJSONPointer(doc any, tokens []string) (any, bool) {
+	for _, tok := range tokens {
+		switch node := doc.(type) {
+		case map[string]any:
+			v, ok := node[tok]
+			if !ok {
+				return nil, false
+			}
+			doc = v
+		case []any:
+			i, ok := jsonPointerIndex(tok, len(node))
+			if !ok {
+				return nil, false
+			}
+			doc = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return doc, true
+}
+
+// jsonPointerIndex parses a reference token as an index into an array of length n.
+func jsonPointerIndex(tok string, n int) (int, bool) {
+	// Array indexes are unsigned decimals without leading zeros.
+	if tok == "" || tok[0] < '0' || tok[0] > '9' || (len(tok) > 1 && tok[0] == '0') {
+		return 0, false
+	}
+	i, err := strconv.Atoi(tok)
+	if err != nil || i >= n {
+		return 0, false
+	}
+	return i, true
+}
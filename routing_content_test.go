@@ -0,0 +1,208 @@
+package sqsrouter
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestPredicates(t *testing.T) {
+	t.Parallel()
+	env := &MessageEnvelope{
+		Message:  []byte(`{"region":"eu","tier":3,"tags":["a","b"],"user":{"a/b":1,"m~n":2},"nothing":null}`),
+		Metadata: MessageMetadata{Source: "billing", MessageID: "m-1"},
+	}
+
+	cases := []struct {
+		name string
+		pred Predicate
+		want bool
+	}{
+		{"equals string", Equals("/region", "eu"), true},
+		{"equals other", Equals("/region", "us"), false},
+		{"equals int against float", Equals("/tier", 3), true},
+		{"equals whole array", Equals("/tags", []string{"a", "b"}), true},
+		{"equals array element", Equals("/tags/1", "b"), true},
+		{"array index out of range", Exists("/tags/2"), false},
+		{"array index with leading zero", Exists("/tags/01"), false},
+		{"array index with sign", Exists("/tags/+1"), false},
+		{"escaped slash", Equals("/user/a~1b", 1), true},
+		{"escaped tilde", Equals("/user/m~0n", 2), true},
+		{"in", In("/region", "us", "eu"), true},
+		{"not in", In("/region", "us", "ap"), false},
+		{"between inclusive", Between("/tier", 1, 3), true},
+		{"between outside", Between("/tier", 4, 9), false},
+		{"between non-number", Between("/region", 0, 100), false},
+		{"exists", Exists("/user"), true},
+		{"exists null", Exists("/nothing"), true},
+		{"missing", Exists("/missing"), false},
+		{"through scalar", Exists("/region/x"), false},
+		{"whole document", Exists(""), true},
+		{"metadata", OnMetadata(Equals("/source", "billing")), true},
+		{"metadata does not see payload", OnMetadata(Exists("/region")), false},
+		{"all", All(Equals("/region", "eu"), OnMetadata(Exists("/messageId")), Between("/tier", 3, 3)), true},
+		{"all fails", All(Equals("/region", "eu"), Equals("/tier", 4)), false},
+		{"any", Any(Equals("/region", "us"), Equals("/tier", 3)), true},
+		{"any fails", Any(Equals("/region", "us")), false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if got := tc.pred(&Content{Envelope: env}); got != tc.want {
+				t.Fatalf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPredicates_InvalidPayload(t *testing.T) {
+	t.Parallel()
+	c := &Content{Envelope: &MessageEnvelope{Message: []byte(`not json`)}}
+	if Exists("")(c) {
+		t.Fatal("predicates must not match an undecodable payload")
+	}
+	if _, ok := c.Payload(); ok {
+		t.Fatal("Payload should report the decode failure")
+	}
+}
+
+func TestPredicates_InvalidPointerPanics(t *testing.T) {
+	t.Parallel()
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic for a pointer without a leading slash")
+		}
+	}()
+	Equals("region", "eu")
+}
+
+func TestContentPolicy_Routing(t *testing.T) {
+	policy := NewContentPolicy().
+		When("Order", "v1", "eu", In("/region", "eu-west", "eu-central")).
+		When("Order", "v1", "vip", OnMetadata(Equals("/source", "vip-shop"))).
+		When("Order", "v1", "catchall", Exists("/region"))
+	r, err := NewRouter(EnvelopeSchema, WithRoutingPolicy(policy))
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+	var handled string
+	handler := func(name string) MessageHandler {
+		return func(context.Context, []byte, []byte) HandlerResult {
+			handled = name
+			return HandlerResult{ShouldDelete: true}
+		}
+	}
+	r.Register("Order", Variant("v1", "eu"), handler("eu"))
+	r.Register("Order", Variant("v1", "vip"), handler("vip"))
+	r.Register("Order", Variant("v1", "catchall"), handler("catchall"))
+	r.Register("Invoice", "v1", handler("invoice"))
+
+	body := func(msgType, payload, source string) []byte {
+		return []byte(`{"schemaVersion":"1.0","messageType":"` + msgType + `","messageVersion":"v1","message":` + payload +
+			`,"metadata":{"source":"` + source + `"}}`)
+	}
+
+	cases := []struct {
+		name string
+		raw  []byte
+		want string
+	}{
+		{"first rule", body("Order", `{"region":"eu-west"}`, "vip-shop"), "eu"},
+		{"second rule on metadata", body("Order", `{"region":"us"}`, "vip-shop"), "vip"},
+		{"later rule", body("Order", `{"region":"us"}`, "shop"), "catchall"},
+		{"type without rules", body("Invoice", `{}`, "shop"), "invoice"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			handled = ""
+			rr := r.Route(context.Background(), tc.raw)
+			if rr.FailureKind != FailNone || handled != tc.want {
+				t.Fatalf("handled = %q, want %q (%+v)", handled, tc.want, rr)
+			}
+			if rr.MessageVersion != "v1" {
+				t.Errorf("MessageVersion = %q, want the message's own version", rr.MessageVersion)
+			}
+		})
+	}
+
+	t.Run("no predicate matched", func(t *testing.T) {
+		rr := r.Route(context.Background(), body("Order", `{}`, "shop"))
+		if rr.FailureKind != FailNoHandler {
+			t.Fatalf("FailureKind = %v, want FailNoHandler", rr.FailureKind)
+		}
+		if !errors.Is(rr.HandlerResult.Error, ErrNoPredicateMatched) || !errors.Is(rr.HandlerResult.Error, ErrNoHandlerRegistered) {
+			t.Fatalf("error = %v, want ErrNoPredicateMatched and ErrNoHandlerRegistered", rr.HandlerResult.Error)
+		}
+	})
+
+	t.Run("default handler when no predicate matched", func(t *testing.T) {
+		r.Register("Order", "v1", handler("default"))
+		handled = ""
+		rr := r.Route(context.Background(), body("Order", `{}`, "shop"))
+		if rr.FailureKind != FailNone || handled != "default" {
+			t.Fatalf("handled = %q, want default (%+v)", handled, rr)
+		}
+	})
+}
+
+func TestContentPolicy_Decision(t *testing.T) {
+	t.Parallel()
+	policy := NewContentPolicy().When("Order", "v1", "eu", Equals("/region", "eu"))
+	env := &MessageEnvelope{MessageType: "Order", MessageVersion: "v1", Message: []byte(`{"region":"eu"}`)}
+
+	got := policy.DecideRouting(context.Background(), env, []HandlerKey{"Order:v1@eu"})
+	if got != (RoutingDecision{Key: "Order:v1@eu", Fallback: ContentFallbackVariant, Variant: "eu"}) {
+		t.Fatalf("decision = %+v", got)
+	}
+	if key := policy.Decide(context.Background(), env, []HandlerKey{"Order:v1@eu"}); key != "Order:v1@eu" {
+		t.Fatalf("Decide = %q", key)
+	}
+}
+
+func TestContentPolicy_SkipsUnregisteredVariant(t *testing.T) {
+	t.Parallel()
+	policy := NewContentPolicy().
+		When("Order", "v1", "eu", Equals("/region", "eu")).
+		When("Order", "v1", "any", Exists("/region"))
+	env := &MessageEnvelope{MessageType: "Order", MessageVersion: "v1", Message: []byte(`{"region":"eu"}`)}
+
+	got := policy.DecideRouting(context.Background(), env, []HandlerKey{"Order:v1@any"})
+	if got != (RoutingDecision{Key: "Order:v1@any", Fallback: ContentFallbackVariant, Variant: "any"}) {
+		t.Errorf("decision = %+v, want the next rule with a registered variant", got)
+	}
+	got = policy.DecideRouting(context.Background(), env, []HandlerKey{"Order:v1"})
+	if got != (RoutingDecision{Key: "Order:v1"}) {
+		t.Errorf("decision = %+v, want the plain handler", got)
+	}
+}
+
+func TestContentPolicy_VariantUsesBaseSchema(t *testing.T) {
+	policy := NewContentPolicy().When("Order", "v1", "eu", Equals("/region", "eu"))
+	r, err := NewRouter(EnvelopeSchema, WithRoutingPolicy(policy))
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+	handled := false
+	r.Register("Order", Variant("v1", "eu"), func(context.Context, []byte, []byte) HandlerResult {
+		handled = true
+		return HandlerResult{ShouldDelete: true}
+	})
+	if err := r.RegisterSchema("Order", "v1", `{"type":"object","required":["id"]}`); err != nil {
+		t.Fatalf("register schema: %v", err)
+	}
+
+	raw := []byte(`{"schemaVersion":"1.0","messageType":"Order","messageVersion":"v1","message":{"region":"eu"},"metadata":{}}`)
+	rr := r.Route(context.Background(), raw)
+	if rr.FailureKind != FailPayloadSchema || handled {
+		t.Fatalf("FailureKind = %v, handled %v; want FailPayloadSchema before the variant handler", rr.FailureKind, handled)
+	}
+
+	// A schema registered for the variant itself takes precedence.
+	if err := r.RegisterSchema("Order", Variant("v1", "eu"), `{"type":"object"}`); err != nil {
+		t.Fatalf("register schema: %v", err)
+	}
+	if rr := r.Route(context.Background(), raw); rr.FailureKind != FailNone || !handled {
+		t.Fatalf("FailureKind = %v, handled %v; want the variant schema to apply", rr.FailureKind, handled)
+	}
+}
@@ -0,0 +1,125 @@
+package sqsrouter
+
+import (
+	"cmp"
+	"context"
+	"strconv"
+	"strings"
+)
+
+// Fallbacks reported by SemverPolicy in RoutingDecision.Fallback.
+const (
+	// SemverFallbackEquivalent selects a handler registered under another spelling of the same version, e.g. "1.1.0" for "v1.1".
+	SemverFallbackEquivalent = "semver_equivalent"
+	// SemverFallbackOlder selects the highest compatible handler older than the message.
+	SemverFallbackOlder = "semver_older"
+	// SemverFallbackNewer selects the lowest compatible handler newer than the message (AllowNewer).
+	SemverFallbackNewer = "semver_newer"
+)
+
+// SemverPolicy selects handlers by semantic version.
+// An exact type:version match always wins. Otherwise, when messageVersion parses as MAJOR[.MINOR[.PATCH]]
+// with an optional "v" prefix, the handler with the highest registered version of the same major version
+// that is not newer than the message is selected, so a producer may roll out a minor version before its
+// consumers. Handler versions that do not parse are only matched exactly.
+// The zero value is ready to use; it implements RoutingDecider to report the fallback applied.
+type SemverPolicy struct {
+	// SameMinor restricts fallback to handlers of the same major and minor version.
+	SameMinor bool
+	// AllowNewer selects the lowest compatible handler newer than the message when no older one is registered.
+	AllowNewer bool
+}
+
+// Decide returns the key of the selected handler; otherwise empty.
+func (p SemverPolicy) Decide(ctx context.Context, envelope *MessageEnvelope, available []HandlerKey) HandlerKey { //nolint:revive
+	return p.DecideRouting(ctx, envelope, available).Key
+}
+
+// DecideRouting returns the selected handler and the fallback that selected it.
+func (p SemverPolicy) DecideRouting(_ context.Context, envelope *MessageEnvelope, available []HandlerKey) RoutingDecision { //nolint:revive
+	want := HandlerKey(makeKey(envelope.MessageType, envelope.MessageVersion))
+	for _, k := range available {
+		if k == want {
+			return RoutingDecision{Key: k}
+		}
+	}
+
+	msgVer, ok := parseSemver(envelope.MessageVersion)
+	if !ok {
+		return RoutingDecision{}
+	}
+	prefix := envelope.MessageType + ":"
+
+	var older, newer HandlerKey
+	var olderVer, newerVer semver
+	for _, k := range available {
+		rest, found := strings.CutPrefix(string(k), prefix)
+		if !found {
+			continue
+		}
+		v, ok := parseSemver(rest)
+		if !ok || v.major != msgVer.major || (p.SameMinor && v.minor != msgVer.minor) {
+			continue
+		}
+		if v.compare(msgVer) <= 0 {
+			if older == "" || v.compare(olderVer) > 0 {
+				older, olderVer = k, v
+			}
+		} else if newer == "" || v.compare(newerVer) < 0 {
+			newer, newerVer = k, v
+		}
+	}
+
+	switch {
+	case older != "" && olderVer == msgVer:
+		return RoutingDecision{Key: older, Fallback: SemverFallbackEquivalent}
+	case older != "":
+		return RoutingDecision{Key: older, Fallback: SemverFallbackOlder}
+	case newer != "" && p.AllowNewer:
+		return RoutingDecision{Key: newer, Fallback: SemverFallbackNewer}
+	default:
+		return RoutingDecision{}
+	}
+}
+
+// semver is a parsed MAJOR.MINOR.PATCH version; missing components are zero.
+type semver struct {
+	major, minor, patch int
+}
+
+// parseSemver parses MAJOR[.MINOR[.PATCH]] with an optional "v" or "V" prefix.
+// Pre-release and build suffixes are not supported.
+func parseSemver(s string) (semver, bool) {
+	if s != "" && (s[0] == 'v' || s[0] == 'V') {
+		s = s[1:]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 { //nolint:mnd // MAJOR.MINOR.PATCH
+		return semver{}, false
+	}
+	var nums [3]int
+	for i, part := range parts {
+		// Reject empty parts and signs, which Atoi would accept.
+		if part == "" || part[0] < '0' || part[0] > '9' {
+			return semver{}, false
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return semver{}, false
+		}
+		nums[i] = n
+	}
+	return semver{major: nums[0], minor: nums[1], patch: nums[2]}, true
+}
+
+// compare returns -1, 0 or +1 depending on whether v is older than, equal to or newer than o.
+func (v semver) compare(o semver) int {
+	switch {
+	case v.major != o.major:
+		return cmp.Compare(v.major, o.major)
+	case v.minor != o.minor:
+		return cmp.Compare(v.minor, o.minor)
+	default:
+		return cmp.Compare(v.patch, o.patch)
+	}
+}
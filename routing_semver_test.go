@@ -0,0 +1,147 @@
+package sqsrouter
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSemverPolicy_Table(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	cases := []struct {
+		name   string
+		policy SemverPolicy
+		ver    string
+		keys   []HandlerKey
+		want   RoutingDecision
+	}{
+		{
+			name: "exact match wins",
+			ver:  "1.1",
+			keys: []HandlerKey{"A:1.0", "A:1.1", "A:1.1.0"},
+			want: RoutingDecision{Key: "A:1.1"},
+		},
+		{
+			name: "falls back to highest older minor",
+			ver:  "1.3",
+			keys: []HandlerKey{"A:1.0", "A:1.2", "A:1.4", "A:2.0", "B:1.2"},
+			want: RoutingDecision{Key: "A:1.2", Fallback: SemverFallbackOlder},
+		},
+		{
+			name: "compares numerically",
+			ver:  "v1.10",
+			keys: []HandlerKey{"A:v1.2", "A:v1.9"},
+			want: RoutingDecision{Key: "A:v1.9", Fallback: SemverFallbackOlder},
+		},
+		{
+			name: "equivalent spelling",
+			ver:  "v1.1",
+			keys: []HandlerKey{"A:1.0", "A:1.1.0"},
+			want: RoutingDecision{Key: "A:1.1.0", Fallback: SemverFallbackEquivalent},
+		},
+		{
+			name: "never crosses major versions",
+			ver:  "2.0",
+			keys: []HandlerKey{"A:1.9"},
+			want: RoutingDecision{},
+		},
+		{
+			name: "newer handlers ignored by default",
+			ver:  "1.0",
+			keys: []HandlerKey{"A:1.1"},
+			want: RoutingDecision{},
+		},
+		{
+			name:   "AllowNewer picks lowest newer",
+			policy: SemverPolicy{AllowNewer: true},
+			ver:    "1.0",
+			keys:   []HandlerKey{"A:1.3", "A:1.1", "A:2.0"},
+			want:   RoutingDecision{Key: "A:1.1", Fallback: SemverFallbackNewer},
+		},
+		{
+			name:   "AllowNewer still prefers older",
+			policy: SemverPolicy{AllowNewer: true},
+			ver:    "1.2",
+			keys:   []HandlerKey{"A:1.1", "A:1.3"},
+			want:   RoutingDecision{Key: "A:1.1", Fallback: SemverFallbackOlder},
+		},
+		{
+			name:   "SameMinor limits to patch fallback",
+			policy: SemverPolicy{SameMinor: true},
+			ver:    "1.2.5",
+			keys:   []HandlerKey{"A:1.1.9", "A:1.2.3"},
+			want:   RoutingDecision{Key: "A:1.2.3", Fallback: SemverFallbackOlder},
+		},
+		{
+			name:   "SameMinor rejects other minors",
+			policy: SemverPolicy{SameMinor: true},
+			ver:    "1.2",
+			keys:   []HandlerKey{"A:1.1"},
+			want:   RoutingDecision{},
+		},
+		{
+			name: "unparseable message version only matches exactly",
+			ver:  "beta",
+			keys: []HandlerKey{"A:1.0"},
+			want: RoutingDecision{},
+		},
+		{
+			name: "unparseable handler versions skipped",
+			ver:  "1.5",
+			keys: []HandlerKey{"A:1.x", "A:+1.2", "A:1..2", "A:1.2.3.4", "A:1.1"},
+			want: RoutingDecision{Key: "A:1.1", Fallback: SemverFallbackOlder},
+		},
+		{
+			name: "type prefix must match whole type",
+			ver:  "1.5",
+			keys: []HandlerKey{"AB:1.0"},
+			want: RoutingDecision{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			env := MessageEnvelope{MessageType: "A", MessageVersion: tc.ver}
+			got := tc.policy.DecideRouting(ctx, &env, tc.keys)
+			if got != tc.want {
+				t.Fatalf("want %+v, got %+v", tc.want, got)
+			}
+			if key := tc.policy.Decide(ctx, &env, tc.keys); key != tc.want.Key {
+				t.Fatalf("Decide = %q, want %q", key, tc.want.Key)
+			}
+		})
+	}
+}
+
+func TestSemverPolicy_ReportsDecisionOnRouteState(t *testing.T) {
+	r, err := NewRouter(EnvelopeSchema, WithRoutingPolicy(SemverPolicy{}))
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+	r.Register("T", "1.0", func(context.Context, []byte, []byte) HandlerResult {
+		return HandlerResult{ShouldDelete: true}
+	})
+	var decision RoutingDecision
+	r.Use(func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, s *RouteState) (RoutedResult, error) {
+			rr, err := next(ctx, s)
+			decision = s.RoutingDecision
+			return rr, err
+		}
+	})
+
+	raw := []byte(`{"schemaVersion":"1.0","messageType":"T","messageVersion":"1.1","message":{},"metadata":{}}`)
+	rr := r.Route(context.Background(), raw)
+
+	if rr.FailureKind != FailNone || !rr.HandlerResult.ShouldDelete {
+		t.Fatalf("message was not handled: %+v", rr)
+	}
+	if rr.MessageVersion != "1.1" {
+		t.Errorf("MessageVersion = %q, want the message's own version", rr.MessageVersion)
+	}
+	if decision != (RoutingDecision{Key: "T:1.0", Fallback: SemverFallbackOlder}) {
+		t.Errorf("RoutingDecision = %+v", decision)
+	}
+}
@@ -0,0 +1,108 @@
+package sqsrouter
+
+import (
+	"context"
+	"maps"
+	"slices"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// routingTable is an immutable snapshot of everything registered on a Router.
+// Register, RegisterSchema and Use build a new table and publish it atomically, so Route reads
+// handlers, schemas, the key list and the composed middleware chain without locking or allocating.
+// A published table must never be modified.
+type routingTable struct {
+	handlers map[string]handlerEntry
+	schemas  map[string]*gojsonschema.Schema
+	// generated holds the keys whose schema was generated by RegisterTyped rather than registered with RegisterSchema.
+	generated   map[string]bool
+	middlewares []Middleware
+	// keys lists the handler keys in sorted order. It is passed to RoutingPolicy.Decide as is.
+	keys []HandlerKey
+	// chain is the middleware chain composed around coreRoute for this table.
+	chain HandlerFunc
+	// defaultHandler receives messages without a handler; nil when none is registered.
+	defaultHandler *handlerEntry
+	// upcasters maps the type:version key an upcaster converts from to the step.
+	upcasters map[string]upcastStep
+}
+
+// handlerEntry is a registered handler.
+type handlerEntry struct {
+	// handler is exposed as RouteState.Handler. For raw handlers it is also what Route invokes.
+	handler MessageHandler
+	// bind is set for typed handlers. It decodes the payload once and returns the call Route invokes;
+	// a decode error is reported as FailPayloadDecode without calling the handler.
+	bind func(env *MessageEnvelope) (func(ctx context.Context) HandlerResult, error)
+	// fanOut is set for handlers registered with RegisterFanOut or AddFanOutHandler.
+	fanOut *fanOut
+}
+
+// newRoutingTable returns an empty table with its chain composed.
+func (r *Router) newRoutingTable() *routingTable {
+	t := &routingTable{
+		handlers:  make(map[string]handlerEntry),
+		schemas:   make(map[string]*gojsonschema.Schema),
+		generated: make(map[string]bool),
+		upcasters: make(map[string]upcastStep),
+	}
+	r.compile(t)
+	return t
+}
+
+// updateTable applies fn to a copy of the current table and publishes the result.
+// If fn returns an error the copy is discarded and the current table stays in place.
+// Writers are serialized by r.mu; readers keep using the table they loaded.
+func (r *Router) updateTable(fn func(t *routingTable) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	next := r.table.Load().clone()
+	if err := fn(next); err != nil {
+		return err
+	}
+	r.compile(next)
+	r.table.Store(next)
+	return nil
+}
+
+// changeTable is like updateTable for changes that cannot fail.
+func (r *Router) changeTable(fn func(t *routingTable)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	next := r.table.Load().clone()
+	fn(next)
+	r.compile(next)
+	r.table.Store(next)
+}
+
+// clone returns a copy of t whose maps and middleware list can be modified without affecting t.
+// The derived fields are left for compile.
+func (t *routingTable) clone() *routingTable {
+	return &routingTable{
+		handlers:       maps.Clone(t.handlers),
+		schemas:        maps.Clone(t.schemas),
+		generated:      maps.Clone(t.generated),
+		middlewares:    slices.Clip(t.middlewares),
+		defaultHandler: t.defaultHandler,
+		upcasters:      maps.Clone(t.upcasters),
+	}
+}
+
+// compile derives the sorted key list and the middleware chain of t.
+// Middlewares are applied in reverse registration order, so the first registered middleware is outermost.
+func (r *Router) compile(t *routingTable) {
+	t.keys = make([]HandlerKey, 0, len(t.handlers))
+	for k := range t.handlers {
+		t.keys = append(t.keys, HandlerKey(k))
+	}
+	slices.Sort(t.keys)
+
+	chain := func(ctx context.Context, s *RouteState) (RoutedResult, error) {
+		return r.coreRoute(ctx, t, s)
+	}
+	for i := len(t.middlewares) - 1; i >= 0; i-- {
+		chain = t.middlewares[i](chain)
+	}
+	t.chain = chain
+}
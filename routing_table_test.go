@@ -0,0 +1,131 @@
+package sqsrouter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// recordingPolicy captures the handler keys offered by the router.
+type recordingPolicy struct {
+	ExactMatchPolicy
+	mu   sync.Mutex
+	seen [][]HandlerKey
+}
+
+func (p *recordingPolicy) Decide(ctx context.Context, env *MessageEnvelope, available []HandlerKey) HandlerKey {
+	p.mu.Lock()
+	p.seen = append(p.seen, available)
+	p.mu.Unlock()
+	return p.ExactMatchPolicy.Decide(ctx, env, available)
+}
+
+func TestRoutingTableKeysAreSortedAndShared(t *testing.T) {
+	policy := &recordingPolicy{}
+	router, err := NewRouter(EnvelopeSchema, WithRoutingPolicy(policy), WithLogger(nil))
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+	noop := func(context.Context, []byte, []byte) HandlerResult { return HandlerResult{ShouldDelete: true} }
+	router.Register("b", "1", noop)
+	router.Register("a", "2", noop)
+	router.Register("a", "1", noop)
+
+	raw := []byte(`{"schemaVersion":"1.0","messageType":"a","messageVersion":"1","message":{},"metadata":{}}`)
+	router.Route(context.Background(), raw)
+	router.Route(context.Background(), raw)
+
+	want := []HandlerKey{"a:1", "a:2", "b:1"}
+	if fmt.Sprint(policy.seen[0]) != fmt.Sprint(want) {
+		t.Fatalf("available handlers = %v, want %v", policy.seen[0], want)
+	}
+	if &policy.seen[0][0] != &policy.seen[1][0] {
+		t.Fatal("the key list should be reused between Route calls, not rebuilt")
+	}
+}
+
+func TestRoutingTableSnapshotIsImmutable(t *testing.T) {
+	router, err := NewRouter(EnvelopeSchema)
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+	before := router.table.Load()
+	router.Register("T", "v1", func(context.Context, []byte, []byte) HandlerResult { return HandlerResult{} })
+	router.Use(func(next HandlerFunc) HandlerFunc { return next })
+
+	if len(before.handlers) != 0 || len(before.keys) != 0 || len(before.middlewares) != 0 {
+		t.Fatal("registrations must not modify a published routing table")
+	}
+	after := router.table.Load()
+	if len(after.handlers) != 1 || len(after.middlewares) != 1 {
+		t.Fatalf("new table not published: %d handlers, %d middlewares", len(after.handlers), len(after.middlewares))
+	}
+}
+
+func TestMiddlewareFactoryRunsPerTableRebuild(t *testing.T) {
+	router, err := NewRouter(EnvelopeSchema)
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+	var built, called int
+	router.Use(func(next HandlerFunc) HandlerFunc {
+		built++
+		return func(ctx context.Context, s *RouteState) (RoutedResult, error) {
+			called++
+			return next(ctx, s)
+		}
+	})
+	router.Register("T", "v1", func(context.Context, []byte, []byte) HandlerResult { return HandlerResult{ShouldDelete: true} })
+	if built != 2 {
+		t.Fatalf("factory built %d chains, want one per table rebuild (Use, Register)", built)
+	}
+
+	raw := []byte(`{"schemaVersion":"1.0","messageType":"T","messageVersion":"v1","message":{},"metadata":{}}`)
+	for range 3 {
+		router.Route(context.Background(), raw)
+	}
+	if built != 2 || called != 3 {
+		t.Fatalf("built %d chains and called the chain %d times, want 2 and 3: routing must not call the factory", built, called)
+	}
+}
+
+func TestRouterConcurrentRegisterAndRoute(t *testing.T) {
+	router, err := NewRouter(EnvelopeSchema, WithLogger(nil))
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+	raw := []byte(`{"schemaVersion":"1.0","messageType":"T","messageVersion":"v0","message":{},"metadata":{}}`)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				router.Route(context.Background(), raw)
+			}
+		}()
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				version := fmt.Sprintf("v%d", i*50+j)
+				router.Register("T", version, func(context.Context, []byte, []byte) HandlerResult {
+					return HandlerResult{ShouldDelete: true}
+				})
+				if err := router.RegisterSchema("T", version, `{"type":"object"}`); err != nil {
+					t.Errorf("register schema: %v", err)
+				}
+				router.Use(func(next HandlerFunc) HandlerFunc { return next })
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if got := len(router.table.Load().handlers); got != 200 {
+		t.Fatalf("handlers = %d, want 200", got)
+	}
+	if rr := router.Route(context.Background(), raw); rr.HandlerResult.Error != nil {
+		t.Fatalf("route after registration: %v", rr.HandlerResult.Error)
+	}
+}
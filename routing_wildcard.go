@@ -0,0 +1,182 @@
+package sqsrouter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// WildcardFallbackPattern is reported by WildcardPolicy in RoutingDecision.Fallback when a pattern,
+// not the message's own type:version key, selected the handler.
+const WildcardFallbackPattern = "wildcard_pattern"
+
+// Pattern segments understood by WildcardPolicy.
+const (
+	wildcardOne  = "*"
+	wildcardMany = "#"
+)
+
+// WildcardPolicy routes dot-separated message types to handlers registered under type patterns.
+// A "*" segment matches exactly one segment of the message type and a "#" segment matches zero or more,
+// so a handler registered for "billing.invoice.*" receives "billing.invoice.created" and one registered
+// for "billing.#" receives every billing type. Versions are matched exactly.
+//
+// When several patterns match, the most specific wins: the one with the most fixed segments (literals and
+// "*", which match exactly one segment each), then the most literal segments, then the fewest "#" segments.
+// For "a.b.c", "a.*.#" therefore wins over "a.#". An exact type:version key is always preferred.
+// Patterns of the same version that could match the same message type with equal specificity, such as
+// "billing.*.created" and "billing.invoice.*", are ambiguous and rejected at registration: Register panics,
+// while TryRegister and RegisterTyped return ErrAmbiguousHandlerKey.
+type WildcardPolicy struct{}
+
+// Decide returns the key of the most specific matching handler; otherwise empty.
+func (p WildcardPolicy) Decide(ctx context.Context, envelope *MessageEnvelope, available []HandlerKey) HandlerKey { //nolint:revive
+	return p.DecideRouting(ctx, envelope, available).Key
+}
+
+// DecideRouting returns the most specific matching handler and reports whether a pattern selected it.
+func (WildcardPolicy) DecideRouting(_ context.Context, envelope *MessageEnvelope, available []HandlerKey) RoutingDecision { //nolint:revive
+	want := HandlerKey(makeKey(envelope.MessageType, envelope.MessageVersion))
+	for _, k := range available {
+		if k == want {
+			return RoutingDecision{Key: k}
+		}
+	}
+
+	suffix := ":" + envelope.MessageVersion
+	var typ []string
+	var best HandlerKey
+	var bestRank patternRank
+	for _, k := range available {
+		pattern, found := strings.CutSuffix(string(k), suffix)
+		if !found || !isPattern(pattern) {
+			continue
+		}
+		if typ == nil {
+			typ = strings.Split(envelope.MessageType, ".")
+		}
+		segs := strings.Split(pattern, ".")
+		if !matchPattern(segs, typ) {
+			continue
+		}
+		// Keys are sorted, so ties (only possible for keys registered without validation) resolve deterministically.
+		if rank := rankPattern(segs); best == "" || rank.moreSpecific(bestRank) {
+			best, bestRank = k, rank
+		}
+	}
+	if best == "" {
+		return RoutingDecision{}
+	}
+	return RoutingDecision{Key: best, Fallback: WildcardFallbackPattern}
+}
+
+// ValidateKey rejects malformed patterns and patterns that are ambiguous with a registered one.
+// A wildcard must make up a whole segment: "billing.*" is a pattern, "billing.inv*" is invalid.
+func (WildcardPolicy) ValidateKey(key HandlerKey, registered []HandlerKey) error {
+	pattern, version := splitHandlerKey(key)
+	segs := strings.Split(pattern, ".")
+	for _, seg := range segs {
+		if seg != wildcardOne && seg != wildcardMany && strings.ContainsAny(seg, wildcardOne+wildcardMany) {
+			return fmt.Errorf("%w %s: wildcards must be whole segments", ErrInvalidHandlerKey, key)
+		}
+	}
+	if !isPattern(pattern) {
+		return nil
+	}
+
+	rank := rankPattern(segs)
+	for _, other := range registered {
+		otherPattern, otherVersion := splitHandlerKey(other)
+		if otherVersion != version {
+			continue
+		}
+		otherSegs := strings.Split(otherPattern, ".")
+		if rankPattern(otherSegs) == rank && patternsOverlap(segs, otherSegs) {
+			return fmt.Errorf("%w: %s and %s match the same message types with equal specificity", ErrAmbiguousHandlerKey, key, other)
+		}
+	}
+	return nil
+}
+
+// splitHandlerKey splits a type:version key at its last colon.
+func splitHandlerKey(key HandlerKey) (messageType, messageVersion string) {
+	i := strings.LastIndexByte(string(key), ':')
+	if i < 0 {
+		return string(key), ""
+	}
+	return string(key[:i]), string(key[i+1:])
+}
+
+// isPattern reports whether a message type contains wildcard characters.
+func isPattern(messageType string) bool {
+	return strings.ContainsAny(messageType, wildcardOne+wildcardMany)
+}
+
+// patternRank is the specificity of a pattern; see WildcardPolicy.
+type patternRank struct {
+	literals, many, one int
+}
+
+func rankPattern(segs []string) patternRank {
+	var r patternRank
+	for _, seg := range segs {
+		switch seg {
+		case wildcardOne:
+			r.one++
+		case wildcardMany:
+			r.many++
+		default:
+			r.literals++
+		}
+	}
+	return r
+}
+
+// moreSpecific reports whether r takes precedence over o.
+func (r patternRank) moreSpecific(o patternRank) bool {
+	switch {
+	case r.literals+r.one != o.literals+o.one:
+		return r.literals+r.one > o.literals+o.one
+	case r.literals != o.literals:
+		return r.literals > o.literals
+	default:
+		return r.many < o.many
+	}
+}
+
+// matchPattern reports whether the pattern segments match the message type segments.
+func matchPattern(pattern, typ []string) bool {
+	if len(pattern) == 0 {
+		return len(typ) == 0
+	}
+	switch pattern[0] {
+	case wildcardMany:
+		for i := 0; i <= len(typ); i++ {
+			if matchPattern(pattern[1:], typ[i:]) {
+				return true
+			}
+		}
+		return false
+	case wildcardOne:
+		return len(typ) > 0 && matchPattern(pattern[1:], typ[1:])
+	default:
+		return len(typ) > 0 && pattern[0] == typ[0] && matchPattern(pattern[1:], typ[1:])
+	}
+}
+
+// patternsOverlap reports whether some message type matches both patterns.
+func patternsOverlap(p, q []string) bool {
+	switch {
+	case len(p) > 0 && p[0] == wildcardMany:
+		// p's "#" matches nothing more, or absorbs q's next segment.
+		return patternsOverlap(p[1:], q) || (len(q) > 0 && patternsOverlap(p, q[1:]))
+	case len(q) > 0 && q[0] == wildcardMany:
+		return patternsOverlap(p, q[1:]) || (len(p) > 0 && patternsOverlap(p[1:], q))
+	case len(p) == 0 || len(q) == 0:
+		return len(p) == 0 && len(q) == 0
+	case p[0] == wildcardOne || q[0] == wildcardOne || p[0] == q[0]:
+		return patternsOverlap(p[1:], q[1:])
+	default:
+		return false
+	}
+}
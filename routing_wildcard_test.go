@@ -0,0 +1,164 @@
+package sqsrouter
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestWildcardPolicy_Table(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	keys := []HandlerKey{
+		"billing.#:v1",
+		"billing.invoice.*:v1",
+		"billing.invoice.created:v1",
+		"billing.*.*.refunded:v1",
+		"shipping.*:v2",
+	}
+
+	cases := []struct {
+		name    string
+		msgType string
+		version string
+		want    RoutingDecision
+	}{
+		{"exact key wins", "billing.invoice.created", "v1", RoutingDecision{Key: "billing.invoice.created:v1"}},
+		{"star matches one segment", "billing.invoice.paid", "v1", RoutingDecision{Key: "billing.invoice.*:v1", Fallback: WildcardFallbackPattern}},
+		{"hash matches several segments", "billing.invoice.line.added", "v1", RoutingDecision{Key: "billing.#:v1", Fallback: WildcardFallbackPattern}},
+		{"hash matches zero segments", "billing", "v1", RoutingDecision{Key: "billing.#:v1", Fallback: WildcardFallbackPattern}},
+		{"more literals win", "billing.card.x.refunded", "v1", RoutingDecision{Key: "billing.*.*.refunded:v1", Fallback: WildcardFallbackPattern}},
+		{"version must match", "billing.invoice.paid", "v2", RoutingDecision{}},
+		{"star needs a segment", "shipping", "v2", RoutingDecision{}},
+		{"star does not span segments", "shipping.label.printed", "v2", RoutingDecision{}},
+		{"no match", "orders.created", "v1", RoutingDecision{}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			env := MessageEnvelope{MessageType: tc.msgType, MessageVersion: tc.version}
+			got := WildcardPolicy{}.DecideRouting(ctx, &env, keys)
+			if got != tc.want {
+				t.Fatalf("want %+v, got %+v", tc.want, got)
+			}
+			if key := (WildcardPolicy{}).Decide(ctx, &env, keys); key != tc.want.Key {
+				t.Fatalf("Decide = %q, want %q", key, tc.want.Key)
+			}
+		})
+	}
+}
+
+func TestWildcardPolicy_FewerHashesWin(t *testing.T) {
+	t.Parallel()
+	env := MessageEnvelope{MessageType: "a.b.c", MessageVersion: "v1"}
+	got := WildcardPolicy{}.Decide(context.Background(), &env, []HandlerKey{"a.#:v1", "a.*.#:v1", "a.*.*:v1"})
+	if got != "a.*.*:v1" {
+		t.Fatalf("got %q, want a.*.*:v1", got)
+	}
+}
+
+func TestWildcardPolicy_MoreFixedSegmentsWin(t *testing.T) {
+	t.Parallel()
+	env := MessageEnvelope{MessageType: "a.b.c", MessageVersion: "v1"}
+	got := WildcardPolicy{}.Decide(context.Background(), &env, []HandlerKey{"a.#:v1", "a.*.#:v1"})
+	if got != "a.*.#:v1" {
+		t.Fatalf("got %q, want a.*.#:v1", got)
+	}
+	got = WildcardPolicy{}.Decide(context.Background(), &env, []HandlerKey{"a.*.*:v1", "a.b.#:v1"})
+	if got != "a.*.*:v1" {
+		t.Fatalf("got %q, want a.*.*:v1", got)
+	}
+}
+
+func TestWildcardPolicy_ValidateKey(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name       string
+		key        HandlerKey
+		registered []HandlerKey
+		wantErr    error
+	}{
+		{"literal key", "billing.invoice.created:v1", []HandlerKey{"billing.invoice.*:v1"}, nil},
+		{"more specific pattern", "billing.invoice.*:v1", []HandlerKey{"billing.#:v1"}, nil},
+		{"same rank disjoint", "billing.*:v1", []HandlerKey{"shipping.*:v1"}, nil},
+		{"same rank other version", "billing.*.created:v2", []HandlerKey{"billing.invoice.*:v1"}, nil},
+		{"overlap with different rank", "a.*.c:v1", []HandlerKey{"a.#:v1"}, nil},
+		{"partial wildcard", "billing.inv*:v1", nil, ErrInvalidHandlerKey},
+		{"hash inside segment", "billing.#x:v1", nil, ErrInvalidHandlerKey},
+		{"star overlap", "billing.*.created:v1", []HandlerKey{"billing.invoice.*:v1"}, ErrAmbiguousHandlerKey},
+		{"hash overlap", "#.created:v1", []HandlerKey{"billing.#:v1"}, ErrAmbiguousHandlerKey},
+		{"hash against star", "a.#.c:v1", []HandlerKey{"a.b.#:v1"}, ErrAmbiguousHandlerKey},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			err := WildcardPolicy{}.ValidateKey(tc.key, tc.registered)
+			if !errors.Is(err, tc.wantErr) || (tc.wantErr == nil && err != nil) {
+				t.Fatalf("err = %v, want %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestWildcardPolicy_RouterRegistration(t *testing.T) {
+	r, err := NewRouter(EnvelopeSchema, WithRoutingPolicy(WildcardPolicy{}))
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+	var handled string
+	r.Register("billing.invoice.*", "v1", func(context.Context, []byte, []byte) HandlerResult {
+		handled = "invoice"
+		return HandlerResult{ShouldDelete: true}
+	})
+
+	func() {
+		defer func() {
+			if rec := recover(); rec == nil {
+				t.Error("Register should panic on an ambiguous pattern")
+			} else if err, ok := rec.(error); !ok || !errors.Is(err, ErrAmbiguousHandlerKey) {
+				t.Errorf("panic value = %v, want ErrAmbiguousHandlerKey", rec)
+			}
+		}()
+		r.Register("billing.*.created", "v1", func(context.Context, []byte, []byte) HandlerResult {
+			return HandlerResult{ShouldDelete: true}
+		})
+	}()
+	if _, ok := r.table.Load().handlers["billing.*.created:v1"]; ok {
+		t.Error("rejected pattern must not be registered")
+	}
+
+	err = r.TryRegister("billing.*.created", "v1", func(context.Context, []byte, []byte) HandlerResult {
+		return HandlerResult{ShouldDelete: true}
+	})
+	if !errors.Is(err, ErrAmbiguousHandlerKey) {
+		t.Errorf("TryRegister err = %v, want ErrAmbiguousHandlerKey", err)
+	}
+
+	err = RegisterTyped(r, "billing.*.created", "v1", func(context.Context, struct{}, MessageMetadata) HandlerResult {
+		return HandlerResult{ShouldDelete: true}
+	})
+	if !errors.Is(err, ErrAmbiguousHandlerKey) {
+		t.Errorf("RegisterTyped err = %v, want ErrAmbiguousHandlerKey", err)
+	}
+	if _, ok := r.table.Load().schemas["billing.*.created:v1"]; ok {
+		t.Error("rejected pattern must not have its schema registered")
+	}
+
+	// Re-registering the same pattern replaces the handler.
+	r.Register("billing.invoice.*", "v1", func(context.Context, []byte, []byte) HandlerResult {
+		handled = "replaced"
+		return HandlerResult{ShouldDelete: true}
+	})
+
+	raw := []byte(`{"schemaVersion":"1.0","messageType":"billing.invoice.paid","messageVersion":"v1","message":{},"metadata":{}}`)
+	rr := r.Route(context.Background(), raw)
+	if rr.FailureKind != FailNone || handled != "replaced" {
+		t.Fatalf("pattern handler not invoked: %+v, handled=%q", rr, handled)
+	}
+	if rr.MessageType != "billing.invoice.paid" {
+		t.Errorf("MessageType = %q, want the message's own type", rr.MessageType)
+	}
+}
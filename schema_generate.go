@@ -0,0 +1,354 @@
+package sqsrouter
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SchemaTag is the struct tag read by GenerateSchema. Its value is a comma-separated list of:
+//
+//	required          the property must be present
+//	format=<name>     a JSON Schema format, e.g. email, date-time, uuid
+//	enum=<a>|<b>|...  the allowed values, parsed according to the field type
+//	minimum=<n>       inclusive lower bound of a number
+//	maximum=<n>       inclusive upper bound of a number
+//	minLength=<n>     minimum length of a string
+//	maxLength=<n>     maximum length of a string
+//	minItems=<n>      minimum length of a slice or array
+//	maxItems=<n>      maximum length of a slice or array
+//
+// For example: `json:"email" jsonschema:"required,format=email"`.
+const SchemaTag = "jsonschema"
+
+// draft07 is the $schema URI of generated schemas.
+const draft07 = "http://json-schema.org/draft-07/schema#"
+
+var (
+	timeType          = reflect.TypeFor[time.Time]()
+	rawMessageType    = reflect.TypeFor[json.RawMessage]()
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// GenerateSchema derives a draft-07 JSON Schema for the JSON encoding of T, ready for Router.RegisterSchema.
+// Properties follow encoding/json naming, including `json` tag names, omitted fields and embedded structs;
+// constraints come from the SchemaTag struct tag. Pointers also accept null, and fields with the
+// `json:",string"` option are strings. Types with custom JSON
+// marshaling accept any value, except time.Time and encoding.TextMarshaler types, which are strings.
+// Recursive types, channels, functions and complex numbers are not supported.
+func GenerateSchema[T any]() (string, error) {
+	schema, err := generateSchema(reflect.TypeFor[T]())
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidSchema, err)
+	}
+	return schema, nil
+}
+
+func generateSchema(t reflect.Type) (string, error) {
+	g := schemaGenerator{visiting: make(map[reflect.Type]bool)}
+	s, err := g.schemaFor(t)
+	if err != nil {
+		return "", err
+	}
+	s.Schema = draft07
+	out, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// generatedSchema is the subset of JSON Schema emitted by GenerateSchema.
+// Properties is a map, so properties are emitted in sorted order and the output is deterministic.
+type generatedSchema struct {
+	Schema               string                      `json:"$schema,omitempty"`
+	Type                 any                         `json:"type,omitempty"`
+	Format               string                      `json:"format,omitempty"`
+	Enum                 []any                       `json:"enum,omitempty"`
+	Minimum              json.Number                 `json:"minimum,omitempty"`
+	Maximum              json.Number                 `json:"maximum,omitempty"`
+	MinLength            *int                        `json:"minLength,omitempty"`
+	MaxLength            *int                        `json:"maxLength,omitempty"`
+	MinItems             *int                        `json:"minItems,omitempty"`
+	MaxItems             *int                        `json:"maxItems,omitempty"`
+	Items                *generatedSchema            `json:"items,omitempty"`
+	Properties           map[string]*generatedSchema `json:"properties,omitempty"`
+	AdditionalProperties *generatedSchema            `json:"additionalProperties,omitempty"`
+	Required             []string                    `json:"required,omitempty"`
+}
+
+type schemaGenerator struct {
+	// visiting holds the struct types being generated, to detect recursion.
+	visiting map[reflect.Type]bool
+}
+
+func (g schemaGenerator) schemaFor(t reflect.Type) (*generatedSchema, error) {
+	if t.Kind() == reflect.Pointer {
+		s, err := g.schemaFor(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		if typ, ok := s.Type.(string); ok {
+			s.Type = []string{typ, "null"}
+		}
+		return s, nil
+	}
+
+	switch {
+	case t == timeType:
+		return &generatedSchema{Type: "string", Format: "date-time"}, nil
+	case t == rawMessageType, t.Implements(jsonMarshalerType), reflect.PointerTo(t).Implements(jsonMarshalerType):
+		return &generatedSchema{}, nil
+	case t.Implements(textMarshalerType), reflect.PointerTo(t).Implements(textMarshalerType):
+		return &generatedSchema{Type: "string"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &generatedSchema{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &generatedSchema{Type: "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return &generatedSchema{Type: "number"}, nil
+	case reflect.String:
+		return &generatedSchema{Type: "string"}, nil
+	case reflect.Interface:
+		return &generatedSchema{}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			// encoding/json encodes []byte as a base64 string.
+			return &generatedSchema{Type: "string"}, nil
+		}
+		items, err := g.schemaFor(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &generatedSchema{Type: "array", Items: items}, nil
+	case reflect.Map:
+		switch t.Key().Kind() {
+		case reflect.String, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		default:
+			if !t.Key().Implements(textMarshalerType) {
+				return nil, fmt.Errorf("unsupported map key type %s", t.Key())
+			}
+		}
+		values, err := g.schemaFor(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &generatedSchema{Type: "object", AdditionalProperties: values}, nil
+	case reflect.Struct:
+		return g.structSchema(t)
+	default:
+		return nil, fmt.Errorf("unsupported type %s", t)
+	}
+}
+
+func (g schemaGenerator) structSchema(t reflect.Type) (*generatedSchema, error) {
+	if g.visiting[t] {
+		return nil, fmt.Errorf("recursive type %s", t)
+	}
+	g.visiting[t] = true
+	defer delete(g.visiting, t)
+
+	s := &generatedSchema{Type: "object", Properties: make(map[string]*generatedSchema)}
+	if err := g.addFields(s, t, false); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// addFields adds the JSON properties of struct type t to s, promoting the fields of untagged embedded structs.
+// Like encoding/json, a promoted field never replaces a property of the embedding struct.
+func (g schemaGenerator) addFields(s *generatedSchema, t reflect.Type, promoted bool) error {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		jsonTag := f.Tag.Get("json")
+		if jsonTag == "-" {
+			continue
+		}
+		name, jsonOpts, _ := strings.Cut(jsonTag, ",")
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if err := g.addFields(s, ft, true); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if _, exists := s.Properties[name]; exists && promoted {
+			continue
+		}
+
+		prop, err := g.schemaFor(f.Type)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", f.Name, err)
+		}
+		quoted := quotedField(f.Type, jsonOpts)
+		if quoted {
+			prop.Type = withNull(prop.Type, "string")
+		}
+		required, err := applySchemaTag(prop, f.Tag.Get(SchemaTag))
+		if err != nil {
+			return fmt.Errorf("field %s: %w", f.Name, err)
+		}
+		if quoted && derefUnnamed(f.Type).Kind() == reflect.String {
+			// The string is JSON-encoded a second time, quotes included.
+			for i, v := range prop.Enum {
+				if v != nil {
+					b, _ := json.Marshal(v)
+					prop.Enum[i] = string(b)
+				}
+			}
+		}
+		_, replaced := s.Properties[name]
+		s.Properties[name] = prop
+		if replaced {
+			s.Required = slices.DeleteFunc(s.Required, func(r string) bool { return r == name })
+		}
+		if required {
+			s.Required = append(s.Required, name)
+		}
+	}
+	return nil
+}
+
+// quotedField reports whether encoding/json encodes a field of type t with the json tag options opts inside
+// a JSON string: the ",string" option applies to booleans, numbers and strings and unnamed pointers to them,
+// unless they implement json.Marshaler.
+func quotedField(t reflect.Type, opts string) bool {
+	if !slices.Contains(strings.Split(opts, ","), "string") {
+		return false
+	}
+	t = derefUnnamed(t)
+	if t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType) {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.String:
+		return true
+	default:
+		return false
+	}
+}
+
+// derefUnnamed returns the element type of an unnamed pointer type, and other types unchanged.
+func derefUnnamed(t reflect.Type) reflect.Type {
+	if t.Name() == "" && t.Kind() == reflect.Pointer {
+		return t.Elem()
+	}
+	return t
+}
+
+// withNull returns the JSON type typ, keeping "null" when the current type cur allows it.
+func withNull(cur any, typ string) any {
+	if types, ok := cur.([]string); ok && slices.Contains(types, "null") {
+		return []string{typ, "null"}
+	}
+	return typ
+}
+
+// applySchemaTag adds the constraints of a SchemaTag value to s and reports whether the field is required.
+func applySchemaTag(s *generatedSchema, tag string) (required bool, err error) {
+	if tag == "" {
+		return false, nil
+	}
+	for opt := range strings.SplitSeq(tag, ",") {
+		key, value, _ := strings.Cut(opt, "=")
+		switch key {
+		case "required":
+			required = true
+		case "format":
+			s.Format = value
+		case "enum":
+			for v := range strings.SplitSeq(value, "|") {
+				ev, err := enumValue(s.Type, v)
+				if err != nil {
+					return false, err
+				}
+				s.Enum = append(s.Enum, ev)
+			}
+			if types, ok := s.Type.([]string); ok && slices.Contains(types, "null") {
+				// A pointer field also accepts null, which the enum must list as well.
+				s.Enum = append(s.Enum, nil)
+			}
+		case "minimum", "maximum":
+			if _, err := strconv.ParseFloat(value, 64); err != nil {
+				return false, fmt.Errorf("invalid %s %q", key, value)
+			}
+			if key == "minimum" {
+				s.Minimum = json.Number(value)
+			} else {
+				s.Maximum = json.Number(value)
+			}
+		case "minLength", "maxLength", "minItems", "maxItems":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return false, fmt.Errorf("invalid %s %q", key, value)
+			}
+			switch key {
+			case "minLength":
+				s.MinLength = &n
+			case "maxLength":
+				s.MaxLength = &n
+			case "minItems":
+				s.MinItems = &n
+			case "maxItems":
+				s.MaxItems = &n
+			}
+		default:
+			return false, fmt.Errorf("unknown %s tag option %q", SchemaTag, key)
+		}
+	}
+	return required, nil
+}
+
+// enumValue parses an enum value according to the JSON type of the field.
+func enumValue(typ any, v string) (any, error) {
+	if types, ok := typ.([]string); ok {
+		typ = types[0] // pointer: the non-null type
+	}
+	switch typ {
+	case "integer":
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer enum value %q", v)
+		}
+		return n, nil
+	case "number":
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number enum value %q", v)
+		}
+		return n, nil
+	case "boolean":
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid boolean enum value %q", v)
+		}
+		return b, nil
+	default:
+		return v, nil
+	}
+}
@@ -0,0 +1,180 @@
+package sqsrouter
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hatsunemiku3939/sqsrouter/internal/jsonschema"
+)
+
+type schemaAddress struct {
+	City string `json:"city" jsonschema:"required"`
+}
+
+type schemaBase struct {
+	ID      string `json:"id" jsonschema:"required,format=uuid"`
+	Created time.Time
+}
+
+type schemaProfile struct {
+	schemaBase
+	Email    string          `json:"email" jsonschema:"required,format=email,maxLength=254"`
+	Age      int             `json:"age,omitempty" jsonschema:"minimum=0,maximum=150"`
+	Score    float64         `json:"score" jsonschema:"enum=0.5|1"`
+	Role     *string         `json:"role" jsonschema:"enum=admin|user"`
+	Tags     []string        `json:"tags" jsonschema:"minItems=1"`
+	Avatar   []byte          `json:"avatar"`
+	Address  *schemaAddress  `json:"address"`
+	Labels   map[string]int  `json:"labels"`
+	Extra    json.RawMessage `json:"extra"`
+	Any      any             `json:"any"`
+	Active   bool            `json:"active" jsonschema:"required"`
+	Ignored  string          `json:"-"`
+	Matrix   [2][]uint8      `json:"matrix"`
+	Untagged map[int][]float32
+}
+
+func TestGenerateSchema(t *testing.T) {
+	got, err := GenerateSchema[schemaProfile]()
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+
+	want := `{"$schema":"http://json-schema.org/draft-07/schema#","type":"object","properties":{` +
+		`"Created":{"type":"string","format":"date-time"},` +
+		`"Untagged":{"type":"object","additionalProperties":{"type":"array","items":{"type":"number"}}},` +
+		`"active":{"type":"boolean"},` +
+		`"address":{"type":["object","null"],"properties":{"city":{"type":"string"}},"required":["city"]},` +
+		`"age":{"type":"integer","minimum":0,"maximum":150},` +
+		`"any":{},` +
+		`"avatar":{"type":"string"},` +
+		`"email":{"type":"string","format":"email","maxLength":254},` +
+		`"extra":{},` +
+		`"id":{"type":"string","format":"uuid"},` +
+		`"labels":{"type":"object","additionalProperties":{"type":"integer"}},` +
+		`"matrix":{"type":"array","items":{"type":"string"}},` +
+		`"role":{"type":["string","null"],"enum":["admin","user",null]},` +
+		`"score":{"type":"number","enum":[0.5,1]},` +
+		`"tags":{"type":"array","minItems":1,"items":{"type":"string"}}},` +
+		`"required":["id","email","active"]}`
+	if got != want {
+		t.Errorf("schema mismatch\n got: %s\nwant: %s", got, want)
+	}
+}
+
+func TestGenerateSchemaCompiles(t *testing.T) {
+	schema, err := GenerateSchema[schemaProfile]()
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	router, err := NewRouter(EnvelopeSchema)
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+	if err := router.RegisterSchema("Profile", "v1", schema); err != nil {
+		t.Fatalf("generated schema does not compile: %v", err)
+	}
+}
+
+func TestGenerateSchemaPromotedFieldDoesNotReplace(t *testing.T) {
+	type outer struct {
+		ID int `json:"id"`
+		schemaBase
+	}
+	got, err := GenerateSchema[outer]()
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if !strings.Contains(got, `"id":{"type":"integer"}`) || strings.Contains(got, `"required"`) {
+		t.Errorf("embedding struct's id should win over the promoted one: %s", got)
+	}
+}
+
+func TestGenerateSchemaStringOption(t *testing.T) {
+	type quoted struct {
+		Count int64     `json:"count,string"`
+		Level *int      `json:"level,omitempty,string" jsonschema:"enum=1|2"`
+		Code  string    `json:"code,string" jsonschema:"enum=a"`
+		Ratio float64   `json:",string"`
+		Time  time.Time `json:"time,string"`
+	}
+	got, err := GenerateSchema[quoted]()
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	want := `{"$schema":"http://json-schema.org/draft-07/schema#","type":"object","properties":{` +
+		`"Ratio":{"type":"string"},` +
+		`"code":{"type":"string","enum":["\"a\""]},` +
+		`"count":{"type":"string"},` +
+		`"level":{"type":["string","null"],"enum":["1","2",null]},` +
+		`"time":{"type":"string","format":"date-time"}}}`
+	if got != want {
+		t.Fatalf("schema mismatch\n got: %s\nwant: %s", got, want)
+	}
+
+	// The JSON encoding of the type must validate against its schema.
+	schema, err := jsonschema.NewSchema(jsonschema.NewStringLoader(got))
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	level := 2
+	doc, err := json.Marshal(quoted{Count: 3, Level: &level, Code: "a", Ratio: 0.5})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	res, err := jsonschema.ValidateSchema(schema, jsonschema.NewBytesLoader(doc))
+	if err := jsonschema.FormatErrors(res, err); err != nil {
+		t.Errorf("%s does not validate: %v", doc, err)
+	}
+}
+
+func TestGenerateSchemaErrors(t *testing.T) {
+	type recursive struct {
+		Next *recursive `json:"next"`
+	}
+	type unknownOption struct {
+		A string `jsonschema:"pattern=^a"`
+	}
+	type badMinimum struct {
+		A int `jsonschema:"minimum=zero"`
+	}
+	type badEnum struct {
+		A int `jsonschema:"enum=1|two"`
+	}
+	type badLength struct {
+		A string `jsonschema:"maxLength=-1"`
+	}
+	type badMapKey struct {
+		M map[[2]int]string
+	}
+
+	tests := []struct {
+		name string
+		gen  func() (string, error)
+		want string
+	}{
+		{"recursive", GenerateSchema[recursive], "recursive type"},
+		{"channel", GenerateSchema[chan int], "unsupported type chan int"},
+		{"func field", GenerateSchema[struct{ F func() }], "field F: unsupported type func()"},
+		{"complex", GenerateSchema[complex128], "unsupported type complex128"},
+		{"unknown option", GenerateSchema[unknownOption], `unknown jsonschema tag option "pattern"`},
+		{"bad minimum", GenerateSchema[badMinimum], `invalid minimum "zero"`},
+		{"bad enum", GenerateSchema[badEnum], `invalid integer enum value "two"`},
+		{"bad length", GenerateSchema[badLength], `invalid maxLength "-1"`},
+		{"bad map key", GenerateSchema[badMapKey], "unsupported map key type [2]int"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.gen()
+			if !errors.Is(err, ErrInvalidSchema) {
+				t.Fatalf("err = %v, want ErrInvalidSchema", err)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package sqsrouter
+
+import "time"
+
+// SQSAttributes describes how the message being routed was delivered by SQS.
+// The consumer populates it from the ReceiveMessage response and passes it to Router.Route through
+// the context (see WithSQSAttributes). The router exposes it as RouteState.SQS, and handlers can read
+// it with SQSAttributesFromContext.
+type SQSAttributes struct {
+	// QueueURL is the queue the message was received from.
+	QueueURL string
+	// MessageID is the SQS-assigned message ID (not the envelope metadata messageId).
+	MessageID string
+	// ApproximateReceiveCount is the number of times the message has been received, including this one.
+	ApproximateReceiveCount int
+	// SentTimestamp is when SQS accepted the message.
+	SentTimestamp time.Time
+	// ApproximateFirstReceiveTimestamp is when the message was first received.
+	ApproximateFirstReceiveTimestamp time.Time
+	// MessageGroupID is set for FIFO queues.
+	MessageGroupID string
+	// MessageDeduplicationID is set for FIFO queues.
+	MessageDeduplicationID string
+	// SequenceNumber is set for FIFO queues.
+	SequenceNumber string
+	// AWSTraceHeader carries the X-Ray trace header, if any.
+	AWSTraceHeader string
+	// MessageAttributes are the user-defined message attributes.
+	MessageAttributes map[string]MessageAttribute
+}
+
+// MessageAttribute is a user-defined SQS message attribute.
+type MessageAttribute struct {
+	DataType    string
+	StringValue string
+	BinaryValue []byte
+}
+
+// Age returns how long ago SQS accepted the message, or zero if SentTimestamp is unknown.
+func (a *SQSAttributes) Age(now time.Time) time.Duration {
+	if a == nil || a.SentTimestamp.IsZero() {
+		return 0
+	}
+	return now.Sub(a.SentTimestamp)
+}
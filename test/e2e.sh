@@ -167,7 +167,7 @@ TEST_ID3=$(cat /proc/sys/kernel/random/uuid)
 send_message "e2eTest" "1.0" "{\"testId\": \"$TEST_ID3\", \"payload\": \"should fail by mw\"}"
 wait_for_log "E2E_MW_BEFORE"
 wait_for_log "E2E_MW_AFTER_ERR"
-wait_for_log "RETRYING message ID"
+wait_for_log "message processed.*deleted=false"
 
 echo "--- Scenario 5: Policy overrides handler error to retry ---"
 kill "$APP_PID" 2>/dev/null || true
@@ -181,6 +181,6 @@ start_app
 TEST_ID4=$(cat /proc/sys/kernel/random/uuid)
 send_message "e2eTest" "1.0" "{\"testId\": \"$TEST_ID4\", \"payload\": \"handler error scenario\"}"
 wait_for_log "E2E_TEST_SUCCESS: Received message for test ID $TEST_ID4"
-wait_for_log "RETRYING message ID"
+wait_for_log "message processed.*deleted=false"
 
 echo "✅ All E2E scenarios passed."
@@ -179,8 +179,11 @@ func main() {
 
 	router.Register(MsgTypeE2ETest, MsgVersion1_0, E2ETestHandler)
 
-    c := consumer.NewConsumer(sqsClient, queueURL, router)
-    c.Start(appCtx)
+	c, err := consumer.NewConsumer(sqsClient, queueURL, router)
+	if err != nil {
+		log.Fatalf("Could not initialize consumer: %v", err)
+	}
+	c.Start(appCtx)
 
 	log.Println("Application has shut down.")
 }
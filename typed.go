@@ -0,0 +1,104 @@
+package sqsrouter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/hatsunemiku3939/sqsrouter/internal/jsonschema"
+)
+
+// TypedHandler processes a message whose payload has been decoded into T.
+// It receives the envelope metadata as decoded by the router.
+type TypedHandler[T any] func(ctx context.Context, msg T, meta MessageMetadata) HandlerResult
+
+// TypedOption configures a handler registered with RegisterTyped.
+type TypedOption func(*typedConfig)
+
+type typedConfig struct {
+	disallowUnknownFields bool
+	skipSchema            bool
+	replaceSchema         bool
+}
+
+// DisallowUnknownFields makes payload decoding fail when the payload contains fields that T does not declare.
+func DisallowUnknownFields() TypedOption {
+	return func(c *typedConfig) { c.disallowUnknownFields = true }
+}
+
+// WithoutGeneratedSchema skips registering the schema generated from T, leaving any schema
+// registered with Router.RegisterSchema for the message type and version in place.
+func WithoutGeneratedSchema() TypedOption {
+	return func(c *typedConfig) { c.skipSchema = true }
+}
+
+// ReplaceSchema registers the schema generated from T even when a schema was registered with
+// Router.RegisterSchema for the message type and version, replacing it.
+func ReplaceSchema() TypedOption {
+	return func(c *typedConfig) { c.replaceSchema = true }
+}
+
+// RegisterTyped adds a handler for a specific message type and version whose payload is decoded into T.
+// Unless WithoutGeneratedSchema is given, the schema derived from T by GenerateSchema is registered as the
+// payload schema. It replaces a schema generated by an earlier RegisterTyped call, but not one registered with
+// Router.RegisterSchema unless ReplaceSchema is given. The payload is decoded once per message; if
+// decoding fails the handler is not called and the failure policy is consulted with FailPayloadDecode.
+// An error is returned, and nothing is registered, when T has no schema or the routing policy rejects
+// the handler key.
+func RegisterTyped[T any](r *Router, messageType, messageVersion string, handler TypedHandler[T], opts ...TypedOption) error {
+	var cfg typedConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	var schema *typedSchema
+	if !cfg.skipSchema {
+		generated, err := generateSchema(reflect.TypeFor[T]())
+		var compiled *jsonschema.Schema
+		if err == nil {
+			compiled, err = jsonschema.NewSchema(jsonschema.NewStringLoader(generated))
+		}
+		if err != nil {
+			return fmt.Errorf("%w for %s:%s: %v", ErrInvalidSchema, messageType, messageVersion, err)
+		}
+		schema = &typedSchema{schema: compiled, replace: cfg.replaceSchema}
+	}
+
+	decode := func(payload []byte) (T, error) {
+		var msg T
+		dec := json.NewDecoder(bytes.NewReader(payload))
+		if cfg.disallowUnknownFields {
+			dec.DisallowUnknownFields()
+		}
+		if err := dec.Decode(&msg); err != nil {
+			return msg, fmt.Errorf("%w: %v", ErrFailedToDecodePayload, err)
+		}
+		return msg, nil
+	}
+
+	entry := handlerEntry{
+		// handler keeps RouteState.Handler usable for middlewares that invoke it with raw JSON.
+		handler: func(ctx context.Context, messageJSON []byte, metadataJSON []byte) HandlerResult {
+			msg, err := decode(messageJSON)
+			if err != nil {
+				return HandlerResult{ShouldDelete: true, Error: err}
+			}
+			var meta MessageMetadata
+			if err := unmarshalMetadata(metadataJSON, &meta); err != nil {
+				return HandlerResult{ShouldDelete: true, Error: fmt.Errorf("%w: %v", ErrFailedToParseEnvelope, err)}
+			}
+			return handler(ctx, msg, meta)
+		},
+		bind: func(env *MessageEnvelope) (func(ctx context.Context) HandlerResult, error) {
+			msg, err := decode(env.Message)
+			if err != nil {
+				return nil, err
+			}
+			meta := env.Metadata
+			return func(ctx context.Context) HandlerResult { return handler(ctx, msg, meta) }, nil
+		},
+	}
+	return r.register(messageType, messageVersion, entry, schema)
+}
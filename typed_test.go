@@ -0,0 +1,254 @@
+package sqsrouter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type userCreated struct {
+	UserID string `json:"userId"`
+	Name   string `json:"name"`
+}
+
+const typedMessage = `{"schemaVersion":"1.0","messageType":"UserCreated","messageVersion":"v1",` +
+	`"message":{"userId":"u-1","name":"Alice"%s},` +
+	`"metadata":{"timestamp":"2024-01-01T00:00:00Z","source":"svcA","messageId":"m-1"}}`
+
+// typedBody returns typedMessage with extra appended to the payload object.
+func typedBody(extra string) []byte {
+	return []byte(fmt.Sprintf(typedMessage, extra))
+}
+
+func TestRegisterTyped(t *testing.T) {
+	router, err := NewRouter(EnvelopeSchema)
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+	var got userCreated
+	var gotMeta MessageMetadata
+	err = RegisterTyped(router, "UserCreated", "v1", func(_ context.Context, msg userCreated, meta MessageMetadata) HandlerResult {
+		got, gotMeta = msg, meta
+		return HandlerResult{ShouldDelete: true}
+	})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	rr := router.Route(context.Background(), typedBody(`,"extra":true`))
+
+	if rr.HandlerResult.Error != nil || !rr.HandlerResult.ShouldDelete || rr.FailureKind != FailNone {
+		t.Fatalf("unexpected result: %+v", rr)
+	}
+	if got != (userCreated{UserID: "u-1", Name: "Alice"}) {
+		t.Errorf("payload = %+v", got)
+	}
+	if !reflect.DeepEqual(gotMeta, MessageMetadata{Timestamp: "2024-01-01T00:00:00Z", Source: "svcA", MessageID: "m-1"}) {
+		t.Errorf("metadata = %+v", gotMeta)
+	}
+}
+
+func TestRegisterTypedDecodeFailure(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  []byte
+		opts  []TypedOption
+		wantK FailureKind
+	}{
+		{"unknown field rejected", typedBody(`,"extra":true`), []TypedOption{DisallowUnknownFields()}, FailPayloadDecode},
+		{"type mismatch", []byte(`{"schemaVersion":"1.0","messageType":"UserCreated","messageVersion":"v1","message":{"userId":7},"metadata":{}}`), []TypedOption{WithoutGeneratedSchema()}, FailPayloadDecode},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, err := NewRouter(EnvelopeSchema)
+			if err != nil {
+				t.Fatalf("new router: %v", err)
+			}
+			called := false
+			err = RegisterTyped(router, "UserCreated", "v1", func(context.Context, userCreated, MessageMetadata) HandlerResult {
+				called = true
+				return HandlerResult{ShouldDelete: true}
+			}, tt.opts...)
+			if err != nil {
+				t.Fatalf("register: %v", err)
+			}
+
+			rr := router.Route(context.Background(), tt.body)
+
+			if called {
+				t.Fatal("handler must not be called when the payload does not decode")
+			}
+			if rr.FailureKind != tt.wantK {
+				t.Errorf("FailureKind = %v, want %v", rr.FailureKind, tt.wantK)
+			}
+			if !errors.Is(rr.HandlerResult.Error, ErrFailedToDecodePayload) {
+				t.Errorf("error = %v, want ErrFailedToDecodePayload", rr.HandlerResult.Error)
+			}
+			if !rr.HandlerResult.ShouldDelete {
+				t.Error("ImmediateDeletePolicy should delete undecodable payloads")
+			}
+			if rr.MessageType != "UserCreated" || rr.MessageVersion != "v1" {
+				t.Errorf("routing info missing from result: %+v", rr)
+			}
+		})
+	}
+}
+
+func TestRegisterTypedStateHandler(t *testing.T) {
+	router, err := NewRouter(EnvelopeSchema)
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+	var got userCreated
+	err = RegisterTyped(router, "UserCreated", "v1", func(_ context.Context, msg userCreated, _ MessageMetadata) HandlerResult {
+		got = msg
+		return HandlerResult{ShouldDelete: true}
+	})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	var stateHandler MessageHandler
+	router.Use(func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, s *RouteState) (RoutedResult, error) {
+			rr, err := next(ctx, s)
+			stateHandler = s.Handler
+			return rr, err
+		}
+	})
+	router.Route(context.Background(), typedBody(""))
+
+	if stateHandler == nil {
+		t.Fatal("RouteState.Handler must be set for typed handlers")
+	}
+	got = userCreated{}
+	res := stateHandler(context.Background(), []byte(`{"userId":"u-2"}`), []byte(`{"messageId":"m-2"}`))
+	if res.Error != nil || got.UserID != "u-2" {
+		t.Fatalf("RouteState.Handler did not invoke the typed handler: %+v, %+v", res, got)
+	}
+}
+
+func TestRegisterTypedGeneratedSchema(t *testing.T) {
+	type signup struct {
+		Email string `json:"email" jsonschema:"required,format=email"`
+		Plan  string `json:"plan" jsonschema:"enum=free|pro"`
+	}
+	router, err := NewRouter(EnvelopeSchema)
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+	called := 0
+	err = RegisterTyped(router, "UserCreated", "v1", func(context.Context, signup, MessageMetadata) HandlerResult {
+		called++
+		return HandlerResult{ShouldDelete: true}
+	})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	body := func(payload string) []byte {
+		return []byte(`{"schemaVersion":"1.0","messageType":"UserCreated","messageVersion":"v1","message":` + payload + `,"metadata":{}}`)
+	}
+
+	if rr := router.Route(context.Background(), body(`{"email":"a@example.com","plan":"pro"}`)); rr.FailureKind != FailNone {
+		t.Fatalf("valid payload rejected: %+v", rr)
+	}
+	for _, payload := range []string{`{"plan":"pro"}`, `{"email":"not-an-email"}`, `{"email":"a@example.com","plan":"gold"}`} {
+		rr := router.Route(context.Background(), body(payload))
+		if rr.FailureKind != FailPayloadSchema || !errors.Is(rr.HandlerResult.Error, ErrInvalidMessagePayload) {
+			t.Errorf("payload %s: got %v, %v; want FailPayloadSchema", payload, rr.FailureKind, rr.HandlerResult.Error)
+		}
+	}
+	if called != 1 {
+		t.Errorf("handler called %d times, want 1", called)
+	}
+}
+
+func TestRegisterTypedWithoutGeneratedSchema(t *testing.T) {
+	router, err := NewRouter(EnvelopeSchema)
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+	if err := router.RegisterSchema("UserCreated", "v1", `{"type":"object","required":["nickname"]}`); err != nil {
+		t.Fatalf("register schema: %v", err)
+	}
+	err = RegisterTyped(router, "UserCreated", "v1", func(context.Context, userCreated, MessageMetadata) HandlerResult {
+		return HandlerResult{ShouldDelete: true}
+	}, WithoutGeneratedSchema())
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	if rr := router.Route(context.Background(), typedBody("")); rr.FailureKind != FailPayloadSchema {
+		t.Errorf("hand-written schema was replaced: FailureKind = %v", rr.FailureKind)
+	}
+}
+
+func TestRegisterTypedKeepsRegisteredSchema(t *testing.T) {
+	router, err := NewRouter(EnvelopeSchema)
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+	if err := router.RegisterSchema("UserCreated", "v1", `{"type":"object","required":["nickname"]}`); err != nil {
+		t.Fatalf("register schema: %v", err)
+	}
+	handler := func(context.Context, userCreated, MessageMetadata) HandlerResult {
+		return HandlerResult{ShouldDelete: true}
+	}
+
+	if err := RegisterTyped(router, "UserCreated", "v1", handler); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if rr := router.Route(context.Background(), typedBody("")); rr.FailureKind != FailPayloadSchema {
+		t.Errorf("hand-written schema was replaced: FailureKind = %v", rr.FailureKind)
+	}
+
+	if err := RegisterTyped(router, "UserCreated", "v1", handler, ReplaceSchema()); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if rr := router.Route(context.Background(), typedBody("")); rr.FailureKind != FailNone {
+		t.Errorf("ReplaceSchema kept the hand-written schema: FailureKind = %v", rr.FailureKind)
+	}
+}
+
+func TestRegisterTypedReplacesGeneratedSchema(t *testing.T) {
+	type signup struct {
+		Email string `json:"email" jsonschema:"required"`
+	}
+	router, err := NewRouter(EnvelopeSchema)
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+	err = RegisterTyped(router, "UserCreated", "v1", func(context.Context, signup, MessageMetadata) HandlerResult {
+		return HandlerResult{ShouldDelete: true}
+	})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	err = RegisterTyped(router, "UserCreated", "v1", func(context.Context, userCreated, MessageMetadata) HandlerResult {
+		return HandlerResult{ShouldDelete: true}
+	})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	if rr := router.Route(context.Background(), typedBody("")); rr.FailureKind != FailNone {
+		t.Errorf("schema generated for the earlier type was kept: FailureKind = %v", rr.FailureKind)
+	}
+}
+
+func TestRegisterTypedUnsupportedType(t *testing.T) {
+	router, err := NewRouter(EnvelopeSchema)
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+	err = RegisterTyped(router, "UserCreated", "v1", func(context.Context, struct{ C chan int }, MessageMetadata) HandlerResult {
+		return HandlerResult{ShouldDelete: true}
+	})
+	if !errors.Is(err, ErrInvalidSchema) {
+		t.Fatalf("err = %v, want ErrInvalidSchema", err)
+	}
+	if _, ok := router.table.Load().handlers["UserCreated:v1"]; ok {
+		t.Error("handler must not be registered when the schema cannot be generated")
+	}
+}
@@ -3,7 +3,10 @@ package sqsrouter
 import (
 	"context"
 	"encoding/json"
+	"log/slog"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/xeipuuv/gojsonschema"
 )
@@ -19,25 +22,37 @@ type MessageEnvelope struct {
 }
 
 // MessageMetadata holds common metadata found in every message.
+// Extensions holds further attributes of the message, e.g. the extension attributes of a CloudEvent.
+// Only envelope codecs set it: it is not decoded from the metadata of the default envelope, so an
+// "extensions" member there is ignored. The router adds it under "extensions" to the metadata JSON
+// passed to handlers and predicates when it is not empty.
 type MessageMetadata struct {
-	Timestamp string `json:"timestamp"`
-	Source    string `json:"source"`
-	MessageID string `json:"messageId"`
+	Timestamp  string         `json:"timestamp"`
+	Source     string         `json:"source"`
+	MessageID  string         `json:"messageId"`
+	Extensions map[string]any `json:"-"`
 }
 
 // HandlerResult indicates the outcome of processing a message.
+// RetryAfter optionally asks the consumer to make a retained message visible again after the given delay
+// instead of waiting for the queue's visibility timeout. It is ignored when ShouldDelete is true.
 type HandlerResult struct {
 	ShouldDelete bool
 	Error        error
+	RetryAfter   time.Duration
 }
 
 // RoutedResult contains the complete result after a message has been routed and handled.
+// FailureKind records the failure the policy was consulted for; it is FailNone when routing succeeded.
+// Variant names the handler variant the routing policy selected, if any (see RoutingDecision).
 type RoutedResult struct {
 	MessageType    string
 	MessageVersion string
 	HandlerResult  HandlerResult
 	MessageID      string
 	Timestamp      string
+	FailureKind    FailureKind
+	Variant        string
 }
 
 // MessageHandler is a function type that processes a specific message type and version.
@@ -54,7 +69,19 @@ type RouteState struct {
 	SchemaExists  bool
 	Metadata      *MessageMetadata
 	Handler       MessageHandler
-	Schema        gojsonschema.JSONLoader
+	// Schema is the compiled payload schema for HandlerKey, or nil. It was a gojsonschema.JSONLoader before
+	// schemas were compiled at registration; validate with Schema.Validate instead of gojsonschema.Validate.
+	Schema *gojsonschema.Schema
+	// SQS holds the SQS delivery attributes when the message came through the consumer; nil otherwise.
+	SQS *SQSAttributes
+	// RoutingDecision describes how the routing policy resolved HandlerKey.
+	RoutingDecision RoutingDecision
+	// OriginalVersion is the version the message arrived with when upcasters converted it; Envelope then
+	// holds the converted payload and version. It is empty when the message was not upcast.
+	OriginalVersion string
+	// Wrappers lists the SNS notifications and EventBridge events the message was unwrapped from, outermost
+	// first, when WithUnwrapping is set; Raw remains the message body as received.
+	Wrappers []MessageWrapper
 }
 
 // HandlerFunc is the function signature wrapped by middlewares.
@@ -65,16 +92,19 @@ type HandlerFunc func(ctx context.Context, state *RouteState) (RoutedResult, err
 type Middleware func(next HandlerFunc) HandlerFunc
 
 // Router routes incoming messages to the correct handler based on message type and version.
-// It is safe for concurrent use.
+// It is safe for concurrent use. Route does not take locks; registrations publish a new routing table.
 type Router struct {
-	mu             sync.RWMutex
-	handlers       map[string]MessageHandler
-	schemas        map[string]gojsonschema.JSONLoader
-	envelopeSchema gojsonschema.JSONLoader
+	// mu serializes writers of table.
+	mu            sync.Mutex
+	table         atomic.Pointer[routingTable]
+	envelopeCodec EnvelopeCodec
+	// unwrap enables removing SNS and EventBridge wrappers before decoding envelopes.
+	unwrap bool
 
-	middlewares   []Middleware
 	routingPolicy RoutingPolicy
 	failurePolicy FailurePolicy
+	logger        *slog.Logger
+	metrics       Metrics
 }
 
 // (no consumer types here; moved to consumer package)
@@ -85,6 +115,33 @@ type HandlerKey string
 // RoutingPolicy decides which handler should process an incoming message.
 // Implementations may perform exact match, version fallback, A/B testing, etc.
 // Returning an empty HandlerKey means no handler selected.
+// availableHandlers is sorted and shared between calls; implementations must not modify it.
 type RoutingPolicy interface {
 	Decide(ctx context.Context, envelope *MessageEnvelope, availableHandlers []HandlerKey) HandlerKey
 }
+
+// RoutingDecision describes how a routing policy resolved a message to a handler.
+// Fallback is empty when Key is the message's own type and version (or no handler was selected);
+// otherwise it names the rule that selected Key, e.g. SemverFallbackOlder.
+// Variant names the handler variant selected by ContentPolicy or CanaryPolicy; it is copied to RoutedResult.Variant.
+// Err optionally explains why no handler was selected; it is wrapped into the FailNoHandler error.
+type RoutingDecision struct {
+	Key      HandlerKey
+	Fallback string
+	Variant  string
+	Err      error
+}
+
+// RoutingDecider is implemented by routing policies that report how they resolved a message.
+// When the router's RoutingPolicy implements it, DecideRouting is called instead of Decide and its
+// result is exposed as RouteState.RoutingDecision.
+type RoutingDecider interface {
+	DecideRouting(ctx context.Context, envelope *MessageEnvelope, availableHandlers []HandlerKey) RoutingDecision
+}
+
+// HandlerKeyValidator is implemented by routing policies that constrain the handler keys they route to.
+// Registration calls ValidateKey with the new key and the other registered keys, in sorted order,
+// and registers nothing when it returns an error.
+type HandlerKeyValidator interface {
+	ValidateKey(key HandlerKey, registered []HandlerKey) error
+}
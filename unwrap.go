@@ -0,0 +1,163 @@
+package sqsrouter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// WrapperKind identifies the service whose wrapper a message was delivered in.
+type WrapperKind string
+
+// Wrappers removed by WithUnwrapping.
+const (
+	// WrapperSNS is an SNS Notification, delivered by a subscription without raw message delivery.
+	WrapperSNS WrapperKind = "sns"
+	// WrapperEventBridge is an EventBridge event, delivered by a rule targeting the queue.
+	WrapperEventBridge WrapperKind = "eventbridge"
+)
+
+// maxWrapperDepth bounds unwrapping; two levels cover EventBridge events delivered through SNS.
+const maxWrapperDepth = 2
+
+// Markers that must occur in a body for it to be a wrapper; other bodies are not decoded for unwrapping.
+var (
+	snsMarker         = []byte(`"TopicArn"`)
+	eventBridgeMarker = []byte(`"detail-type"`)
+)
+
+// SNSMessageAttribute is an SNS message attribute as it appears in a Notification.
+type SNSMessageAttribute struct {
+	Type  string `json:"Type"`
+	Value string `json:"Value"`
+}
+
+// MessageWrapper holds the fields of an SNS notification or EventBridge event a message was unwrapped from.
+// Fields that do not apply to its Kind are empty.
+type MessageWrapper struct {
+	Kind WrapperKind
+	// ID is the SNS MessageId or the EventBridge event id.
+	ID string
+	// Time is the SNS Timestamp or the EventBridge event time.
+	Time string
+
+	// SNS notification fields.
+	TopicArn          string
+	Subject           string
+	MessageAttributes map[string]SNSMessageAttribute
+
+	// EventBridge event fields.
+	DetailType string
+	Source     string
+	Account    string
+	Region     string
+	Resources  []string
+}
+
+type snsNotification struct {
+	Type              string                         `json:"Type"`
+	MessageID         string                         `json:"MessageId"`
+	TopicArn          string                         `json:"TopicArn"`
+	Subject           string                         `json:"Subject"`
+	Message           json.RawMessage                `json:"Message"`
+	Timestamp         string                         `json:"Timestamp"`
+	MessageAttributes map[string]SNSMessageAttribute `json:"MessageAttributes"`
+}
+
+type eventBridgeEvent struct {
+	ID         string          `json:"id"`
+	DetailType string          `json:"detail-type"`
+	Source     string          `json:"source"`
+	Account    string          `json:"account"`
+	Time       string          `json:"time"`
+	Region     string          `json:"region"`
+	Resources  []string        `json:"resources"`
+	Detail     json.RawMessage `json:"detail"`
+}
+
+// unwrapEnvelope removes SNS and EventBridge wrappers from raw and returns the inner body together with
+// the wrappers, outermost first. Bodies that are not wrappers are returned unchanged.
+func unwrapEnvelope(raw []byte) ([]byte, []MessageWrapper, error) {
+	var wrappers []MessageWrapper
+	for range maxWrapperDepth {
+		inner, w, ok, err := unwrapOnce(raw)
+		if err != nil {
+			return raw, wrappers, err
+		}
+		if !ok {
+			break
+		}
+		wrappers = append(wrappers, w)
+		raw = inner
+	}
+	return raw, wrappers, nil
+}
+
+// unwrapOnce removes the outermost wrapper from raw; ok is false when raw is not a wrapper.
+func unwrapOnce(raw []byte) (inner []byte, w MessageWrapper, ok bool, err error) {
+	if bytes.Contains(raw, snsMarker) {
+		if inner, w, ok, err = unwrapSNS(raw); ok || err != nil {
+			return inner, w, ok, err
+		}
+	}
+	if bytes.Contains(raw, eventBridgeMarker) {
+		return unwrapEventBridge(raw)
+	}
+	return nil, w, false, nil
+}
+
+// unwrapSNS extracts the message of an SNS Notification.
+func unwrapSNS(raw []byte) ([]byte, MessageWrapper, bool, error) {
+	var n snsNotification
+	if json.Unmarshal(raw, &n) != nil || n.Type != "Notification" || n.TopicArn == "" {
+		return nil, MessageWrapper{}, false, nil
+	}
+	var inner string
+	if jsonTypeOf(n.Message) != "string" || json.Unmarshal(n.Message, &inner) != nil {
+		return nil, MessageWrapper{}, false, fmt.Errorf("%w: SNS notification Message must be a string", ErrInvalidEnvelope)
+	}
+	return []byte(inner), MessageWrapper{
+		Kind:              WrapperSNS,
+		ID:                n.MessageID,
+		Time:              n.Timestamp,
+		TopicArn:          n.TopicArn,
+		Subject:           n.Subject,
+		MessageAttributes: n.MessageAttributes,
+	}, true, nil
+}
+
+// unwrapEventBridge extracts the detail of an EventBridge event.
+func unwrapEventBridge(raw []byte) ([]byte, MessageWrapper, bool, error) {
+	var e eventBridgeEvent
+	if json.Unmarshal(raw, &e) != nil || e.DetailType == "" || e.Detail == nil {
+		return nil, MessageWrapper{}, false, nil
+	}
+	if jsonTypeOf(e.Detail) != "object" {
+		return nil, MessageWrapper{}, false, fmt.Errorf("%w: EventBridge event detail must be an object", ErrInvalidEnvelope)
+	}
+	return e.Detail, MessageWrapper{
+		Kind:       WrapperEventBridge,
+		ID:         e.ID,
+		Time:       e.Time,
+		DetailType: e.DetailType,
+		Source:     e.Source,
+		Account:    e.Account,
+		Region:     e.Region,
+		Resources:  e.Resources,
+	}, true, nil
+}
+
+// decodeBody unwraps the message body when WithUnwrapping is set, recording the wrappers on state,
+// and decodes the envelope.
+func (r *Router) decodeBody(state *RouteState) (MessageEnvelope, FailureKind, error) {
+	raw := state.Raw
+	if r.unwrap {
+		inner, wrappers, err := unwrapEnvelope(raw)
+		state.Wrappers = wrappers
+		if err != nil {
+			return MessageEnvelope{}, FailEnvelopeSchema, err
+		}
+		raw = inner
+	}
+	return r.parseEnvelope(raw)
+}
@@ -0,0 +1,128 @@
+package sqsrouter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const innerEnvelope = `{"schemaVersion":"1.0","messageType":"UserCreated","messageVersion":"v1","message":{"id":1},"metadata":{"messageId":"m-1"}}`
+
+// snsNotificationOf wraps body in an SNS Notification.
+func snsNotificationOf(t *testing.T, body string) string {
+	t.Helper()
+	b, err := json.Marshal(map[string]any{
+		"Type":              "Notification",
+		"MessageId":         "sns-1",
+		"TopicArn":          "arn:aws:sns:eu-west-1:123456789012:users",
+		"Subject":           "user created",
+		"Message":           body,
+		"Timestamp":         "2024-01-01T00:00:00.000Z",
+		"SignatureVersion":  "1",
+		"MessageAttributes": map[string]any{"tenant": map[string]string{"Type": "String", "Value": "acme"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// eventBridgeEventOf wraps detail in an EventBridge event.
+func eventBridgeEventOf(detail string) string {
+	return `{"version":"0","id":"eb-1","detail-type":"UserCreated","source":"users","account":"123456789012",` +
+		`"time":"2024-01-01T00:00:00Z","region":"eu-west-1","resources":["arn:r"],"detail":` + detail + `}`
+}
+
+func TestWithUnwrapping(t *testing.T) {
+	sns := MessageWrapper{
+		Kind: WrapperSNS, ID: "sns-1", Time: "2024-01-01T00:00:00.000Z",
+		TopicArn: "arn:aws:sns:eu-west-1:123456789012:users", Subject: "user created",
+		MessageAttributes: map[string]SNSMessageAttribute{"tenant": {Type: "String", Value: "acme"}},
+	}
+	eb := MessageWrapper{
+		Kind: WrapperEventBridge, ID: "eb-1", Time: "2024-01-01T00:00:00Z", DetailType: "UserCreated",
+		Source: "users", Account: "123456789012", Region: "eu-west-1", Resources: []string{"arn:r"},
+	}
+	cases := []struct {
+		name     string
+		raw      string
+		wrappers []MessageWrapper
+	}{
+		{"plain envelope", innerEnvelope, nil},
+		{"sns", snsNotificationOf(t, innerEnvelope), []MessageWrapper{sns}},
+		{"eventbridge", eventBridgeEventOf(innerEnvelope), []MessageWrapper{eb}},
+		{"eventbridge through sns", snsNotificationOf(t, eventBridgeEventOf(innerEnvelope)), []MessageWrapper{sns, eb}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := NewRouter(EnvelopeSchema, WithUnwrapping())
+			if err != nil {
+				t.Fatalf("new router: %v", err)
+			}
+			var got string
+			r.Register("UserCreated", "v1", func(_ context.Context, msg []byte, _ []byte) HandlerResult {
+				got = string(msg)
+				return HandlerResult{ShouldDelete: true}
+			})
+			var state RouteState
+			r.Use(captureState(&state))
+
+			rr := r.Route(context.Background(), []byte(tc.raw))
+
+			if rr.FailureKind != FailNone || rr.MessageID != "m-1" {
+				t.Fatalf("unexpected result: %+v", rr)
+			}
+			if got != `{"id":1}` {
+				t.Errorf("payload = %s", got)
+			}
+			if !reflect.DeepEqual(state.Wrappers, tc.wrappers) {
+				t.Errorf("wrappers = %+v, want %+v", state.Wrappers, tc.wrappers)
+			}
+			if string(state.Raw) != tc.raw {
+				t.Errorf("Raw must remain the received body")
+			}
+		})
+	}
+}
+
+func TestWithUnwrapping_Invalid(t *testing.T) {
+	cases := map[string]string{
+		"sns message not a string":  `{"Type":"Notification","TopicArn":"arn:t","Message":{"a":1}}`,
+		"eventbridge detail string": `{"detail-type":"T","source":"s","detail":"x"}`,
+		"inner body not JSON":       snsNotificationOf(t, "hello"),
+	}
+	for name, raw := range cases {
+		t.Run(name, func(t *testing.T) {
+			r, err := NewRouter(EnvelopeSchema, WithUnwrapping())
+			if err != nil {
+				t.Fatalf("new router: %v", err)
+			}
+			rr := r.Route(context.Background(), []byte(raw))
+			if rr.FailureKind != FailEnvelopeSchema || !errors.Is(rr.HandlerResult.Error, ErrInvalidEnvelope) {
+				t.Fatalf("want FailEnvelopeSchema, got %+v", rr)
+			}
+		})
+	}
+}
+
+func TestWithUnwrapping_Disabled(t *testing.T) {
+	r, err := NewRouter(EnvelopeSchema)
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+	rr := r.Route(context.Background(), []byte(snsNotificationOf(t, innerEnvelope)))
+	if rr.FailureKind != FailEnvelopeSchema {
+		t.Fatalf("kind = %v, want FailEnvelopeSchema without WithUnwrapping", rr.FailureKind)
+	}
+}
+
+func TestUnwrapEnvelope_NotAWrapper(t *testing.T) {
+	// Marker strings inside a plain envelope do not make it a wrapper.
+	raw := `{"schemaVersion":"1.0","messageType":"T","messageVersion":"v1","message":{"TopicArn":"x","detail-type":"y"},"metadata":{}}`
+	got, wrappers, err := unwrapEnvelope([]byte(raw))
+	if err != nil || wrappers != nil || string(got) != raw {
+		t.Fatalf("got %s, %v, %v", got, wrappers, err)
+	}
+}
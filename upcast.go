@@ -0,0 +1,77 @@
+package sqsrouter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Upcaster converts a message payload from one version to the next, e.g. a 1.0 payload to 2.0.
+// Upcasters should be pure functions of the payload: a failure is reported as FailUpcast, which
+// ImmediateDeletePolicy treats as permanent.
+type Upcaster func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error)
+
+// upcastStep is a registered upcaster and the version it produces.
+type upcastStep struct {
+	toVersion string
+	upcast    Upcaster
+}
+
+// RegisterUpcaster registers a conversion of messageType payloads from fromVersion to toVersion.
+// A message arriving with fromVersion is converted before routing, following registered upcasters step by
+// step (1.0 -> 1.1 -> 2.0) until no upcaster applies. It is then routed, validated against the schema
+// registered for the resulting version and handled as that version, so older handlers can be removed once
+// an upcaster covers their version. RouteState.OriginalVersion records the version the message arrived
+// with, and RoutedResult reports it as MessageVersion.
+//
+// Registering another upcaster from the same version replaces it. An error is returned, and nothing is
+// registered, when the versions are equal, upcaster is nil or the chain would loop.
+func (r *Router) RegisterUpcaster(messageType, fromVersion, toVersion string, upcaster Upcaster) error {
+	from := makeKey(messageType, fromVersion)
+	if fromVersion == toVersion || upcaster == nil {
+		return fmt.Errorf("%w for %s: needs a function and a different target version", ErrInvalidUpcaster, from)
+	}
+	return r.updateTable(func(t *routingTable) error {
+		// The chain is acyclic, so following it from toVersion ends unless it reaches fromVersion.
+		for v := toVersion; ; {
+			if v == fromVersion {
+				return fmt.Errorf("%w for %s: upcasting to %s loops back to %s", ErrInvalidUpcaster, from, toVersion, fromVersion)
+			}
+			step, ok := t.upcasters[makeKey(messageType, v)]
+			if !ok {
+				break
+			}
+			v = step.toVersion
+		}
+		t.upcasters[from] = upcastStep{toVersion: toVersion, upcast: upcaster}
+		return nil
+	})
+}
+
+// upcast converts the envelope of state in place to the newest version its upcasters lead to,
+// recording the version it arrived with in state.OriginalVersion before the first conversion.
+func (t *routingTable) upcast(ctx context.Context, state *RouteState) error {
+	if len(t.upcasters) == 0 {
+		return nil
+	}
+	envelope := state.Envelope
+	for {
+		step, ok := t.upcasters[makeKey(envelope.MessageType, envelope.MessageVersion)]
+		if !ok {
+			return nil
+		}
+		out, err := step.upcast(ctx, envelope.Message)
+		if err == nil && !json.Valid(out) {
+			err = errors.New("upcaster returned invalid JSON")
+		}
+		if err != nil {
+			return fmt.Errorf("%w from %s to %s: %v", ErrUpcastFailed, envelope.MessageVersion, step.toVersion, err)
+		}
+		if state.OriginalVersion == "" {
+			state.OriginalVersion = envelope.MessageVersion
+		}
+		envelope.Message = out
+		envelope.MessageVersion = step.toVersion
+	}
+}
@@ -0,0 +1,197 @@
+package sqsrouter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const upcastMessage = `{"schemaVersion":"1.0","messageType":"UserCreated","messageVersion":"1.0","message":{"name":"Ada Lovelace"},"metadata":{"messageId":"m-1"}}`
+
+// splitName upcasts {"name":"A B"} to {"first":"A","last":"B"}.
+func splitName(_ context.Context, payload json.RawMessage) (json.RawMessage, error) {
+	var v1 struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(payload, &v1); err != nil {
+		return nil, err
+	}
+	first, last, _ := strings.Cut(v1.Name, " ")
+	return json.Marshal(map[string]string{"first": first, "last": last})
+}
+
+// addTier upcasts a payload by adding "tier":"free".
+func addTier(_ context.Context, payload json.RawMessage) (json.RawMessage, error) {
+	var m map[string]any
+	if err := json.Unmarshal(payload, &m); err != nil {
+		return nil, err
+	}
+	m["tier"] = "free"
+	return json.Marshal(m)
+}
+
+// captureState returns a middleware storing the route state of the last message in *state.
+func captureState(state *RouteState) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, s *RouteState) (RoutedResult, error) {
+			rr, err := next(ctx, s)
+			*state = *s
+			return rr, err
+		}
+	}
+}
+
+func TestRegisterUpcaster_Chain(t *testing.T) {
+	m := &recordingMetrics{}
+	r, err := NewRouter(EnvelopeSchema, WithMetrics(m))
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+	var got string
+	r.Register("UserCreated", "3.0", func(_ context.Context, msg []byte, _ []byte) HandlerResult {
+		got = string(msg)
+		return HandlerResult{ShouldDelete: true}
+	})
+	if err := r.RegisterSchema("UserCreated", "3.0", `{"type":"object","required":["first","last","tier"]}`); err != nil {
+		t.Fatalf("register schema: %v", err)
+	}
+	if err := r.RegisterUpcaster("UserCreated", "1.0", "2.0", splitName); err != nil {
+		t.Fatalf("register upcaster: %v", err)
+	}
+	if err := r.RegisterUpcaster("UserCreated", "2.0", "3.0", addTier); err != nil {
+		t.Fatalf("register upcaster: %v", err)
+	}
+	var state RouteState
+	r.Use(captureState(&state))
+
+	rr := r.Route(context.Background(), []byte(upcastMessage))
+
+	if rr.FailureKind != FailNone || !rr.HandlerResult.ShouldDelete {
+		t.Fatalf("unexpected result: %+v", rr)
+	}
+	if want := `{"first":"Ada","last":"Lovelace","tier":"free"}`; got != want {
+		t.Errorf("payload = %s, want %s", got, want)
+	}
+	if rr.MessageVersion != "1.0" {
+		t.Errorf("result version = %q, want the original 1.0", rr.MessageVersion)
+	}
+	if state.OriginalVersion != "1.0" || state.Envelope.MessageVersion != "3.0" || state.HandlerKey != "UserCreated:3.0" {
+		t.Errorf("state: original %q, version %q, key %q", state.OriginalVersion, state.Envelope.MessageVersion, state.HandlerKey)
+	}
+	if len(m.handlers) != 1 || m.handlers[0] != "UserCreated:1.0" {
+		t.Errorf("handler duration recorded for %v, want the original version like the result", m.handlers)
+	}
+}
+
+func TestRegisterUpcaster_CurrentVersionUntouched(t *testing.T) {
+	r, err := NewRouter(EnvelopeSchema)
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+	r.Register("UserCreated", "1.0", func(context.Context, []byte, []byte) HandlerResult {
+		return HandlerResult{ShouldDelete: true}
+	})
+	if err := r.RegisterUpcaster("UserCreated", "0.9", "1.0", addTier); err != nil {
+		t.Fatalf("register upcaster: %v", err)
+	}
+	var state RouteState
+	r.Use(captureState(&state))
+
+	rr := r.Route(context.Background(), []byte(upcastMessage))
+
+	if rr.FailureKind != FailNone {
+		t.Fatalf("unexpected result: %+v", rr)
+	}
+	if state.OriginalVersion != "" {
+		t.Errorf("OriginalVersion = %q, want empty", state.OriginalVersion)
+	}
+}
+
+func TestRegisterUpcaster_TargetSchema(t *testing.T) {
+	r, err := NewRouter(EnvelopeSchema)
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+	r.Register("UserCreated", "2.0", func(context.Context, []byte, []byte) HandlerResult {
+		t.Error("handler must not run")
+		return HandlerResult{ShouldDelete: true}
+	})
+	if err := r.RegisterSchema("UserCreated", "2.0", `{"type":"object","required":["tier"]}`); err != nil {
+		t.Fatalf("register schema: %v", err)
+	}
+	if err := r.RegisterUpcaster("UserCreated", "1.0", "2.0", splitName); err != nil {
+		t.Fatalf("register upcaster: %v", err)
+	}
+
+	rr := r.Route(context.Background(), []byte(upcastMessage))
+
+	if rr.FailureKind != FailPayloadSchema || !errors.Is(rr.HandlerResult.Error, ErrInvalidMessagePayload) {
+		t.Fatalf("want FailPayloadSchema, got %+v", rr)
+	}
+}
+
+func TestRegisterUpcaster_Failure(t *testing.T) {
+	boom := errors.New("boom")
+	cases := []struct {
+		name     string
+		upcaster Upcaster
+	}{
+		{"error", func(context.Context, json.RawMessage) (json.RawMessage, error) { return nil, boom }},
+		{"invalid JSON", func(context.Context, json.RawMessage) (json.RawMessage, error) { return json.RawMessage(`{`), nil }},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := NewRouter(EnvelopeSchema)
+			if err != nil {
+				t.Fatalf("new router: %v", err)
+			}
+			r.Register("UserCreated", "2.0", func(context.Context, []byte, []byte) HandlerResult {
+				t.Error("handler must not run")
+				return HandlerResult{ShouldDelete: true}
+			})
+			if err := r.RegisterUpcaster("UserCreated", "1.0", "2.0", tc.upcaster); err != nil {
+				t.Fatalf("register upcaster: %v", err)
+			}
+
+			rr := r.Route(context.Background(), []byte(upcastMessage))
+
+			if rr.FailureKind != FailUpcast || !errors.Is(rr.HandlerResult.Error, ErrUpcastFailed) {
+				t.Fatalf("want FailUpcast, got %+v", rr)
+			}
+			if !rr.HandlerResult.ShouldDelete {
+				t.Errorf("ImmediateDeletePolicy should delete upcast failures")
+			}
+			if rr.MessageVersion != "1.0" {
+				t.Errorf("result version = %q, want 1.0", rr.MessageVersion)
+			}
+		})
+	}
+}
+
+func TestRegisterUpcaster_Validation(t *testing.T) {
+	r, err := NewRouter(EnvelopeSchema)
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+	if err := r.RegisterUpcaster("UserCreated", "1.0", "1.0", addTier); !errors.Is(err, ErrInvalidUpcaster) {
+		t.Errorf("same version: err = %v, want ErrInvalidUpcaster", err)
+	}
+	if err := r.RegisterUpcaster("UserCreated", "1.0", "2.0", nil); !errors.Is(err, ErrInvalidUpcaster) {
+		t.Errorf("nil upcaster: err = %v, want ErrInvalidUpcaster", err)
+	}
+	if err := r.RegisterUpcaster("UserCreated", "1.0", "2.0", addTier); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if err := r.RegisterUpcaster("UserCreated", "2.0", "3.0", addTier); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if err := r.RegisterUpcaster("UserCreated", "3.0", "1.0", addTier); !errors.Is(err, ErrInvalidUpcaster) {
+		t.Errorf("cycle: err = %v, want ErrInvalidUpcaster", err)
+	}
+	// Another type may convert between the same versions.
+	if err := r.RegisterUpcaster("UserDeleted", "3.0", "1.0", addTier); err != nil {
+		t.Errorf("other type: %v", err)
+	}
+}